@@ -20,6 +20,7 @@ package v1beta1
 import (
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -38,11 +39,183 @@ type AdaptiveMovingTargetDefenseSpec struct {
 	// +kubebuilder:validation:MinItems=1
 	// Define strategy that maps actions to security events (based on the security event fields)
 	Strategy []ResponseStrategy `json:"strategy"`
+
+	// +kubebuilder:validation:Optional
+	// DefaultAction is executed when no rule of the strategy matches the SecurityEvent
+	DefaultAction *AMTDAction `json:"defaultAction,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Priority of the AMTD over other AMTDs managing the same pod, the higher the stronger
+	Priority int32 `json:"priority,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Mode is enforce (default) to execute the actions or audit to only report what would be executed
+	Mode AMTDMode `json:"mode,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// NotificationSinks in the namespace of the AMTD that are notified about the outcome of the actions
+	NotificationSinks []corev1.LocalObjectReference `json:"notificationSinks,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Rotation replaces the managed pods periodically, even if no SecurityEvent refers to them
+	Rotation *Rotation `json:"rotation,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Disruption limits the destructive actions (delete and quarantine) executed on the selected pods
+	Disruption *DisruptionLimits `json:"disruption,omitempty"`
+}
+
+// DisruptionLimits keeps enough of the selected pods available while destructive actions are executed.
+// Actions that would exceed a limit are not dropped but retried until the limit allows them.
+type DisruptionLimits struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// MaxConcurrentDeletions is the maximum number of selected pods that may be terminating at the same time
+	MaxConcurrentDeletions *int32 `json:"maxConcurrentDeletions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// MaxConcurrentQuarantines is the maximum number of selected pods that may be quarantined at the same time
+	MaxConcurrentQuarantines *int32 `json:"maxConcurrentQuarantines,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:XIntOrString
+	// MinAvailable is the number or the percentage of the selected pods that must stay available
+	// (ready, not terminating and not isolated) after the action
+	MinAvailable *intstr.IntOrString `json:"minAvailable,omitempty"`
+}
+
+// Rotation deletes the managed pods periodically so that their owners replace them with fresh ones.
+// Exactly one of interval and schedule must be set.
+// +kubebuilder:validation:XValidation:rule="has(self.interval) != has(self.schedule)",message="exactly one of interval and schedule must be set"
+type Rotation struct {
+	// +kubebuilder:validation:Optional
+	// Interval is the maximum lifetime of a managed pod, e.g. "6h"
+	Interval *metav1.Duration `json:"interval,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Schedule in cron format (minute hour day-of-month month day-of-week, in UTC), e.g. "0 3 * * *",
+	// every pod that was created before the scheduled time is rotated
+	Schedule string `json:"schedule,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Jitter delays the rotation of each pod by a random duration up to this value,
+	// so that the pods are not rotated at the same moment
+	Jitter *metav1.Duration `json:"jitter,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default:=1
+	// MaxConcurrent is the maximum number of pods rotated at the same time, a pod is being
+	// rotated while it terminates or while the pods of the AMTD are not all ready
+	MaxConcurrent int32 `json:"maxConcurrent,omitempty"`
 }
 
+// AMTDMode decides whether the actions of an AMTD are executed
+// +kubebuilder:validation:Enum=enforce;audit
+type AMTDMode string
+
+const (
+	// AMTDEnforce executes the actions
+	AMTDEnforce AMTDMode = "enforce"
+	// AMTDAudit plans the actions but does not execute them, the would-be actions are reported
+	// in the status of the SecurityEvents, in Kubernetes Events and in the metrics
+	AMTDAudit AMTDMode = "audit"
+)
+
 type DisableAction struct{}
-type DeleteAction struct{}
-type QuarantineAction struct{}
+
+// DeleteMode decides how the delete action removes the pod
+// +kubebuilder:validation:Enum=evict;delete
+type DeleteMode string
+
+const (
+	// DeleteEvict evicts the pod with the Eviction API, so that its PodDisruptionBudgets are honoured
+	DeleteEvict DeleteMode = "evict"
+	// DeleteDirect deletes the pod without checking its PodDisruptionBudgets
+	DeleteDirect DeleteMode = "delete"
+)
+
+type DeleteAction struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=evict
+	// Mode is either evict (default) or delete
+	Mode DeleteMode `json:"mode,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=0
+	// GracePeriodSeconds overrides the terminationGracePeriodSeconds of the pod
+	GracePeriodSeconds *int64 `json:"gracePeriodSeconds,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// WaitForReplacement releases the pod from its ReplicaSet or DaemonSet first and deletes it only
+	// when the replacement created by the owner is ready, so that the pod keeps serving until then
+	WaitForReplacement bool `json:"waitForReplacement,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ReplacementTimeout is how long to wait for the replacement before the pod is deleted anyway,
+	// defaults to 5m
+	ReplacementTimeout *metav1.Duration `json:"replacementTimeout,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ForceAfter force-deletes the pod (with zero grace period) when it is still terminating this
+	// long after its grace period expired, e.g. because its node is unreachable
+	ForceAfter *metav1.Duration `json:"forceAfter,omitempty"`
+}
+
+// QuarantineIsolation decides which direction of the traffic of a quarantined pod is blocked
+// +kubebuilder:validation:Enum=both;ingress;egress
+type QuarantineIsolation string
+
+const (
+	QuarantineIsolateBoth    QuarantineIsolation = "both"
+	QuarantineIsolateIngress QuarantineIsolation = "ingress"
+	QuarantineIsolateEgress  QuarantineIsolation = "egress"
+)
+
+// NetworkEndpoint is an endpoint outside of the cluster that a quarantined pod may connect to
+type NetworkEndpoint struct {
+	// +kubebuilder:validation:Required
+	// CIDR of the endpoint, e.g. 10.0.12.7/32
+	CIDR string `json:"cidr"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=65535
+	// Port of the endpoint, every port of the protocol is allowed if it is not set
+	Port *int32 `json:"port,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=TCP;UDP;SCTP
+	// +kubebuilder:default:=TCP
+	Protocol corev1.Protocol `json:"protocol,omitempty"`
+}
+
+type QuarantineAction struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default:=both
+	// Isolation is the direction of the traffic that is blocked: both (default), ingress or egress
+	Isolation QuarantineIsolation `json:"isolation,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// AllowDNS allows DNS queries to the cluster DNS (the kube-dns pods of the kube-system namespace)
+	AllowDNS bool `json:"allowDNS,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ForensicsNamespace allows the traffic from and to the pods of this namespace, so that
+	// the quarantined pod can be inspected
+	ForensicsNamespace string `json:"forensicsNamespace,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LoggingEndpoints are allowed as egress destinations, so that the quarantined pod can ship its logs
+	LoggingEndpoints []NetworkEndpoint `json:"loggingEndpoints,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// TemplateNetworkPolicy is the name of a NetworkPolicy in the namespace of the pod whose ingress and
+	// egress rules are allowed for the quarantined pod, on top of the ones above
+	TemplateNetworkPolicy string `json:"templateNetworkPolicy,omitempty"`
+}
+
 type Debugger struct {
 
 	// +kubebuilder:validation:Optional
@@ -245,6 +418,18 @@ type CustomAction struct {
 	TargetContainerName string `json:"targetContainerName,omitempty" protobuf:"bytes,2,opt,name=targetContainerName"`
 }
 
+// PluginAction refers to an action that is not built into Phoenix but registered
+// by the component embedding the Phoenix controllers (see pkg/actions)
+type PluginAction struct {
+	// +kubebuilder:validation:Required
+	// Name the action was registered with
+	Name string `json:"name"`
+
+	// +kubebuilder:validation:Optional
+	// Parameters are passed to the registered action as is
+	Parameters map[string]string `json:"parameters,omitempty"`
+}
+
 // +kubebuilder:validation:MaxProperties=1
 type AMTDAction struct {
 	Disable      *DisableAction    `json:"disable,omitempty"`
@@ -252,6 +437,7 @@ type AMTDAction struct {
 	Quarantine   *QuarantineAction `json:"quarantine,omitempty"`
 	Debugger     *Debugger         `json:"debugger,omitempty"`
 	CustomAction *CustomAction     `json:"customAction,omitempty"`
+	Plugin       *PluginAction     `json:"plugin,omitempty"`
 }
 
 // MovingStrategy Substructure for strategy definitions
@@ -264,6 +450,10 @@ type ResponseStrategy struct {
 	// +kubebuilder:validation:Required
 	// Action field value of the SecurityEvent that arrives
 	Action AMTDAction `json:"action"`
+
+	// +kubebuilder:validation:Optional
+	// Priority of the strategy over other matching strategies of the AMTD, the higher the stronger
+	Priority int32 `json:"priority,omitempty"`
 }
 
 type Rule struct {
@@ -280,14 +470,97 @@ type Rule struct {
 	Source string `json:"source"`
 }
 
+// Condition types of AdaptiveMovingTargetDefenseStatus
+const (
+	// AMTDActive is True when the AMTD manages at least one pod and its rules do not collide with other AMTDs
+	AMTDActive string = "Active"
+	// AMTDRuleCollision is True when a rule of the AMTD collides with a rule of another AMTD managing the same pod
+	AMTDRuleCollision string = "RuleCollision"
+)
+
+// StrategyStatus counts the actions executed based on a strategy
+type StrategyStatus struct {
+	// Rule of the strategy
+	Rule Rule `json:"rule"`
+
+	// Action of the strategy
+	Action string `json:"action"`
+
+	// Executions is the number of times the action was executed successfully
+	Executions int64 `json:"executions"`
+
+	// +kubebuilder:validation:Optional
+	// LastExecutionTime is when the action was executed the last time
+	LastExecutionTime *metav1.Time `json:"lastExecutionTime,omitempty"`
+}
+
 // AdaptiveMovingTargetDefenseStatus defines the observed state of AdaptiveMovingTargetDefense
 type AdaptiveMovingTargetDefenseStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// +kubebuilder:validation:Optional
+	// ObservedGeneration is the generation of the spec the status was computed for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// ManagedPodCount is the number of pods managed by the AMTD
+	ManagedPodCount int32 `json:"managedPodCount"`
+
+	// +kubebuilder:validation:Optional
+	// ManagedPods lists the names of the pods managed by the AMTD
+	ManagedPods []string `json:"managedPods,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LastReconcileTime is when the pods selected by the AMTD were processed the last time
+	LastReconcileTime *metav1.Time `json:"lastReconcileTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Strategies counts the actions executed per strategy
+	Strategies []StrategyStatus `json:"strategies,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// DefaultActionExecutions is the number of times the default action was executed successfully
+	DefaultActionExecutions int64 `json:"defaultActionExecutions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LastDefaultActionTime is when the default action was executed the last time
+	LastDefaultActionTime *metav1.Time `json:"lastDefaultActionTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Rotation reports the periodic rotation of the managed pods
+	Rotation *RotationStatus `json:"rotation,omitempty"`
+}
+
+// RotationStatus reports the periodic rotation of the managed pods
+type RotationStatus struct {
+	// +kubebuilder:validation:Optional
+	// LastScheduleTime is the last time the schedule activated
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LastRotationTime is when a pod was rotated the last time
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// RotatedPods is the number of pods rotated so far
+	RotatedPods int64 `json:"rotatedPods,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// LastAuditTime is when the pods due for rotation were reported the last time in audit mode,
+	// the pods that were due before are not reported again
+	LastAuditTime *metav1.Time `json:"lastAuditTime,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Mode",type=string,JSONPath=`.spec.mode`
+// +kubebuilder:printcolumn:name="Pods",type=integer,JSONPath=`.status.managedPodCount`
+// +kubebuilder:printcolumn:name="Active",type=string,JSONPath=`.status.conditions[?(@.type=="Active")].status`
+// +kubebuilder:printcolumn:name="Last Reconcile",type="date",JSONPath=".status.lastReconcileTime"
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // AdaptiveMovingTargetDefense is the Schema for the adaptivemovingtargetdefenses API
 type AdaptiveMovingTargetDefense struct {
 	metav1.TypeMeta   `json:",inline"`
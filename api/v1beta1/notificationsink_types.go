@@ -0,0 +1,177 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package v1beta1
+
+import (
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ActionOutcome is the result of executing an action on a target
+// +kubebuilder:validation:Enum=Succeeded;Failed;Audited
+type ActionOutcome string
+
+const (
+	// ActionSucceeded means that the action was executed on the target
+	ActionSucceeded ActionOutcome = "Succeeded"
+	// ActionFailed means that the action could not be executed on the target
+	ActionFailed ActionOutcome = "Failed"
+	// ActionAudited means that the action would have been executed but the AMTD is in audit mode
+	ActionAudited ActionOutcome = "Audited"
+)
+
+// NotificationSinkSpec defines where and how the outcome of the actions is reported.
+// Exactly one of webhook, slack, cloudEvents and smtp must be set.
+type NotificationSinkSpec struct {
+	// +kubebuilder:validation:Optional
+	// Webhook posts the notification as JSON to an HTTP endpoint
+	Webhook *WebhookSink `json:"webhook,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Slack posts a message to a Slack-compatible incoming webhook
+	Slack *SlackSink `json:"slack,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CloudEvents posts the notification as a structured CloudEvent
+	CloudEvents *CloudEventsSink `json:"cloudEvents,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// SMTP sends the notification in an e-mail
+	SMTP *SMTPSink `json:"smtp,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Outcomes to notify about, every outcome if empty
+	Outcomes []ActionOutcome `json:"outcomes,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Retry configures the redelivery of notifications that could not be delivered
+	Retry *NotificationRetry `json:"retry,omitempty"`
+}
+
+// HTTPEndpoint is an HTTP endpoint that receives notifications
+type HTTPEndpoint struct {
+	// +kubebuilder:validation:Optional
+	// URL of the endpoint
+	URL string `json:"url,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// URLSecretRef selects the key of a Secret in the namespace of the NotificationSink that contains
+	// the URL of the endpoint, it takes precedence over url
+	URLSecretRef *corev1.SecretKeySelector `json:"urlSecretRef,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Headers added to the requests, e.g. Authorization
+	Headers map[string]string `json:"headers,omitempty"`
+}
+
+// WebhookSink posts the notification as JSON
+type WebhookSink struct {
+	HTTPEndpoint `json:",inline"`
+}
+
+// SlackSink posts a message to a Slack-compatible incoming webhook
+type SlackSink struct {
+	HTTPEndpoint `json:",inline"`
+
+	// +kubebuilder:validation:Optional
+	// Channel overrides the default channel of the incoming webhook
+	Channel string `json:"channel,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Username overrides the default username of the incoming webhook
+	Username string `json:"username,omitempty"`
+}
+
+// CloudEventsSink posts the notification as a structured CloudEvent
+type CloudEventsSink struct {
+	HTTPEndpoint `json:",inline"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=phoenix
+	// Source attribute of the CloudEvents
+	Source string `json:"source,omitempty"`
+}
+
+// SMTPSink sends the notification in an e-mail
+type SMTPSink struct {
+	// +kubebuilder:validation:Required
+	// Host of the SMTP server
+	Host string `json:"host"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default=587
+	// Port of the SMTP server
+	Port int32 `json:"port,omitempty"`
+
+	// +kubebuilder:validation:Required
+	// From is the sender address of the e-mails
+	From string `json:"from"`
+
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	// To lists the recipient addresses of the e-mails
+	To []string `json:"to"`
+
+	// +kubebuilder:validation:Optional
+	// Username for PLAIN authentication, no authentication if empty
+	Username string `json:"username,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// PasswordSecretRef selects the key of a Secret in the namespace of the NotificationSink that
+	// contains the password
+	PasswordSecretRef *corev1.SecretKeySelector `json:"passwordSecretRef,omitempty"`
+}
+
+// NotificationRetry configures the redelivery of notifications
+type NotificationRetry struct {
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:default=3
+	// Attempts is the maximum number of delivery attempts
+	Attempts int32 `json:"attempts,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:default="1s"
+	// Backoff is the delay before the first redelivery, it is doubled after each attempt
+	Backoff metav1.Duration `json:"backoff,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:printcolumn:name="Webhook",type=string,JSONPath=`.spec.webhook.url`,priority=1
+// +kubebuilder:printcolumn:name="SMTP",type=string,JSONPath=`.spec.smtp.host`,priority=1
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+// NotificationSink is the Schema for the notificationsinks API
+type NotificationSink struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec NotificationSinkSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// NotificationSinkList contains a list of NotificationSink
+type NotificationSinkList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []NotificationSink `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&NotificationSink{}, &NotificationSinkList{})
+}
@@ -18,7 +18,11 @@
 package v1beta1
 
 import (
+	"fmt"
+	"strings"
+
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/validation"
 )
 
 // EDIT THIS FILE!  THIS IS SCAFFOLDING FOR YOU TO OWN!
@@ -30,7 +34,7 @@ type SecurityEventSpec struct {
 	// Important: Run "make" to regenerate code after modifying this file
 
 	// +kubebuilder:validation:Required
-	// Targets contains the list of affected pods, each item in the form of "namespace/name" or "/name"
+	// Targets contains the list of affected pods, each item in the form of "namespace/name"
 	Targets []string `json:"targets"`
 
 	// +kubebuilder:validation:Required
@@ -41,10 +45,123 @@ type SecurityEventSpec struct {
 	Description string `json:"description"`
 }
 
+// ParseTarget splits a target of a SecurityEvent into the namespace and the name of the pod
+func ParseTarget(target string) (string, string, error) {
+	parts := strings.Split(target, "/")
+	if len(parts) != 2 {
+		return "", "", fmt.Errorf(`target "%s" is not in the form of "namespace/name"`, target)
+	}
+	namespace, name := parts[0], parts[1]
+	if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+		return "", "", fmt.Errorf(`invalid namespace in target "%s": %s`, target, strings.Join(errs, ", "))
+	}
+	if errs := validation.IsDNS1123Subdomain(name); len(errs) > 0 {
+		return "", "", fmt.Errorf(`invalid pod name in target "%s": %s`, target, strings.Join(errs, ", "))
+	}
+	return namespace, name, nil
+}
+
+// SecurityEventPhase is the overall state of processing a SecurityEvent
+// +kubebuilder:validation:Enum=Pending;Processing;Applied;PartiallyFailed;Ignored;Audited
+type SecurityEventPhase string
+
+const (
+	// SecurityEventPending means that the targets of the SecurityEvent are not processed yet
+	SecurityEventPending SecurityEventPhase = "Pending"
+	// SecurityEventProcessing means that actions on some targets are still in progress
+	SecurityEventProcessing SecurityEventPhase = "Processing"
+	// SecurityEventApplied means that an action was executed on every target that has a matching strategy
+	SecurityEventApplied SecurityEventPhase = "Applied"
+	// SecurityEventPartiallyFailed means that the action failed on at least one target
+	SecurityEventPartiallyFailed SecurityEventPhase = "PartiallyFailed"
+	// SecurityEventIgnored means that no action was executed because no target has a matching strategy
+	SecurityEventIgnored SecurityEventPhase = "Ignored"
+	// SecurityEventAudited means that matching strategies were found but only in audit mode, so no action was executed
+	SecurityEventAudited SecurityEventPhase = "Audited"
+)
+
+// TargetPhase is the state of processing a single target of a SecurityEvent
+// +kubebuilder:validation:Enum=Pending;Applied;Failed;Ignored;Audited
+type TargetPhase string
+
+const (
+	TargetPending TargetPhase = "Pending"
+	TargetApplied TargetPhase = "Applied"
+	TargetFailed  TargetPhase = "Failed"
+	TargetIgnored TargetPhase = "Ignored"
+	// TargetAudited means that the action was planned in audit mode but not executed
+	TargetAudited TargetPhase = "Audited"
+)
+
+// Condition types of SecurityEventStatus
+const (
+	// SecurityEventProcessed is True when every target reached a final phase
+	SecurityEventProcessed string = "Processed"
+	// SecurityEventActionsApplied is True when the action succeeded on every target that has a matching strategy
+	SecurityEventActionsApplied string = "ActionsApplied"
+)
+
+// TargetStatus describes what happened with a target of the SecurityEvent
+type TargetStatus struct {
+	// Target as it is listed in the spec
+	Target string `json:"target"`
+
+	// Phase of processing the target
+	Phase TargetPhase `json:"phase"`
+
+	// +kubebuilder:validation:Optional
+	// AMTD whose strategy matched the SecurityEvent, in the form of "namespace/name"
+	AMTD string `json:"amtd,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Rule of the matched strategy
+	Rule *Rule `json:"rule,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Action executed on the target, or the action that would have been executed in audit mode
+	Action string `json:"action,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CompletedActions are the actions that were executed, or reported in audit mode, on the target.
+	// They are not executed again when the target is retried.
+	CompletedActions []string `json:"completedActions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// StartTime is when the processing of the target started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// CompletionTime is when the target reached a final phase
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// IssuedTime is when Phoenix issued the deletion or eviction of the pod for an action that
+	// finishes only when the pod is gone
+	IssuedTime *metav1.Time `json:"issuedTime,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Message explains why the target was ignored
+	Message string `json:"message,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Error of the last failed attempt
+	Error string `json:"error,omitempty"`
+}
+
 // SecurityEventStatus defines the observed state of SecurityEvent
 type SecurityEventStatus struct {
-	// INSERT ADDITIONAL STATUS FIELD - define observed state of cluster
-	// Important: Run "make" to regenerate code after modifying this file
+	// +kubebuilder:validation:Optional
+	// Phase summarizes the phases of the targets
+	Phase SecurityEventPhase `json:"phase,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// +listType=map
+	// +listMapKey=type
+	Conditions []metav1.Condition `json:"conditions,omitempty"`
+
+	// +kubebuilder:validation:Optional
+	// Targets contains the result of processing for each target
+	Targets []TargetStatus `json:"targets,omitempty"`
 }
 
 // +kubebuilder:object:root=true
@@ -55,6 +172,8 @@ type SecurityEventStatus struct {
 // +kubebuilder:printcolumn:name="Type",type=string,JSONPath=`.spec.rule.type`
 // +kubebuilder:printcolumn:name="Level",type=string,JSONPath=`.spec.rule.threatLevel`
 // +kubebuilder:printcolumn:name="Description",type=string,JSONPath=`.spec.description`
+// +kubebuilder:printcolumn:name="Phase",type=string,JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Actions",type=string,JSONPath=`.status.targets[*].action`
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // SecurityEvent is the Schema for the securityevents API
 type SecurityEvent struct {
@@ -23,7 +23,9 @@ package v1beta1
 
 import (
 	"k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
 )
 
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
@@ -37,12 +39,12 @@ func (in *AMTDAction) DeepCopyInto(out *AMTDAction) {
 	if in.Delete != nil {
 		in, out := &in.Delete, &out.Delete
 		*out = new(DeleteAction)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Quarantine != nil {
 		in, out := &in.Quarantine, &out.Quarantine
 		*out = new(QuarantineAction)
-		**out = **in
+		(*in).DeepCopyInto(*out)
 	}
 	if in.Debugger != nil {
 		in, out := &in.Debugger, &out.Debugger
@@ -54,6 +56,11 @@ func (in *AMTDAction) DeepCopyInto(out *AMTDAction) {
 		*out = new(CustomAction)
 		(*in).DeepCopyInto(*out)
 	}
+	if in.Plugin != nil {
+		in, out := &in.Plugin, &out.Plugin
+		*out = new(PluginAction)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AMTDAction.
@@ -72,7 +79,7 @@ func (in *AdaptiveMovingTargetDefense) DeepCopyInto(out *AdaptiveMovingTargetDef
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdaptiveMovingTargetDefense.
@@ -142,6 +149,26 @@ func (in *AdaptiveMovingTargetDefenseSpec) DeepCopyInto(out *AdaptiveMovingTarge
 			(*in)[i].DeepCopyInto(&(*out)[i])
 		}
 	}
+	if in.DefaultAction != nil {
+		in, out := &in.DefaultAction, &out.DefaultAction
+		*out = new(AMTDAction)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.NotificationSinks != nil {
+		in, out := &in.NotificationSinks, &out.NotificationSinks
+		*out = make([]v1.LocalObjectReference, len(*in))
+		copy(*out, *in)
+	}
+	if in.Rotation != nil {
+		in, out := &in.Rotation, &out.Rotation
+		*out = new(Rotation)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Disruption != nil {
+		in, out := &in.Disruption, &out.Disruption
+		*out = new(DisruptionLimits)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdaptiveMovingTargetDefenseSpec.
@@ -157,6 +184,38 @@ func (in *AdaptiveMovingTargetDefenseSpec) DeepCopy() *AdaptiveMovingTargetDefen
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *AdaptiveMovingTargetDefenseStatus) DeepCopyInto(out *AdaptiveMovingTargetDefenseStatus) {
 	*out = *in
+	if in.ManagedPods != nil {
+		in, out := &in.ManagedPods, &out.ManagedPods
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.LastReconcileTime != nil {
+		in, out := &in.LastReconcileTime, &out.LastReconcileTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Strategies != nil {
+		in, out := &in.Strategies, &out.Strategies
+		*out = make([]StrategyStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.LastDefaultActionTime != nil {
+		in, out := &in.LastDefaultActionTime, &out.LastDefaultActionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.Rotation != nil {
+		in, out := &in.Rotation, &out.Rotation
+		*out = new(RotationStatus)
+		(*in).DeepCopyInto(*out)
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new AdaptiveMovingTargetDefenseStatus.
@@ -169,6 +228,22 @@ func (in *AdaptiveMovingTargetDefenseStatus) DeepCopy() *AdaptiveMovingTargetDef
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *CloudEventsSink) DeepCopyInto(out *CloudEventsSink) {
+	*out = *in
+	in.HTTPEndpoint.DeepCopyInto(&out.HTTPEndpoint)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new CloudEventsSink.
+func (in *CloudEventsSink) DeepCopy() *CloudEventsSink {
+	if in == nil {
+		return nil
+	}
+	out := new(CloudEventsSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *CustomAction) DeepCopyInto(out *CustomAction) {
 	*out = *in
@@ -301,6 +376,21 @@ func (in *Debugger) DeepCopy() *Debugger {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *DeleteAction) DeepCopyInto(out *DeleteAction) {
 	*out = *in
+	if in.GracePeriodSeconds != nil {
+		in, out := &in.GracePeriodSeconds, &out.GracePeriodSeconds
+		*out = new(int64)
+		**out = **in
+	}
+	if in.ReplacementTimeout != nil {
+		in, out := &in.ReplacementTimeout, &out.ReplacementTimeout
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.ForceAfter != nil {
+		in, out := &in.ForceAfter, &out.ForceAfter
+		*out = new(metav1.Duration)
+		**out = **in
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DeleteAction.
@@ -328,9 +418,234 @@ func (in *DisableAction) DeepCopy() *DisableAction {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *DisruptionLimits) DeepCopyInto(out *DisruptionLimits) {
+	*out = *in
+	if in.MaxConcurrentDeletions != nil {
+		in, out := &in.MaxConcurrentDeletions, &out.MaxConcurrentDeletions
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MaxConcurrentQuarantines != nil {
+		in, out := &in.MaxConcurrentQuarantines, &out.MaxConcurrentQuarantines
+		*out = new(int32)
+		**out = **in
+	}
+	if in.MinAvailable != nil {
+		in, out := &in.MinAvailable, &out.MinAvailable
+		*out = new(intstr.IntOrString)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new DisruptionLimits.
+func (in *DisruptionLimits) DeepCopy() *DisruptionLimits {
+	if in == nil {
+		return nil
+	}
+	out := new(DisruptionLimits)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *HTTPEndpoint) DeepCopyInto(out *HTTPEndpoint) {
+	*out = *in
+	if in.URLSecretRef != nil {
+		in, out := &in.URLSecretRef, &out.URLSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Headers != nil {
+		in, out := &in.Headers, &out.Headers
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new HTTPEndpoint.
+func (in *HTTPEndpoint) DeepCopy() *HTTPEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(HTTPEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NetworkEndpoint) DeepCopyInto(out *NetworkEndpoint) {
+	*out = *in
+	if in.Port != nil {
+		in, out := &in.Port, &out.Port
+		*out = new(int32)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NetworkEndpoint.
+func (in *NetworkEndpoint) DeepCopy() *NetworkEndpoint {
+	if in == nil {
+		return nil
+	}
+	out := new(NetworkEndpoint)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationRetry) DeepCopyInto(out *NotificationRetry) {
+	*out = *in
+	out.Backoff = in.Backoff
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationRetry.
+func (in *NotificationRetry) DeepCopy() *NotificationRetry {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationRetry)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationSink) DeepCopyInto(out *NotificationSink) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationSink.
+func (in *NotificationSink) DeepCopy() *NotificationSink {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NotificationSink) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationSinkList) DeepCopyInto(out *NotificationSinkList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]NotificationSink, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationSinkList.
+func (in *NotificationSinkList) DeepCopy() *NotificationSinkList {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationSinkList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *NotificationSinkList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *NotificationSinkSpec) DeepCopyInto(out *NotificationSinkSpec) {
+	*out = *in
+	if in.Webhook != nil {
+		in, out := &in.Webhook, &out.Webhook
+		*out = new(WebhookSink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Slack != nil {
+		in, out := &in.Slack, &out.Slack
+		*out = new(SlackSink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.CloudEvents != nil {
+		in, out := &in.CloudEvents, &out.CloudEvents
+		*out = new(CloudEventsSink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.SMTP != nil {
+		in, out := &in.SMTP, &out.SMTP
+		*out = new(SMTPSink)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Outcomes != nil {
+		in, out := &in.Outcomes, &out.Outcomes
+		*out = make([]ActionOutcome, len(*in))
+		copy(*out, *in)
+	}
+	if in.Retry != nil {
+		in, out := &in.Retry, &out.Retry
+		*out = new(NotificationRetry)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new NotificationSinkSpec.
+func (in *NotificationSinkSpec) DeepCopy() *NotificationSinkSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(NotificationSinkSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *PluginAction) DeepCopyInto(out *PluginAction) {
+	*out = *in
+	if in.Parameters != nil {
+		in, out := &in.Parameters, &out.Parameters
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new PluginAction.
+func (in *PluginAction) DeepCopy() *PluginAction {
+	if in == nil {
+		return nil
+	}
+	out := new(PluginAction)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *QuarantineAction) DeepCopyInto(out *QuarantineAction) {
 	*out = *in
+	if in.LoggingEndpoints != nil {
+		in, out := &in.LoggingEndpoints, &out.LoggingEndpoints
+		*out = make([]NetworkEndpoint, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new QuarantineAction.
@@ -360,6 +675,58 @@ func (in *ResponseStrategy) DeepCopy() *ResponseStrategy {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *Rotation) DeepCopyInto(out *Rotation) {
+	*out = *in
+	if in.Interval != nil {
+		in, out := &in.Interval, &out.Interval
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+	if in.Jitter != nil {
+		in, out := &in.Jitter, &out.Jitter
+		*out = new(metav1.Duration)
+		**out = **in
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new Rotation.
+func (in *Rotation) DeepCopy() *Rotation {
+	if in == nil {
+		return nil
+	}
+	out := new(Rotation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *RotationStatus) DeepCopyInto(out *RotationStatus) {
+	*out = *in
+	if in.LastScheduleTime != nil {
+		in, out := &in.LastScheduleTime, &out.LastScheduleTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastRotationTime != nil {
+		in, out := &in.LastRotationTime, &out.LastRotationTime
+		*out = (*in).DeepCopy()
+	}
+	if in.LastAuditTime != nil {
+		in, out := &in.LastAuditTime, &out.LastAuditTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new RotationStatus.
+func (in *RotationStatus) DeepCopy() *RotationStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(RotationStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *Rule) DeepCopyInto(out *Rule) {
 	*out = *in
@@ -375,13 +742,38 @@ func (in *Rule) DeepCopy() *Rule {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SMTPSink) DeepCopyInto(out *SMTPSink) {
+	*out = *in
+	if in.To != nil {
+		in, out := &in.To, &out.To
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.PasswordSecretRef != nil {
+		in, out := &in.PasswordSecretRef, &out.PasswordSecretRef
+		*out = new(v1.SecretKeySelector)
+		(*in).DeepCopyInto(*out)
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SMTPSink.
+func (in *SMTPSink) DeepCopy() *SMTPSink {
+	if in == nil {
+		return nil
+	}
+	out := new(SMTPSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecurityEvent) DeepCopyInto(out *SecurityEvent) {
 	*out = *in
 	out.TypeMeta = in.TypeMeta
 	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
 	in.Spec.DeepCopyInto(&out.Spec)
-	out.Status = in.Status
+	in.Status.DeepCopyInto(&out.Status)
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecurityEvent.
@@ -458,6 +850,20 @@ func (in *SecurityEventSpec) DeepCopy() *SecurityEventSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *SecurityEventStatus) DeepCopyInto(out *SecurityEventStatus) {
 	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]metav1.Condition, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Targets != nil {
+		in, out := &in.Targets, &out.Targets
+		*out = make([]TargetStatus, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
 }
 
 // DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SecurityEventStatus.
@@ -469,3 +875,92 @@ func (in *SecurityEventStatus) DeepCopy() *SecurityEventStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *SlackSink) DeepCopyInto(out *SlackSink) {
+	*out = *in
+	in.HTTPEndpoint.DeepCopyInto(&out.HTTPEndpoint)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new SlackSink.
+func (in *SlackSink) DeepCopy() *SlackSink {
+	if in == nil {
+		return nil
+	}
+	out := new(SlackSink)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *StrategyStatus) DeepCopyInto(out *StrategyStatus) {
+	*out = *in
+	out.Rule = in.Rule
+	if in.LastExecutionTime != nil {
+		in, out := &in.LastExecutionTime, &out.LastExecutionTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new StrategyStatus.
+func (in *StrategyStatus) DeepCopy() *StrategyStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(StrategyStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *TargetStatus) DeepCopyInto(out *TargetStatus) {
+	*out = *in
+	if in.Rule != nil {
+		in, out := &in.Rule, &out.Rule
+		*out = new(Rule)
+		**out = **in
+	}
+	if in.CompletedActions != nil {
+		in, out := &in.CompletedActions, &out.CompletedActions
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.StartTime != nil {
+		in, out := &in.StartTime, &out.StartTime
+		*out = (*in).DeepCopy()
+	}
+	if in.CompletionTime != nil {
+		in, out := &in.CompletionTime, &out.CompletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.IssuedTime != nil {
+		in, out := &in.IssuedTime, &out.IssuedTime
+		*out = (*in).DeepCopy()
+	}
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new TargetStatus.
+func (in *TargetStatus) DeepCopy() *TargetStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(TargetStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *WebhookSink) DeepCopyInto(out *WebhookSink) {
+	*out = *in
+	in.HTTPEndpoint.DeepCopyInto(&out.HTTPEndpoint)
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new WebhookSink.
+func (in *WebhookSink) DeepCopy() *WebhookSink {
+	if in == nil {
+		return nil
+	}
+	out := new(WebhookSink)
+	in.DeepCopyInto(out)
+	return out
+}
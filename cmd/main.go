@@ -20,6 +20,7 @@ package main
 import (
 	"flag"
 	"os"
+	"strings"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -36,6 +37,9 @@ import (
 
 	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
 	"github.com/r6security/phoenix/internal/controller"
+	"github.com/r6security/phoenix/internal/integration"
+	webhookv1beta1 "github.com/r6security/phoenix/internal/webhook/v1beta1"
+	"github.com/r6security/phoenix/pkg/rules"
 	//+kubebuilder:scaffold:imports
 
 	"sigs.k8s.io/controller-runtime/pkg/metrics/server"
@@ -57,11 +61,80 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var strategyResolution string
+	var integrationAddr string
+	var integrationTokenFile string
+	var integrationCertDir string
+	var enableFalcoIntegration bool
+	var falcoSource string
+	var enableKubeArmorIntegration bool
+	var kubeArmorMapping string
+	var kubeArmorRelayAddr string
+	var kubeArmorRelayFilter string
+	var kubeArmorRelayCA string
+	var enableAlertmanagerIntegration bool
+	var alertmanagerMapping string
+	var alertmanagerNamespaceLabel string
+	var alertmanagerPodLabel string
+	var enableCloudEventsIntegration bool
+	var audit bool
+	var enableWebhooks bool
+	var securityEventRuleFields string
+	var securityEventMaxTargets int
+	var securityEventCheckTargets bool
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&strategyResolution, "strategy-resolution", string(rules.HighestPriority),
+		"Decides which strategies are executed when several match a SecurityEvent: "+
+			"first-match, highest-priority, most-severe-action or run-all.")
+	flag.BoolVar(&audit, "audit", false,
+		"Run every AdaptiveMovingTargetDefense in audit mode: actions are planned and reported but not executed.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", false,
+		"Serve the validating admission webhooks on port 9443, the serving certificate is read from "+
+			"/tmp/k8s-webhook-server/serving-certs (see config/webhook and config/certmanager).")
+	flag.StringVar(&securityEventRuleFields, "securityevent-required-rule-fields",
+		strings.Join(webhookv1beta1.DefaultSecurityEventPolicy.RequiredRuleFields, ","),
+		"The rule fields (type, threatLevel, source) that the webhook and the CloudEvents integration require to be non-empty in SecurityEvents.")
+	flag.IntVar(&securityEventMaxTargets, "securityevent-max-targets", webhookv1beta1.DefaultSecurityEventPolicy.MaxTargets,
+		"The maximum number of targets the webhook admits in a SecurityEvent. Use 0 for no limit.")
+	flag.BoolVar(&securityEventCheckTargets, "securityevent-check-targets", false,
+		"Reject SecurityEvents whose target pods do not exist or are not managed by an AdaptiveMovingTargetDefense.")
+	flag.StringVar(&integrationAddr, "integration-bind-address", "0",
+		"The address the built-in Integration Backends bind to. Use 0 to disable them.")
+	flag.StringVar(&integrationTokenFile, "integration-token-file", "",
+		"Path of the file with the bearer token that requests to the Integration Backends must present, "+
+			"e.g. a key of a mounted Secret. Required unless client certificates are accepted.")
+	flag.StringVar(&integrationCertDir, "integration-cert-dir", "",
+		"Directory with the serving certificate (tls.crt, tls.key) of the Integration Backends, if set they serve HTTPS. "+
+			"If it contains a ca.crt, clients presenting a certificate signed by it need no bearer token.")
+	flag.BoolVar(&enableFalcoIntegration, "enable-falco-integration", false,
+		"Create SecurityEvents from the alerts that the http_output of Falco posts to "+integration.FalcoPath+".")
+	flag.StringVar(&falcoSource, "falco-source", integration.FalcoSource,
+		"The source of the SecurityEvents created from Falco alerts. If empty, the source of the alert is used.")
+	flag.BoolVar(&enableKubeArmorIntegration, "enable-kubearmor-integration", false,
+		"Create SecurityEvents from the KubeArmor alerts posted to "+integration.KubeArmorPath+".")
+	flag.StringVar(&kubeArmorMapping, "kubearmor-mapping", "",
+		"Path of the YAML file with the templates that map KubeArmor alerts onto the rule of SecurityEvents.")
+	flag.StringVar(&kubeArmorRelayAddr, "kubearmor-relay-address", "",
+		"Create SecurityEvents from the WatchAlerts gRPC stream of kubearmor-relay at this address, "+
+			"e.g. kubearmor.kubearmor.svc:32767. Empty disables the stream.")
+	flag.StringVar(&kubeArmorRelayFilter, "kubearmor-relay-filter", "all",
+		"The alerts kubearmor-relay streams: all, policy or system.")
+	flag.StringVar(&kubeArmorRelayCA, "kubearmor-relay-ca", "",
+		"Path of the CA certificate of kubearmor-relay, if set the stream uses TLS.")
+	flag.BoolVar(&enableAlertmanagerIntegration, "enable-alertmanager-integration", false,
+		"Create SecurityEvents from the alerts that the webhook receiver of Alertmanager posts to "+integration.AlertmanagerPath+".")
+	flag.StringVar(&alertmanagerMapping, "alertmanager-mapping", "",
+		"Path of the YAML file with the templates that map Alertmanager alerts onto the rule of SecurityEvents.")
+	flag.StringVar(&alertmanagerNamespaceLabel, "alertmanager-namespace-label", "namespace",
+		"The label of Alertmanager alerts that contains the namespace of the target pod.")
+	flag.StringVar(&alertmanagerPodLabel, "alertmanager-pod-label", "pod",
+		"The label of Alertmanager alerts that contains the name of the target pod.")
+	flag.BoolVar(&enableCloudEventsIntegration, "enable-cloudevents-integration", false,
+		"Create SecurityEvents from the "+integration.SecurityEventCloudEventType+" CloudEvents posted to "+integration.CloudEventsPath+".")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -70,6 +143,12 @@ func main() {
 
 	ctrl.SetLogger(zap.New(zap.UseFlagOptions(&opts)))
 
+	resolution, err := rules.ParseResolutionMode(strategyResolution)
+	if err != nil {
+		setupLog.Error(err, "invalid --strategy-resolution")
+		os.Exit(1)
+	}
+
 	mgr, err := ctrl.NewManager(ctrl.GetConfigOrDie(), ctrl.Options{
 		Scheme: scheme,
 		Metrics: server.Options{
@@ -100,8 +179,10 @@ func main() {
 	}
 
 	if err = (&controller.AdaptiveMovingTargetDefenseReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Audit:      audit,
+		Resolution: resolution,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "AdaptiveMovingTargetDefense")
 		os.Exit(1)
@@ -114,14 +195,100 @@ func main() {
 		os.Exit(1)
 	}
 	if err = (&controller.SecurityEventReconciler{
-		Client: mgr.GetClient(),
-		Scheme: mgr.GetScheme(),
+		Client:     mgr.GetClient(),
+		Scheme:     mgr.GetScheme(),
+		Resolution: resolution,
+		Audit:      audit,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, "unable to create controller", "controller", "SecurityEvent")
 		os.Exit(1)
 	}
+	requiredRuleFields, err := webhookv1beta1.ParseRuleFields(securityEventRuleFields)
+	if err != nil {
+		setupLog.Error(err, "invalid --securityevent-required-rule-fields")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		if err = webhookv1beta1.SetupAdaptiveMovingTargetDefenseWebhookWithManager(mgr, resolution); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "AdaptiveMovingTargetDefense")
+			os.Exit(1)
+		}
+		if err = webhookv1beta1.SetupSecurityEventWebhookWithManager(mgr, webhookv1beta1.SecurityEventPolicy{
+			RequiredRuleFields: requiredRuleFields,
+			MaxTargets:         securityEventMaxTargets,
+			CheckTargets:       securityEventCheckTargets,
+		}); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "SecurityEvent")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
+	var kubeArmorHandler *integration.KubeArmorHandler
+	if enableKubeArmorIntegration || kubeArmorRelayAddr != "" {
+		mapping := integration.DefaultKubeArmorMapping
+		if kubeArmorMapping != "" {
+			if mapping, err = integration.LoadRuleMapping(kubeArmorMapping, mapping); err != nil {
+				setupLog.Error(err, "unable to load the KubeArmor mapping")
+				os.Exit(1)
+			}
+		}
+		if kubeArmorHandler, err = integration.NewKubeArmorHandler(mgr.GetClient(), mapping); err != nil {
+			setupLog.Error(err, "invalid KubeArmor mapping")
+			os.Exit(1)
+		}
+	}
+	if kubeArmorRelayAddr != "" {
+		if err := mgr.Add(&integration.KubeArmorRelayWatcher{
+			Handler: kubeArmorHandler,
+			Address: kubeArmorRelayAddr,
+			Filter:  kubeArmorRelayFilter,
+			CAFile:  kubeArmorRelayCA,
+		}); err != nil {
+			setupLog.Error(err, "unable to set up the KubeArmor relay watcher")
+			os.Exit(1)
+		}
+	}
+
+	if integrationAddr != "0" {
+		if integrationTokenFile == "" && integrationCertDir == "" {
+			setupLog.Error(nil, "the Integration Backends require --integration-token-file or --integration-cert-dir with a client CA")
+			os.Exit(1)
+		}
+		integrationServer := integration.NewServer(integrationAddr, integrationTokenFile, integrationCertDir)
+		if enableFalcoIntegration {
+			integrationServer.Handle(integration.FalcoPath, &integration.FalcoHandler{
+				Client: mgr.GetClient(),
+				Source: falcoSource,
+			})
+		}
+		if enableKubeArmorIntegration {
+			integrationServer.Handle(integration.KubeArmorPath, kubeArmorHandler)
+		}
+		if enableAlertmanagerIntegration {
+			mapping := integration.DefaultAlertmanagerMapping
+			if alertmanagerMapping != "" {
+				if mapping, err = integration.LoadRuleMapping(alertmanagerMapping, mapping); err != nil {
+					setupLog.Error(err, "unable to load the Alertmanager mapping")
+					os.Exit(1)
+				}
+			}
+			handler, err := integration.NewAlertmanagerHandler(mgr.GetClient(), mapping, alertmanagerNamespaceLabel, alertmanagerPodLabel)
+			if err != nil {
+				setupLog.Error(err, "invalid Alertmanager mapping")
+				os.Exit(1)
+			}
+			integrationServer.Handle(integration.AlertmanagerPath, handler)
+		}
+		if enableCloudEventsIntegration {
+			integrationServer.Handle(integration.CloudEventsPath, &integration.CloudEventsHandler{Client: mgr.GetClient(), RequiredRuleFields: requiredRuleFields})
+		}
+		if err := mgr.Add(integrationServer); err != nil {
+			setupLog.Error(err, "unable to set up Integration Backends")
+			os.Exit(1)
+		}
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
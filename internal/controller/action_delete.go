@@ -0,0 +1,277 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+const (
+	// REPLACEMENT_TIMEOUT is how long the delete action waits for the replacement of the pod by default
+	REPLACEMENT_TIMEOUT = 5 * time.Minute
+	// DELETE_CHECK_INTERVAL is how often the progress of a delete action that waits is checked
+	DELETE_CHECK_INTERVAL = 5 * time.Second
+	// DEFAULT_GRACE_PERIOD is the terminationGracePeriodSeconds of pods that do not set it
+	DEFAULT_GRACE_PERIOD = 30 * time.Second
+)
+
+// ReplacementInfo is stored in the AMTD_REPLACEMENT annotation of a pod that was released from
+// its owner, so that the delete action can find the replacement of the pod
+type ReplacementInfo struct {
+	ReleasedAt string                `json:"released-at"`
+	Owner      metav1.OwnerReference `json:"owner"`
+	Labels     map[string]string     `json:"labels"`
+}
+
+//+kubebuilder:rbac:groups=core,resources=pods/eviction,verbs=create
+
+// deleteAction evicts the target pod so that its owner can replace it with a fresh one.
+// The eviction honours the PodDisruptionBudgets of the pod and the disruption limits of the AMTD,
+// when they do not allow it the action is deferred.
+type deleteAction struct {
+	client.Client
+	// APIReader reads the pods bypassing the cache when the disruption limits are checked
+	APIReader client.Reader
+}
+
+func (a *deleteAction) Validate(spec amtdv1beta1.AMTDAction) error {
+	return nil
+}
+
+func (a *deleteAction) Execute(ctx context.Context, target *actions.Target) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	pod := target.Pod
+	spec := target.Spec.Delete
+	if spec == nil {
+		spec = &amtdv1beta1.DeleteAction{}
+	}
+
+	if pod.DeletionTimestamp != nil {
+		// the pod is already terminating
+		return a.forceDelete(ctx, target, spec)
+	}
+
+	replaced := false
+	if spec.WaitForReplacement {
+		done, ready, err := a.waitForReplacement(ctx, target, spec)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if !done {
+			return ctrl.Result{RequeueAfter: DELETE_CHECK_INTERVAL}, nil
+		}
+		replaced = ready
+	}
+	if err := checkDisruption(ctx, a.APIReader, target.AMTD, pod, actions.Delete); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	// Success for this delete is either:
+	// 1. the eviction or the deletion is successful without error
+	// 2. the resource already doesn't exist so delete can't take action
+	var err error
+	if spec.Mode == amtdv1beta1.DeleteDirect || replaced {
+		// a released pod is not counted by the PodDisruptionBudgets anymore, its ready replacement
+		// is. Without a ready replacement the eviction keeps the budgets in charge.
+		err = a.Client.Delete(ctx, pod, &client.DeleteOptions{GracePeriodSeconds: spec.GracePeriodSeconds})
+	} else {
+		eviction := &policyv1.Eviction{
+			ObjectMeta:    metav1.ObjectMeta{Namespace: pod.Namespace, Name: pod.Name},
+			DeleteOptions: &metav1.DeleteOptions{GracePeriodSeconds: spec.GracePeriodSeconds},
+		}
+		err = a.Client.SubResource("eviction").Create(ctx, pod, eviction)
+		if errors.IsTooManyRequests(err) {
+			// a PodDisruptionBudget does not allow the eviction now
+			return ctrl.Result{}, deferred(err.Error())
+		}
+	}
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, fmt.Sprintf(`Failed to delete pod "%s"`, pod.Name))
+		return ctrl.Result{}, err
+	}
+
+	log.Info(fmt.Sprintf(`Pod: "%s" was sucessfully deleted with ACTION: delete`, pod.Name))
+	target.Issued = err == nil
+	if spec.ForceAfter != nil && err == nil {
+		// come back when the pod should be gone to force it if it is stuck
+		return ctrl.Result{RequeueAfter: gracePeriod(pod, spec) + spec.ForceAfter.Duration}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+func (a *deleteAction) Revert(ctx context.Context, target *actions.Target) error {
+	return actions.ErrNotRevertible
+}
+
+// forceDelete deletes the terminating pod with zero grace period if it is stuck for longer than
+// ForceAfter, the action is in progress until then
+func (a *deleteAction) forceDelete(ctx context.Context, target *actions.Target, spec *amtdv1beta1.DeleteAction) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	pod := target.Pod
+
+	if spec.ForceAfter == nil {
+		return ctrl.Result{}, nil
+	}
+	// the deletion timestamp of a pod is the end of its grace period
+	if remaining := time.Until(pod.DeletionTimestamp.Add(spec.ForceAfter.Duration)); remaining > 0 {
+		return ctrl.Result{RequeueAfter: remaining}, nil
+	}
+
+	log.Info(fmt.Sprintf(`Pod "%s" is stuck terminating since %s, force deleting it`, pod.Name, pod.DeletionTimestamp.UTC().Format(time.RFC3339)))
+	err := a.Client.Delete(ctx, pod, client.GracePeriodSeconds(0))
+	if err != nil && !errors.IsNotFound(err) {
+		log.Error(err, fmt.Sprintf(`Failed to force delete pod "%s"`, pod.Name))
+		return ctrl.Result{}, err
+	}
+	target.Issued = err == nil
+	return ctrl.Result{}, nil
+}
+
+// waitForReplacement releases the pod from its owner so that the owner starts a replacement, and
+// reports whether the pod can be deleted: the replacement is ready, the timeout expired or the pod
+// cannot be replaced in advance. The second result reports whether a ready replacement was found.
+func (a *deleteAction) waitForReplacement(ctx context.Context, target *actions.Target, spec *amtdv1beta1.DeleteAction) (bool, bool, error) {
+	log := log.FromContext(ctx)
+	pod := target.Pod
+
+	encoded, found := pod.ObjectMeta.Annotations[AMTD_REPLACEMENT]
+	if !found {
+		released, err := a.releaseFromOwner(ctx, target)
+		if err != nil {
+			return false, false, err
+		}
+		if !released {
+			log.Info(fmt.Sprintf(`Pod "%s" cannot be replaced before it is deleted`, pod.Name))
+		}
+		return !released, false, nil
+	}
+
+	var replacementInfo ReplacementInfo
+	if err := json.Unmarshal([]byte(encoded), &replacementInfo); err != nil {
+		return false, false, fmt.Errorf(`invalid %s annotation on pod "%s": %w`, AMTD_REPLACEMENT, pod.Name, err)
+	}
+	releasedAt, err := time.Parse(time.RFC3339, replacementInfo.ReleasedAt)
+	if err != nil {
+		return false, false, fmt.Errorf(`invalid %s annotation on pod "%s": %w`, AMTD_REPLACEMENT, pod.Name, err)
+	}
+
+	timeout := REPLACEMENT_TIMEOUT
+	if spec.ReplacementTimeout != nil {
+		timeout = spec.ReplacementTimeout.Duration
+	}
+	if time.Since(releasedAt) >= timeout {
+		log.Info(fmt.Sprintf(`No replacement of pod "%s" is ready after %s, deleting it anyway`, pod.Name, timeout))
+		return true, false, nil
+	}
+
+	podList := &corev1.PodList{}
+	if err := a.Client.List(ctx, podList, client.InNamespace(pod.Namespace)); err != nil {
+		return false, false, err
+	}
+	for i := range podList.Items {
+		replacement := &podList.Items[i]
+		owner := metav1.GetControllerOfNoCopy(replacement)
+		if owner == nil || owner.UID != replacementInfo.Owner.UID || replacement.DeletionTimestamp != nil {
+			continue
+		}
+		if !replacement.CreationTimestamp.Time.Before(releasedAt) && podReady(replacement) {
+			log.Info(fmt.Sprintf(`Pod "%s" is replaced by pod "%s"`, pod.Name, replacement.Name))
+			return true, true, nil
+		}
+	}
+	return false, false, nil
+}
+
+// releaseFromOwner removes the labels that the controller of the pod selects it by, so that the
+// controller releases the pod and starts a replacement. Labels that Services or the AMTD select the
+// pod by are kept, so the pod keeps serving and stays managed. It reports false if the pod has no
+// controller that would replace it or there is no such label (e.g. the Service selects the pods by
+// the same labels as the ReplicaSet).
+func (a *deleteAction) releaseFromOwner(ctx context.Context, target *actions.Target) (bool, error) {
+	log := log.FromContext(ctx)
+	pod := target.Pod
+
+	owner, ownerSelector, err := replaceableOwner(ctx, a.Client, pod)
+	if err != nil || owner == nil {
+		return false, err
+	}
+
+	keptLabels, err := serviceLabels(ctx, a.Client, pod)
+	if err != nil {
+		return false, err
+	}
+	if target.AMTD != nil {
+		for key, value := range target.AMTD.Spec.PodSelector {
+			keptLabels[key] = value
+		}
+	}
+	removedLabels := replacementLabels(pod, ownerSelector, keptLabels)
+	if len(removedLabels) == 0 {
+		return false, nil
+	}
+
+	replacementInfoEncoded, err := json.Marshal(ReplacementInfo{
+		ReleasedAt: time.Now().UTC().Format(time.RFC3339),
+		Owner:      *owner,
+		Labels:     removedLabels,
+	})
+	if err != nil {
+		log.Error(err, fmt.Sprintf(`replacementInfo json encoding does not work: %s`, err.Error()))
+		return false, err
+	}
+	for key := range removedLabels {
+		delete(pod.ObjectMeta.Labels, key)
+	}
+	if pod.ObjectMeta.Annotations == nil {
+		pod.ObjectMeta.Annotations = map[string]string{}
+	}
+	pod.ObjectMeta.Annotations[AMTD_REPLACEMENT] = string(replacementInfoEncoded)
+
+	if err := a.Client.Update(ctx, pod); err != nil {
+		log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
+		return false, err
+	}
+
+	log.Info(fmt.Sprintf(`Pod "%s" was released from %s "%s", labels removed: %v`, pod.Name, owner.Kind, owner.Name, removedLabels))
+	return true, nil
+}
+
+// gracePeriod returns the grace period the pod is deleted with
+func gracePeriod(pod *corev1.Pod, spec *amtdv1beta1.DeleteAction) time.Duration {
+	switch {
+	case spec.GracePeriodSeconds != nil:
+		return time.Duration(*spec.GracePeriodSeconds) * time.Second
+	case pod.Spec.TerminationGracePeriodSeconds != nil:
+		return time.Duration(*pod.Spec.TerminationGracePeriodSeconds) * time.Second
+	}
+	return DEFAULT_GRACE_PERIOD
+}
@@ -0,0 +1,173 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+func TestDeleteWaitsForReplacement(t *testing.T) {
+	ctx := context.Background()
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo-5d8f", UID: "rs"},
+		Spec: appsv1.ReplicaSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "demo", "pod-template-hash": "5d8f"}},
+		},
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "demo"}},
+	}
+	ownedBy := func(pod *corev1.Pod) *corev1.Pod {
+		pod.Labels["pod-template-hash"] = "5d8f"
+		pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: replicaSet.Name, UID: replicaSet.UID, Controller: ptr.To(true)}}
+		return pod
+	}
+	pod := ownedBy(newTestPod("a", true, nil))
+	c := newTestClient(t, replicaSet, service, pod)
+
+	action := &deleteAction{Client: c, APIReader: c}
+	target := &actions.Target{
+		Pod:  pod,
+		AMTD: &amtdv1beta1.AdaptiveMovingTargetDefense{Spec: amtdv1beta1.AdaptiveMovingTargetDefenseSpec{PodSelector: map[string]string{"app": "demo"}}},
+		Spec: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{WaitForReplacement: true}},
+	}
+
+	// the pod is released from the ReplicaSet but keeps serving
+	result, err := action.Execute(ctx, target)
+	if err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("expected the action to be in progress, got %v, %v", result, err)
+	}
+	released := &corev1.Pod{}
+	if err := c.Get(ctx, types.NamespacedName{Namespace: "default", Name: "a"}, released); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := released.Labels["pod-template-hash"]; found || released.Labels["app"] != "demo" {
+		t.Errorf("expected only the ReplicaSet label to be removed, got %v", released.Labels)
+	}
+	if _, found := released.Annotations[AMTD_REPLACEMENT]; !found {
+		t.Errorf("expected the %s annotation", AMTD_REPLACEMENT)
+	}
+
+	// a replacement that is not ready yet
+	replacement := ownedBy(newTestPod("b", false, nil))
+	replacement.CreationTimestamp = metav1.NewTime(time.Now().Add(time.Minute))
+	if err := c.Create(ctx, replacement); err != nil {
+		t.Fatal(err)
+	}
+	target.Pod = released
+	if result, err := action.Execute(ctx, target); err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("expected the action to wait for the replacement, got %v, %v", result, err)
+	}
+
+	// the replacement is ready, the pod is deleted
+	replacement.Status.Conditions[0].Status = corev1.ConditionTrue
+	if err := c.Status().Update(ctx, replacement); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := action.Execute(ctx, target); err != nil || result.RequeueAfter != 0 {
+		t.Fatalf("expected the pod to be deleted, got %v, %v", result, err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the pod to be deleted, got %v", err)
+	}
+}
+
+func TestDeleteEvictsWithoutReadyReplacement(t *testing.T) {
+	ctx := context.Background()
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo-5d8f", UID: "rs"},
+		Spec: appsv1.ReplicaSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "demo", "pod-template-hash": "5d8f"}},
+		},
+	}
+	pod := newTestPod("a", true, nil)
+	pod.Labels["pod-template-hash"] = "5d8f"
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: replicaSet.Name, UID: replicaSet.UID, Controller: ptr.To(true)}}
+
+	// a PodDisruptionBudget rejects every eviction
+	deletes := 0
+	c := interceptor.NewClient(newTestClient(t, replicaSet, pod).(client.WithWatch), interceptor.Funcs{
+		SubResourceCreate: func(ctx context.Context, c client.Client, subResourceName string, obj client.Object, subResource client.Object, opts ...client.SubResourceCreateOption) error {
+			return errors.NewTooManyRequests("Cannot evict pod as it would violate the pod's disruption budget.", 0)
+		},
+		Delete: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.DeleteOption) error {
+			deletes++
+			return c.Delete(ctx, obj, opts...)
+		},
+	})
+
+	action := &deleteAction{Client: c, APIReader: c}
+	target := &actions.Target{
+		Pod:  pod,
+		AMTD: &amtdv1beta1.AdaptiveMovingTargetDefense{Spec: amtdv1beta1.AdaptiveMovingTargetDefenseSpec{PodSelector: map[string]string{"app": "demo"}}},
+		Spec: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{WaitForReplacement: true, ReplacementTimeout: &metav1.Duration{}}},
+	}
+	if result, err := action.Execute(ctx, target); err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("expected the pod to be released, got %v, %v", result, err)
+	}
+
+	// the replacement timed out without a ready replacement, the released pod is still evicted
+	_, err := action.Execute(ctx, target)
+	if _, ok := actions.IsDeferred(err); !ok {
+		t.Errorf("expected the eviction to be deferred by the PodDisruptionBudget, got %v", err)
+	}
+	if deletes != 0 || target.Issued {
+		t.Errorf("expected the pod not to be deleted directly, got %d deletes", deletes)
+	}
+}
+
+func TestDirectDeleteHonoursPodDisruptionBudgets(t *testing.T) {
+	ctx := context.Background()
+	pod := newTestPod("a", true, nil)
+	budget := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "demo"}}},
+		Status:     policyv1.PodDisruptionBudgetStatus{DisruptionsAllowed: 0},
+	}
+	c := newTestClient(t, pod, budget)
+
+	action := &deleteAction{Client: c, APIReader: c}
+	target := &actions.Target{
+		Pod:  pod,
+		AMTD: newTestAMTD("demo"),
+		Spec: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{Mode: amtdv1beta1.DeleteDirect}},
+	}
+	_, err := action.Execute(ctx, target)
+	if _, deferred := actions.IsDeferred(err); !deferred {
+		t.Fatalf("expected the deletion to be deferred by the PodDisruptionBudget, got %v", err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), &corev1.Pod{}); err != nil {
+		t.Errorf("expected the pod to be kept, got %v", err)
+	}
+}
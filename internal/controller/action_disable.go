@@ -0,0 +1,251 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+// DisableInfo is stored in the AMTD_DISABLED annotation of a disabled pod so that
+// the action can be reverted
+type DisableInfo struct {
+	DisabledAt    string            `json:"disabled-at"`
+	SecurityEvent string            `json:"security-event,omitempty"`
+	Labels        map[string]string `json:"labels"`
+}
+
+//+kubebuilder:rbac:groups=core,resources=services,verbs=get;list;watch
+
+// disableAction takes the target pod out of service while keeping it running for
+// forensics: labels that Services select the pod by are removed (so the pod is
+// removed from their endpoints), except the ones of the AMTD podSelector, and, if the pod declares the AMTD_READINESS_GATE
+// readiness gate, the pod is reported as not ready. A pod without the readiness gate that a Service
+// selects only by labels of the podSelector cannot be disabled, the action fails then.
+type disableAction struct {
+	client.Client
+}
+
+func (a *disableAction) Validate(spec amtdv1beta1.AMTDAction) error {
+	return nil
+}
+
+func (a *disableAction) Execute(ctx context.Context, target *actions.Target) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	pod := target.Pod
+
+	if _, found := pod.ObjectMeta.Annotations[AMTD_DISABLED]; found {
+		log.Info(fmt.Sprintf(`Pod "%s" is already disabled`, pod.Name))
+		return ctrl.Result{}, nil
+	}
+
+	// Collect labels that Services use to select the pod
+	removedLabels, err := serviceLabels(ctx, a.Client, pod)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	// the pod stays managed by the AMTD
+	if target.AMTD != nil {
+		for key := range target.AMTD.Spec.PodSelector {
+			delete(removedLabels, key)
+		}
+	}
+	if !hasPodReadinessGate(pod) {
+		services, err := servingServices(ctx, a.Client, pod, removedLabels)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if len(services) > 0 {
+			// retrying does not help, the podSelector or the pod has to be changed
+			return ctrl.Result{}, actions.InvalidSpec(`pod "%s" cannot be disabled: Services %v select it only by labels of the podSelector and it does not declare the %s readiness gate`, pod.Name, services, AMTD_READINESS_GATE)
+		}
+	}
+
+	disableInfo := DisableInfo{
+		DisabledAt: time.Now().UTC().Format(time.RFC3339),
+		Labels:     removedLabels,
+	}
+	if target.SecurityEvent != nil {
+		disableInfo.SecurityEvent = target.SecurityEvent.Name
+	}
+	disableInfoEncoded, err := json.Marshal(disableInfo)
+	if err != nil {
+		log.Error(err, fmt.Sprintf(`disableInfo json encoding does not work: %s`, err.Error()))
+		return ctrl.Result{}, err
+	}
+
+	for key := range removedLabels {
+		delete(pod.ObjectMeta.Labels, key)
+	}
+	if pod.ObjectMeta.Annotations == nil {
+		pod.ObjectMeta.Annotations = map[string]string{}
+	}
+	pod.ObjectMeta.Annotations[AMTD_DISABLED] = string(disableInfoEncoded)
+
+	if err := a.Client.Update(ctx, pod); err != nil {
+		log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
+		return ctrl.Result{}, err
+	}
+
+	if err := setPodReadinessGate(ctx, a.Client, pod, corev1.ConditionFalse, "DisabledByAMTD"); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	log.Info(fmt.Sprintf(`Pod "%s" was disabled, labels removed: %v`, pod.Name, removedLabels))
+	return ctrl.Result{}, nil
+}
+
+func (a *disableAction) Revert(ctx context.Context, target *actions.Target) error {
+	log := log.FromContext(ctx)
+	pod := target.Pod
+
+	if _, found := pod.ObjectMeta.Annotations[AMTD_DISABLED]; !found {
+		return fmt.Errorf(`pod "%s" is not disabled`, pod.Name)
+	}
+
+	var disableInfo DisableInfo
+	if err := json.Unmarshal([]byte(pod.ObjectMeta.Annotations[AMTD_DISABLED]), &disableInfo); err != nil {
+		return fmt.Errorf(`invalid %s annotation on pod "%s": %w`, AMTD_DISABLED, pod.Name, err)
+	}
+
+	if pod.ObjectMeta.Labels == nil {
+		pod.ObjectMeta.Labels = map[string]string{}
+	}
+	for key, value := range disableInfo.Labels {
+		pod.ObjectMeta.Labels[key] = value
+	}
+	delete(pod.ObjectMeta.Annotations, AMTD_DISABLED)
+
+	if err := a.Client.Update(ctx, pod); err != nil {
+		log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
+		return err
+	}
+
+	if err := setPodReadinessGate(ctx, a.Client, pod, corev1.ConditionTrue, "EnabledByAMTD"); err != nil {
+		return err
+	}
+
+	log.Info(fmt.Sprintf(`Pod "%s" was enabled again`, pod.Name))
+	return nil
+}
+
+// serviceLabels returns the labels of the pod that Services use to select it
+func serviceLabels(ctx context.Context, c client.Client, pod *corev1.Pod) (map[string]string, error) {
+	serviceList := &corev1.ServiceList{}
+	if err := c.List(ctx, serviceList, client.InNamespace(pod.Namespace)); err != nil {
+		log.FromContext(ctx).Error(err, fmt.Sprintf(`Failed to retrieve services: "%s"`, err.Error()))
+		return nil, err
+	}
+
+	selectedBy := map[string]string{}
+	for _, service := range serviceList.Items {
+		// Services without selector have manually managed endpoints
+		if len(service.Spec.Selector) == 0 {
+			continue
+		}
+		if !labels.SelectorFromSet(service.Spec.Selector).Matches(labels.Set(pod.ObjectMeta.Labels)) {
+			continue
+		}
+		for key := range service.Spec.Selector {
+			selectedBy[key] = pod.ObjectMeta.Labels[key]
+		}
+	}
+	return selectedBy, nil
+}
+
+// servingServices returns the names of the Services that still select the pod when the removed labels are gone
+func servingServices(ctx context.Context, c client.Client, pod *corev1.Pod, removed map[string]string) ([]string, error) {
+	serviceList := &corev1.ServiceList{}
+	if err := c.List(ctx, serviceList, client.InNamespace(pod.Namespace)); err != nil {
+		log.FromContext(ctx).Error(err, fmt.Sprintf(`Failed to retrieve services: "%s"`, err.Error()))
+		return nil, err
+	}
+
+	remaining := labels.Set{}
+	for key, value := range pod.ObjectMeta.Labels {
+		if _, found := removed[key]; !found {
+			remaining[key] = value
+		}
+	}
+	services := []string{}
+	for _, service := range serviceList.Items {
+		if len(service.Spec.Selector) > 0 && labels.SelectorFromSet(service.Spec.Selector).Matches(remaining) {
+			services = append(services, service.Name)
+		}
+	}
+	return services, nil
+}
+
+// hasPodReadinessGate reports whether the pod declares the AMTD_READINESS_GATE readiness gate
+func hasPodReadinessGate(pod *corev1.Pod) bool {
+	for _, gate := range pod.Spec.ReadinessGates {
+		if gate.ConditionType == AMTD_READINESS_GATE {
+			return true
+		}
+	}
+	return false
+}
+
+// getPodReadinessGate returns the AMTD_READINESS_GATE condition of the pod, nil if it is not set yet
+func getPodReadinessGate(pod *corev1.Pod) *corev1.PodCondition {
+	for i := range pod.Status.Conditions {
+		if pod.Status.Conditions[i].Type == AMTD_READINESS_GATE {
+			return &pod.Status.Conditions[i]
+		}
+	}
+	return nil
+}
+
+// setPodReadinessGate sets the AMTD_READINESS_GATE condition of the pod if the pod
+// declares it as a readiness gate, otherwise it does nothing
+func setPodReadinessGate(ctx context.Context, c client.Client, pod *corev1.Pod, status corev1.ConditionStatus, reason string) error {
+	if !hasPodReadinessGate(pod) {
+		return nil
+	}
+
+	condition := corev1.PodCondition{
+		Type:               AMTD_READINESS_GATE,
+		Status:             status,
+		Reason:             reason,
+		LastTransitionTime: metav1.Now(),
+	}
+
+	if existing := getPodReadinessGate(pod); existing != nil {
+		*existing = condition
+	} else {
+		pod.Status.Conditions = append(pod.Status.Conditions, condition)
+	}
+
+	if err := c.Status().Update(ctx, pod); err != nil {
+		log.FromContext(ctx).Error(err, fmt.Sprintf(`Failed to update status of pod: "%s": %s`, pod.Name, err.Error()))
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,167 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+func TestDisableIsReversible(t *testing.T) {
+	ctx := context.Background()
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "demo", "tier": "web"}},
+	}
+	otherService := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "other"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "other"}},
+	}
+	pod := newTestPod("a", true, nil)
+	pod.Labels["tier"] = "web"
+	pod.Labels["version"] = "v1"
+	pod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: AMTD_READINESS_GATE}}
+	originalLabels := map[string]string{}
+	for key, value := range pod.Labels {
+		originalLabels[key] = value
+	}
+	c := newTestClient(t, service, otherService, pod)
+
+	action := &disableAction{Client: c}
+	target := &actions.Target{
+		Pod:           pod,
+		AMTD:          newTestAMTD("demo"),
+		SecurityEvent: newTestSecurityEvent("event", "exec", "a"),
+		Spec:          amtdv1beta1.AMTDAction{Disable: &amtdv1beta1.DisableAction{}},
+	}
+	if _, err := action.Execute(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pod leaves the Service but stays managed by the AMTD and is reported as not ready
+	disabled := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), disabled); err != nil {
+		t.Fatal(err)
+	}
+	expectedLabels := map[string]string{"app": "demo", "version": "v1"}
+	if !reflect.DeepEqual(disabled.Labels, expectedLabels) {
+		t.Errorf("expected labels %v, got %v", expectedLabels, disabled.Labels)
+	}
+	var disableInfo DisableInfo
+	if err := json.Unmarshal([]byte(disabled.Annotations[AMTD_DISABLED]), &disableInfo); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(disableInfo.Labels, map[string]string{"tier": "web"}) || disableInfo.SecurityEvent != "event" {
+		t.Errorf("unexpected disable record %+v", disableInfo)
+	}
+	if condition := getPodReadinessGate(disabled); condition == nil || condition.Status != corev1.ConditionFalse {
+		t.Errorf("expected the readiness gate to be False, got %+v", condition)
+	}
+
+	// disabling again does not change the record
+	if _, err := action.Execute(ctx, &actions.Target{Pod: disabled, AMTD: target.AMTD, Spec: target.Spec}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), disabled); err != nil {
+		t.Fatal(err)
+	}
+	if encoded, _ := json.Marshal(disableInfo); disabled.Annotations[AMTD_DISABLED] != string(encoded) {
+		t.Errorf("expected the disable record to be kept, got %s", disabled.Annotations[AMTD_DISABLED])
+	}
+
+	// the release restores the pod
+	if err := action.Revert(ctx, &actions.Target{Pod: disabled}); err != nil {
+		t.Fatal(err)
+	}
+	enabled := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), enabled); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(enabled.Labels, originalLabels) {
+		t.Errorf("expected labels %v, got %v", originalLabels, enabled.Labels)
+	}
+	if _, found := enabled.Annotations[AMTD_DISABLED]; found {
+		t.Errorf("expected the disable record to be removed")
+	}
+	if condition := getPodReadinessGate(enabled); condition == nil || condition.Status != corev1.ConditionTrue {
+		t.Errorf("expected the readiness gate to be True, got %+v", condition)
+	}
+	if err := action.Revert(ctx, &actions.Target{Pod: enabled}); err == nil {
+		t.Errorf("expected an error when reverting a pod that is not disabled")
+	}
+}
+
+func TestDisableWithoutReadinessGate(t *testing.T) {
+	ctx := context.Background()
+	pod := newTestPod("a", true, nil)
+	c := newTestClient(t, pod)
+
+	action := &disableAction{Client: c}
+	if _, err := action.Execute(ctx, &actions.Target{Pod: pod, AMTD: newTestAMTD("demo")}); err != nil {
+		t.Fatal(err)
+	}
+	disabled := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), disabled); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := disabled.Annotations[AMTD_DISABLED]; !found {
+		t.Errorf("expected the pod to be disabled")
+	}
+	if condition := getPodReadinessGate(disabled); condition != nil {
+		t.Errorf("expected no readiness gate condition on a pod that does not declare it, got %+v", condition)
+	}
+}
+
+func TestDisableFailsWhenServicesKeepThePod(t *testing.T) {
+	ctx := context.Background()
+	// the Service selects the pod only by the label of the podSelector
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "demo"}},
+	}
+	pod := newTestPod("a", true, nil)
+	c := newTestClient(t, service, pod)
+
+	action := &disableAction{Client: c}
+	_, err := action.Execute(ctx, &actions.Target{Pod: pod, AMTD: newTestAMTD("demo")})
+	if !actions.IsInvalidSpec(err) {
+		t.Fatalf("expected the action to fail without retry, got %v", err)
+	}
+	kept := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), kept); err != nil {
+		t.Fatal(err)
+	}
+	if _, found := kept.Annotations[AMTD_DISABLED]; found {
+		t.Errorf("expected the pod not to be recorded as disabled")
+	}
+
+	// the readiness gate takes the pod out of the Service
+	pod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: AMTD_READINESS_GATE}}
+	if _, err := action.Execute(ctx, &actions.Target{Pod: pod, AMTD: newTestAMTD("demo")}); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,168 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+const defaultEphemeralContainerName string = "amtd-debug-container"
+
+// debuggerAction attaches a debug container to the target pod
+type debuggerAction struct {
+	client.Client
+}
+
+func (a *debuggerAction) Validate(spec amtdv1beta1.AMTDAction) error {
+	if spec.Debugger == nil {
+		return actions.InvalidSpec("debugger action is not defined")
+	}
+	if spec.Debugger.Image == "" {
+		return actions.InvalidSpec("debugger image must not be empty")
+	}
+	return nil
+}
+
+func (a *debuggerAction) Execute(ctx context.Context, target *actions.Target) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	pod := target.Pod
+	debugger := target.Spec.Debugger
+
+	if len(pod.Spec.Containers) == 0 {
+		return ctrl.Result{}, fmt.Errorf("there is no container in the pod which can be used to attach the ephemeral container to")
+	}
+
+	name := debugger.Name
+	if name == "" {
+		name = defaultEphemeralContainerName
+	}
+
+	ec := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:  name,
+			Image: debugger.Image,
+			Stdin: debugger.Terminal,
+			TTY:   debugger.Terminal,
+		},
+		TargetContainerName: pod.Spec.Containers[0].Name,
+	}
+
+	attached, err := attachEphemeralContainer(ctx, a.Client, pod, ec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if attached {
+		log.Info("Successfully attached debug container", "containerName", name)
+	}
+	return ctrl.Result{}, nil
+}
+
+func (a *debuggerAction) Revert(ctx context.Context, target *actions.Target) error {
+	// ephemeral containers cannot be removed from a pod
+	return actions.ErrNotRevertible
+}
+
+// customAction attaches a user defined ephemeral container to the target pod
+type customAction struct {
+	client.Client
+}
+
+func (a *customAction) Validate(spec amtdv1beta1.AMTDAction) error {
+	if spec.CustomAction == nil {
+		return actions.InvalidSpec("custom action is not defined")
+	}
+	if spec.CustomAction.Image == "" {
+		return actions.InvalidSpec("custom action image must not be empty")
+	}
+	return nil
+}
+
+func (a *customAction) Execute(ctx context.Context, target *actions.Target) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	pod := target.Pod
+	custom := target.Spec.CustomAction
+
+	if len(pod.Spec.Containers) == 0 {
+		return ctrl.Result{}, fmt.Errorf("there is no container in the pod which can be used to attach the ephemeral container to")
+	}
+
+	name := custom.Name
+	if name == "" {
+		name = defaultEphemeralContainerName
+	}
+
+	targetContainerName := custom.TargetContainerName
+	if targetContainerName == "" {
+		targetContainerName = pod.Spec.Containers[0].Name
+	}
+
+	ec := corev1.EphemeralContainer{
+		EphemeralContainerCommon: corev1.EphemeralContainerCommon{
+			Name:  name,
+			Image: custom.Image,
+			Stdin: custom.Stdin,
+			TTY:   custom.TTY,
+		},
+		TargetContainerName: targetContainerName,
+	}
+
+	attached, err := attachEphemeralContainer(ctx, a.Client, pod, ec)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	if attached {
+		log.Info("Successfully attached custom action container", "containerName", name)
+	}
+	return ctrl.Result{}, nil
+}
+
+func (a *customAction) Revert(ctx context.Context, target *actions.Target) error {
+	// ephemeral containers cannot be removed from a pod
+	return actions.ErrNotRevertible
+}
+
+// attachEphemeralContainer adds ec to the pod unless an ephemeral container with
+// the same name already exists, in which case it returns false
+func attachEphemeralContainer(ctx context.Context, c client.Client, pod *corev1.Pod, ec corev1.EphemeralContainer) (bool, error) {
+	log := log.FromContext(ctx)
+
+	for _, existing := range pod.Spec.EphemeralContainers {
+		if existing.Name == ec.Name {
+			log.Info("Cannot attach ephemeral container because it is already exists", "containerName", ec.Name)
+			return false, nil
+		}
+	}
+
+	pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, ec)
+
+	err := c.SubResource("ephemeralcontainers").Update(ctx, pod)
+	if err != nil {
+		log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
+		return false, err
+	}
+	return true, nil
+}
@@ -0,0 +1,387 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	v1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+// QuarantineInfo is stored in the AMTD_QUARANTINE annotation of a quarantined pod
+// so that the pod can be released from quarantine
+type QuarantineInfo struct {
+	QuarantinedAt string `json:"quarantined-at"`
+	SecurityEvent string `json:"security-event,omitempty"`
+	NetworkPolicy string `json:"network-policy"`
+	// Labels are the labels of the pod before the quarantine
+	Labels map[string]string `json:"labels"`
+	// RemovedLabels are the labels removed so that Services and the owner do not select the pod
+	RemovedLabels []string `json:"removed-labels,omitempty"`
+	// Owner is the controller of the pod that was made to replace it
+	Owner *metav1.OwnerReference `json:"owner,omitempty"`
+}
+
+// The cluster DNS that quarantined pods may query when AllowDNS is set
+const (
+	DNS_NAMESPACE = "kube-system"
+	DNS_PORT      = 53
+)
+
+var DNS_POD_LABELS = map[string]string{"k8s-app": "kube-dns"}
+
+//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create;delete
+//+kubebuilder:rbac:groups=apps,resources=replicasets;statefulsets;daemonsets,verbs=get
+//+kubebuilder:rbac:groups=batch,resources=jobs,verbs=get
+
+// quarantineAction isolates the target pod with a NetworkPolicy selecting the AMTD_NETWORK_POLICY
+// label of the pod, which denies everything the options of the action do not allow. The labels that Services and the owner of the pod select it
+// by are removed (except the ones of the AMTD podSelector), so the pod leaves the Service endpoints
+// and its owner starts a replacement, while the pod keeps running for forensics. Services and owners
+// that select the pod only by labels of the podSelector are reported as warnings of the target.
+type quarantineAction struct {
+	client.Client
+	Scheme *runtime.Scheme
+	// APIReader reads the pods bypassing the cache when the disruption limits are checked
+	APIReader client.Reader
+}
+
+func (a *quarantineAction) Validate(spec amtdv1beta1.AMTDAction) error {
+	if spec.Quarantine == nil {
+		return nil
+	}
+	if namespace := spec.Quarantine.ForensicsNamespace; namespace != "" {
+		if errs := validation.IsDNS1123Label(namespace); len(errs) > 0 {
+			return actions.InvalidSpec(`invalid forensicsNamespace "%s": %s`, namespace, strings.Join(errs, ", "))
+		}
+	}
+	for _, endpoint := range spec.Quarantine.LoggingEndpoints {
+		if _, _, err := net.ParseCIDR(endpoint.CIDR); err != nil {
+			return actions.InvalidSpec(`invalid loggingEndpoints cidr "%s": %w`, endpoint.CIDR, err)
+		}
+	}
+	return nil
+}
+
+func (a *quarantineAction) Execute(ctx context.Context, target *actions.Target) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	pod := target.Pod
+	AMTD := target.AMTD
+
+	_, quarantined := pod.ObjectMeta.Annotations[AMTD_QUARANTINE]
+	if !quarantined {
+		if err := checkDisruption(ctx, a.APIReader, AMTD, pod, actions.Quarantine); err != nil {
+			return ctrl.Result{}, err
+		}
+	}
+
+	networkPolicyName := fmt.Sprintf("%s-%s-%s", pod.Namespace, pod.Name, "policy")
+
+	networkPolicy := &v1.NetworkPolicy{}
+	err := a.Client.Get(ctx, types.NamespacedName{
+		Namespace: pod.Namespace,
+		Name:      networkPolicyName,
+	}, networkPolicy)
+
+	if err != nil && errors.IsNotFound(err) {
+		networkPolicySpec, err := a.networkPolicySpec(ctx, pod, target.Spec.Quarantine, networkPolicyName)
+		if err != nil {
+			log.Error(err, fmt.Sprintf(`Failed to build the quarantine NetworkPolicy of pod "%s": %s`, pod.Name, err.Error()))
+			return ctrl.Result{}, err
+		}
+		networkPolicy := &v1.NetworkPolicy{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      networkPolicyName,
+				Namespace: pod.Namespace,
+			},
+			Spec: networkPolicySpec,
+		}
+
+		// Set the pod as the owner and controller for the NetworkPolicy, so that the NetworkPolicy
+		// is removed with the pod but deleting the AMTD does not lift the quarantine
+		err = ctrl.SetControllerReference(pod, networkPolicy, a.Scheme)
+		if err != nil {
+			log.Error(err, "Failed to set Pod as owner and controller reference on NetworkPolicy",
+				"Pod", pod.Name,
+				"NetworkPolicy", networkPolicy.Name,
+				"Namespace", networkPolicy.Namespace,
+			)
+		}
+
+		err = a.Client.Create(ctx, networkPolicy)
+		if err != nil {
+			log.Error(err, "Failed to create Networkpolicy in the cluster",
+				"NetworkPolicy", networkPolicy.Name,
+				"Namespace", networkPolicy.Namespace)
+			return ctrl.Result{}, err
+		}
+	} else if err != nil {
+		log.Error(err, "Failed to retrieve Networkpolicy",
+			"NetworkPolicy", networkPolicyName,
+			"Namespace", pod.Namespace)
+		return ctrl.Result{}, err
+	}
+
+	if quarantined {
+		return ctrl.Result{}, nil
+	}
+
+	// ---------------------------------------------------
+	// Collect the labels that Services and the owner select the pod by
+	// ---------------------------------------------------
+	removedLabels, err := serviceLabels(ctx, a.Client, pod)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	owner, ownerSelector, err := replaceableOwner(ctx, a.Client, pod)
+	if err != nil {
+		return ctrl.Result{}, err
+	}
+	ownerLabels := replacementLabels(pod, ownerSelector, AMTD.Spec.PodSelector)
+	for key, value := range ownerLabels {
+		removedLabels[key] = value
+	}
+	// the pod stays managed by the AMTD
+	for key := range AMTD.Spec.PodSelector {
+		delete(removedLabels, key)
+	}
+
+	// Selectors covered by the podSelector keep the pod in service, only the NetworkPolicy applies
+	// then: its traffic is dropped instead of drained
+	if !hasPodReadinessGate(pod) {
+		services, err := servingServices(ctx, a.Client, pod, removedLabels)
+		if err != nil {
+			return ctrl.Result{}, err
+		}
+		if len(services) > 0 {
+			target.Warnings = append(target.Warnings, fmt.Sprintf(`Services %v select the pod only by labels of the podSelector, its traffic is dropped by the NetworkPolicy instead`, services))
+		}
+	}
+	if owner != nil && len(ownerLabels) == 0 {
+		target.Warnings = append(target.Warnings, fmt.Sprintf(`%s "%s" selects the pod only by labels of the podSelector, it does not start a replacement`, owner.Kind, owner.Name))
+	}
+
+	// Record the original state of the pod so that it can be released later
+	quarantineInfo := QuarantineInfo{
+		QuarantinedAt: time.Now().UTC().Format(time.RFC3339),
+		NetworkPolicy: networkPolicyName,
+		Labels:        map[string]string{},
+		RemovedLabels: []string{},
+	}
+	if target.SecurityEvent != nil {
+		quarantineInfo.SecurityEvent = target.SecurityEvent.Name
+	}
+	if len(ownerLabels) > 0 {
+		quarantineInfo.Owner = owner
+	}
+	for key, value := range pod.ObjectMeta.Labels {
+		quarantineInfo.Labels[key] = value
+	}
+	for key := range removedLabels {
+		quarantineInfo.RemovedLabels = append(quarantineInfo.RemovedLabels, key)
+	}
+	sort.Strings(quarantineInfo.RemovedLabels)
+	quarantineInfoEncoded, err := json.Marshal(quarantineInfo)
+	if err != nil {
+		log.Error(err, fmt.Sprintf(`quarantineInfo json encoding does not work: %s`, err.Error()))
+		return ctrl.Result{}, err
+	}
+
+	// Relabel pod: remove the collected labels and add the one the NetworkPolicy selects
+	if pod.ObjectMeta.Labels == nil {
+		pod.ObjectMeta.Labels = map[string]string{}
+	}
+	for key := range removedLabels {
+		delete(pod.ObjectMeta.Labels, key)
+	}
+	pod.ObjectMeta.Labels[AMTD_NETWORK_POLICY] = networkPolicyName
+	if pod.ObjectMeta.Annotations == nil {
+		pod.ObjectMeta.Annotations = map[string]string{}
+	}
+	pod.ObjectMeta.Annotations[AMTD_QUARANTINE] = string(quarantineInfoEncoded)
+
+	err = a.Client.Update(ctx, pod)
+	if err != nil {
+		log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
+		return ctrl.Result{}, err
+	}
+
+	if err := setPodReadinessGate(ctx, a.Client, pod, corev1.ConditionFalse, "QuarantinedByAMTD"); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	if quarantineInfo.Owner != nil {
+		log.Info(fmt.Sprintf(`Pod %s was put in quarantine, %s "%s" replaces it, labels removed: %v`, pod.Name, owner.Kind, owner.Name, quarantineInfo.RemovedLabels))
+	} else {
+		log.Info(fmt.Sprintf(`Pod %s was put in quarantine, labels removed: %v`, pod.Name, quarantineInfo.RemovedLabels))
+	}
+	return ctrl.Result{}, nil
+}
+
+// networkPolicySpec builds the spec of the quarantine NetworkPolicy: it blocks the traffic of the
+// pod in the directions of the isolation, except what the options of the action allow
+func (a *quarantineAction) networkPolicySpec(ctx context.Context, pod *corev1.Pod, options *amtdv1beta1.QuarantineAction, networkPolicyName string) (v1.NetworkPolicySpec, error) {
+	spec := v1.NetworkPolicySpec{
+		PodSelector: metav1.LabelSelector{
+			MatchLabels: map[string]string{AMTD_NETWORK_POLICY: networkPolicyName},
+		},
+		Ingress: []v1.NetworkPolicyIngressRule{},
+		Egress:  []v1.NetworkPolicyEgressRule{},
+		PolicyTypes: []v1.PolicyType{
+			v1.PolicyTypeIngress,
+			v1.PolicyTypeEgress,
+		},
+	}
+	if options == nil {
+		return spec, nil
+	}
+
+	if options.TemplateNetworkPolicy != "" {
+		template := &v1.NetworkPolicy{}
+		if err := a.Client.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: options.TemplateNetworkPolicy}, template); err != nil {
+			return spec, fmt.Errorf(`failed to retrieve template NetworkPolicy "%s": %w`, options.TemplateNetworkPolicy, err)
+		}
+		spec.Ingress = append(spec.Ingress, template.Spec.Ingress...)
+		spec.Egress = append(spec.Egress, template.Spec.Egress...)
+	}
+
+	if options.ForensicsNamespace != "" {
+		forensics := v1.NetworkPolicyPeer{
+			NamespaceSelector: &metav1.LabelSelector{
+				MatchLabels: map[string]string{corev1.LabelMetadataName: options.ForensicsNamespace},
+			},
+		}
+		spec.Ingress = append(spec.Ingress, v1.NetworkPolicyIngressRule{From: []v1.NetworkPolicyPeer{forensics}})
+		spec.Egress = append(spec.Egress, v1.NetworkPolicyEgressRule{To: []v1.NetworkPolicyPeer{forensics}})
+	}
+
+	if options.AllowDNS {
+		udp, tcp := corev1.ProtocolUDP, corev1.ProtocolTCP
+		port := intstr.FromInt32(DNS_PORT)
+		spec.Egress = append(spec.Egress, v1.NetworkPolicyEgressRule{
+			To: []v1.NetworkPolicyPeer{{
+				NamespaceSelector: &metav1.LabelSelector{
+					MatchLabels: map[string]string{corev1.LabelMetadataName: DNS_NAMESPACE},
+				},
+				PodSelector: &metav1.LabelSelector{
+					MatchLabels: DNS_POD_LABELS,
+				},
+			}},
+			Ports: []v1.NetworkPolicyPort{
+				{Protocol: &udp, Port: &port},
+				{Protocol: &tcp, Port: &port},
+			},
+		})
+	}
+
+	for _, endpoint := range options.LoggingEndpoints {
+		protocol := endpoint.Protocol
+		if protocol == "" {
+			protocol = corev1.ProtocolTCP
+		}
+		rulePort := v1.NetworkPolicyPort{Protocol: &protocol}
+		if endpoint.Port != nil {
+			port := intstr.FromInt32(*endpoint.Port)
+			rulePort.Port = &port
+		}
+		spec.Egress = append(spec.Egress, v1.NetworkPolicyEgressRule{
+			To:    []v1.NetworkPolicyPeer{{IPBlock: &v1.IPBlock{CIDR: endpoint.CIDR}}},
+			Ports: []v1.NetworkPolicyPort{rulePort},
+		})
+	}
+
+	switch options.Isolation {
+	case amtdv1beta1.QuarantineIsolateIngress:
+		spec.PolicyTypes = []v1.PolicyType{v1.PolicyTypeIngress}
+		spec.Egress = nil
+	case amtdv1beta1.QuarantineIsolateEgress:
+		spec.PolicyTypes = []v1.PolicyType{v1.PolicyTypeEgress}
+		spec.Ingress = nil
+	}
+	return spec, nil
+}
+
+func (a *quarantineAction) Revert(ctx context.Context, target *actions.Target) error {
+	log := log.FromContext(ctx)
+	pod := target.Pod
+
+	if _, found := pod.ObjectMeta.Annotations[AMTD_QUARANTINE]; !found {
+		return fmt.Errorf(`pod "%s" has no quarantine record`, pod.Name)
+	}
+
+	var quarantineInfo QuarantineInfo
+	if err := json.Unmarshal([]byte(pod.ObjectMeta.Annotations[AMTD_QUARANTINE]), &quarantineInfo); err != nil {
+		return fmt.Errorf(`invalid %s annotation on pod "%s": %w`, AMTD_QUARANTINE, pod.Name, err)
+	}
+
+	// Restore the original labels, so that Services select the pod again and its owner adopts it
+	// (and scales back to its replica count)
+	delete(pod.ObjectMeta.Labels, AMTD_NETWORK_POLICY)
+	if pod.ObjectMeta.Labels == nil {
+		pod.ObjectMeta.Labels = map[string]string{}
+	}
+	for key, value := range quarantineInfo.Labels {
+		pod.ObjectMeta.Labels[key] = value
+	}
+	delete(pod.ObjectMeta.Annotations, AMTD_QUARANTINE)
+
+	if err := a.Client.Update(ctx, pod); err != nil {
+		log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
+		return err
+	}
+
+	if err := setPodReadinessGate(ctx, a.Client, pod, corev1.ConditionTrue, "ReleasedByAMTD"); err != nil {
+		return err
+	}
+
+	// Remove the per-pod NetworkPolicy
+	networkPolicy := &v1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      quarantineInfo.NetworkPolicy,
+			Namespace: pod.Namespace,
+		},
+	}
+	if err := a.Client.Delete(ctx, networkPolicy); err != nil && !errors.IsNotFound(err) {
+		log.Error(err, "Failed to delete Networkpolicy in the cluster",
+			"NetworkPolicy", networkPolicy.Name,
+			"Namespace", networkPolicy.Namespace)
+		return err
+	}
+
+	log.Info(fmt.Sprintf(`Pod %s was released from quarantine`, pod.Name))
+	return nil
+}
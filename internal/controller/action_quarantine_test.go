@@ -0,0 +1,203 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"strings"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+func TestQuarantineIsReversible(t *testing.T) {
+	ctx := context.Background()
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo-5d8f", UID: "rs"},
+		Spec: appsv1.ReplicaSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "demo", "pod-template-hash": "5d8f"}},
+		},
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "demo", "tier": "web"}},
+	}
+	pod := newTestPod("a", true, nil)
+	pod.Labels["tier"] = "web"
+	pod.Labels["version"] = "v1"
+	pod.Labels["pod-template-hash"] = "5d8f"
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: replicaSet.Name, UID: replicaSet.UID, Controller: ptr.To(true)}}
+	originalLabels := map[string]string{}
+	for key, value := range pod.Labels {
+		originalLabels[key] = value
+	}
+	c := newTestClient(t, replicaSet, service, pod)
+
+	action := &quarantineAction{Client: c, Scheme: c.Scheme(), APIReader: c}
+	target := &actions.Target{
+		Pod:  pod,
+		AMTD: &amtdv1beta1.AdaptiveMovingTargetDefense{Spec: amtdv1beta1.AdaptiveMovingTargetDefenseSpec{PodSelector: map[string]string{"app": "demo"}}},
+		Spec: amtdv1beta1.AMTDAction{Quarantine: &amtdv1beta1.QuarantineAction{}},
+	}
+	if _, err := action.Execute(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+
+	// the pod leaves the Service and the ReplicaSet but stays managed, its owner is not touched
+	quarantined := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), quarantined); err != nil {
+		t.Fatal(err)
+	}
+	expectedLabels := map[string]string{"app": "demo", "version": "v1", AMTD_NETWORK_POLICY: "default-a-policy"}
+	if !reflect.DeepEqual(quarantined.Labels, expectedLabels) {
+		t.Errorf("expected labels %v, got %v", expectedLabels, quarantined.Labels)
+	}
+	if !reflect.DeepEqual(quarantined.OwnerReferences, pod.OwnerReferences) {
+		t.Errorf("expected the owner references to be kept, got %v", quarantined.OwnerReferences)
+	}
+	var quarantineInfo QuarantineInfo
+	if err := json.Unmarshal([]byte(quarantined.Annotations[AMTD_QUARANTINE]), &quarantineInfo); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(quarantineInfo.Labels, originalLabels) || !reflect.DeepEqual(quarantineInfo.RemovedLabels, []string{"pod-template-hash", "tier"}) {
+		t.Errorf("unexpected quarantine record %+v", quarantineInfo)
+	}
+	if quarantineInfo.Owner == nil || quarantineInfo.Owner.Name != replicaSet.Name {
+		t.Errorf("expected the ReplicaSet to be recorded as the owner replacing the pod, got %v", quarantineInfo.Owner)
+	}
+	networkPolicy := &networkingv1.NetworkPolicy{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "default-a-policy"}, networkPolicy); err != nil {
+		t.Fatal(err)
+	}
+	if owner := metav1.GetControllerOf(networkPolicy); owner == nil || owner.UID != pod.UID {
+		t.Errorf("expected the pod to own the NetworkPolicy, got %v", owner)
+	}
+
+	// the release restores the pod
+	if err := action.Revert(ctx, &actions.Target{Pod: quarantined}); err != nil {
+		t.Fatal(err)
+	}
+	released := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), released); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(released.Labels, originalLabels) {
+		t.Errorf("expected labels %v, got %v", originalLabels, released.Labels)
+	}
+	if _, found := released.Annotations[AMTD_QUARANTINE]; found {
+		t.Errorf("expected the quarantine record to be removed")
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(networkPolicy), networkPolicy); !errors.IsNotFound(err) {
+		t.Errorf("expected the NetworkPolicy to be deleted, got %v", err)
+	}
+}
+
+func TestQuarantineNetworkPolicySpec(t *testing.T) {
+	template := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "quarantine-template"},
+		Spec: networkingv1.NetworkPolicySpec{
+			Egress: []networkingv1.NetworkPolicyEgressRule{{To: []networkingv1.NetworkPolicyPeer{{IPBlock: &networkingv1.IPBlock{CIDR: "10.0.0.0/8"}}}}},
+		},
+	}
+	action := &quarantineAction{Client: newTestClient(t, template)}
+	pod := newTestPod("a", true, nil)
+
+	tests := []struct {
+		name        string
+		options     *amtdv1beta1.QuarantineAction
+		policyTypes []networkingv1.PolicyType
+		ingress     int
+		egress      int
+	}{
+		{"deny all", nil, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}, 0, 0},
+		{"allow-lists", &amtdv1beta1.QuarantineAction{
+			AllowDNS:           true,
+			ForensicsNamespace: "forensics",
+			LoggingEndpoints:   []amtdv1beta1.NetworkEndpoint{{CIDR: "10.0.12.7/32", Port: ptr.To[int32](514)}},
+		}, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}, 1, 3},
+		{"egress only", &amtdv1beta1.QuarantineAction{Isolation: amtdv1beta1.QuarantineIsolateEgress, ForensicsNamespace: "forensics"}, []networkingv1.PolicyType{networkingv1.PolicyTypeEgress}, 0, 1},
+		{"ingress only", &amtdv1beta1.QuarantineAction{Isolation: amtdv1beta1.QuarantineIsolateIngress, AllowDNS: true}, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress}, 0, 0},
+		{"template", &amtdv1beta1.QuarantineAction{TemplateNetworkPolicy: "quarantine-template", AllowDNS: true}, []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress}, 0, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			spec, err := action.networkPolicySpec(context.Background(), pod, tt.options, "default-a-policy")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(spec.PolicyTypes, tt.policyTypes) {
+				t.Errorf("expected policy types %v, got %v", tt.policyTypes, spec.PolicyTypes)
+			}
+			if len(spec.Ingress) != tt.ingress || len(spec.Egress) != tt.egress {
+				t.Errorf("expected %d ingress and %d egress rules, got %v and %v", tt.ingress, tt.egress, spec.Ingress, spec.Egress)
+			}
+			if spec.PodSelector.MatchLabels[AMTD_NETWORK_POLICY] != "default-a-policy" {
+				t.Errorf("unexpected pod selector %v", spec.PodSelector)
+			}
+		})
+	}
+
+	if _, err := action.networkPolicySpec(context.Background(), pod, &amtdv1beta1.QuarantineAction{TemplateNetworkPolicy: "missing"}, "default-a-policy"); err == nil {
+		t.Errorf("expected an error for a missing template")
+	}
+}
+
+func TestQuarantineReportsSelectorsOfThePodSelector(t *testing.T) {
+	ctx := context.Background()
+	// the Service and the ReplicaSet select the pod only by the label of the podSelector
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo-5d8f", UID: "rs"},
+		Spec: appsv1.ReplicaSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "demo"}},
+		},
+	}
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "demo"}},
+	}
+	pod := newTestPod("a", true, nil)
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: replicaSet.Name, UID: replicaSet.UID, Controller: ptr.To(true)}}
+	c := newTestClient(t, replicaSet, service, pod)
+
+	action := &quarantineAction{Client: c, Scheme: c.Scheme(), APIReader: c}
+	target := &actions.Target{
+		Pod:  pod,
+		AMTD: newTestAMTD("demo"),
+		Spec: amtdv1beta1.AMTDAction{Quarantine: &amtdv1beta1.QuarantineAction{}},
+	}
+	if _, err := action.Execute(ctx, target); err != nil {
+		t.Fatal(err)
+	}
+	if len(target.Warnings) != 2 || !strings.Contains(target.Warnings[0], "Services [demo]") || !strings.Contains(target.Warnings[1], `ReplicaSet "demo-5d8f"`) {
+		t.Errorf("expected warnings about the Service and the ReplicaSet, got %v", target.Warnings)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "default-a-policy"}, &networkingv1.NetworkPolicy{}); err != nil {
+		t.Errorf("expected the pod to be isolated by the NetworkPolicy, got %v", err)
+	}
+}
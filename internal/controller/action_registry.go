@@ -0,0 +1,45 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+// NewActionRegistry returns a registry that contains the built-in actions of Phoenix. The reader
+// should not be cached: the destructive actions count the disrupted pods with it.
+func NewActionRegistry(c client.Client, reader client.Reader, scheme *runtime.Scheme) *actions.Registry {
+	registry := actions.NewRegistry()
+
+	builtins := map[string]actions.Action{
+		actions.Disable:      &disableAction{Client: c},
+		actions.Delete:       &deleteAction{Client: c, APIReader: reader},
+		actions.Quarantine:   &quarantineAction{Client: c, Scheme: scheme, APIReader: reader},
+		actions.Debugger:     &debuggerAction{Client: c},
+		actions.CustomAction: &customAction{Client: c},
+	}
+	for name, action := range builtins {
+		// names are unique constants, registering them into an empty registry cannot fail
+		_ = registry.RegisterBuiltin(name, action)
+	}
+
+	return registry
+}
@@ -21,7 +21,7 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"reflect"
+	"slices"
 	"strconv"
 	"time"
 
@@ -31,22 +31,42 @@ import (
 	"k8s.io/apimachinery/pkg/labels"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/internal/metrics"
+	"github.com/r6security/phoenix/pkg/actions"
+	"github.com/r6security/phoenix/pkg/rules"
 )
 
 // AdaptiveMovingTargetDefenseReconciler reconciles a AdaptiveMovingTargetDefense object
 type AdaptiveMovingTargetDefenseReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Recorder emits Kubernetes Events about enrollment, rule collisions and rotations
+	Recorder record.EventRecorder
+
+	// Actions executes the delete action of the periodic rotation, the built-in actions are used if nil
+	Actions *actions.Registry
+
+	// Audit puts every AMTD in audit mode, pods are not rotated then
+	Audit bool
+
+	// Resolution is the strategy resolution mode of the SecurityEvent controller, it decides
+	// which overlapping rules of AMTDs managing the same pod collide
+	Resolution rules.ResolutionMode
 }
 
 //+kubebuilder:rbac:groups=amtd.r6security.com,resources=adaptivemovingtargetdefenses,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=amtd.r6security.com,resources=adaptivemovingtargetdefenses/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=amtd.r6security.com,resources=adaptivemovingtargetdefenses/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -69,6 +89,7 @@ func (r *AdaptiveMovingTargetDefenseReconciler) Reconcile(ctx context.Context, r
 	if err != nil {
 		if errors.IsNotFound(err) {
 			log.Info(fmt.Sprintf(`Custom resource for AdaptiveMovingTargetDefense "%s" does not exist, remove annotations from pods`, req.Namespace+"/"+req.Name))
+			metrics.DeleteManagedPods(req.Namespace, req.Name)
 			// Get pods based on PodSelector
 			podList := &corev1.PodList{}
 			listOptions := &client.ListOptions{Namespace: req.Namespace}
@@ -92,6 +113,7 @@ func (r *AdaptiveMovingTargetDefenseReconciler) Reconcile(ctx context.Context, r
 					}
 
 					log.Info(fmt.Sprintf(`Pod: "%s" was sucessfully updated with annotations`, pod.Name))
+					r.Recorder.Eventf(&pod, corev1.EventTypeNormal, EVENT_REASON_UNENROLLED, `Pod is not managed by AdaptiveMovingTargetDefense "%s" anymore`, req.Namespace+"/"+req.Name)
 				}
 			}
 			return ctrl.Result{}, nil
@@ -111,6 +133,12 @@ func (r *AdaptiveMovingTargetDefenseReconciler) Reconcile(ctx context.Context, r
 		return ctrl.Result{}, err
 	}
 
+	// Keep the original state for patching the status
+	original := AMTD.DeepCopy()
+	managedPods := []string{}
+	managed := []corev1.Pod{}
+	collisions := []string{}
+
 	// Annotate pods
 	for _, pod := range podList.Items {
 
@@ -128,6 +156,7 @@ func (r *AdaptiveMovingTargetDefenseReconciler) Reconcile(ctx context.Context, r
 		}
 
 		// Check whether there is collision with other AMTD resources?
+		podCollisions := []string{}
 		for _, AMTDManageInfo := range amtdManageInfoList {
 			// Do not want to test collision with self
 			if !(AMTD.Namespace == AMTDManageInfo.AMTDNamespace && AMTD.Name == AMTDManageInfo.AMTDName) {
@@ -139,6 +168,7 @@ func (r *AdaptiveMovingTargetDefenseReconciler) Reconcile(ctx context.Context, r
 						// not have any resources for this AdaptiveMovingTargetDefense but no delete is required.
 						// TODO: remove the annotations
 						log.Info(fmt.Sprintf(`Custom resource for AdaptiveMovingTargetDefense "%s" does not exist, remove annotations from pods`, req.Namespace+"/"+req.Name))
+						continue
 					} else {
 						// some other error happend
 						log.Error(err, fmt.Sprintf(`Failed to retrieve custom resource "%s": %s`, AMTDManageInfo.AMTDName, err.Error()))
@@ -146,16 +176,22 @@ func (r *AdaptiveMovingTargetDefenseReconciler) Reconcile(ctx context.Context, r
 					}
 				}
 
-				for _, strategyOther := range AMTDOther.Spec.Strategy {
-					for _, strategy := range AMTD.Spec.Strategy {
-						if reflect.DeepEqual(strategyOther.Rule, strategy.Rule) {
-							log.Error(err, fmt.Sprintf(`AMTD RuleIDs collision "%s" <> "%s"`, AMTD.Name, AMTDOther.Name))
-							return ctrl.Result{}, err
-						}
-					}
+				if rules.Collide(r.Resolution, AMTD, AMTDOther) {
+					log.Info(fmt.Sprintf(`AMTD RuleIDs collision "%s" <> "%s"`, AMTD.Name, AMTDOther.Name))
+					podCollisions = append(podCollisions, AMTDOther.Namespace+"/"+AMTDOther.Name)
 				}
 			}
 		}
+		if len(podCollisions) > 0 {
+			// The pod is not managed until the collision is resolved, the periodic requeue rechecks it
+			r.Recorder.Eventf(AMTD, corev1.EventTypeWarning, EVENT_REASON_RULE_COLLISION, `Rules collide with %v on pod "%s", the pod is not managed`, podCollisions, pod.Name)
+			for _, collision := range podCollisions {
+				if !slices.Contains(collisions, collision) {
+					collisions = append(collisions, collision)
+				}
+			}
+			continue
+		}
 
 		// Add AMTD manage info if necessary
 		amtdManagedInfoExist := false
@@ -191,11 +227,50 @@ func (r *AdaptiveMovingTargetDefenseReconciler) Reconcile(ctx context.Context, r
 		}
 
 		log.Info(fmt.Sprintf(`Pod: "%s" was sucessfully updated with annotations`, pod.Name))
+		managedPods = append(managedPods, pod.Name)
+		if !amtdManagedInfoExist {
+			r.Recorder.Eventf(&pod, corev1.EventTypeNormal, EVENT_REASON_ENROLLED, `Pod is managed by AdaptiveMovingTargetDefense "%s"`, AMTD.Namespace+"/"+AMTD.Name)
+			r.Recorder.Eventf(AMTD, corev1.EventTypeNormal, EVENT_REASON_ENROLLED, `Pod "%s" is managed`, pod.Name)
+		}
+
+		// Pods that declare the AMTD readiness gate are ready from the AMTD viewpoint until they are disabled
+		if _, disabled := pod.ObjectMeta.Annotations[AMTD_DISABLED]; !disabled && getPodReadinessGate(&pod) == nil {
+			err = setPodReadinessGate(ctx, r.Client, &pod, corev1.ConditionTrue, "ManagedByAMTD")
+			if err != nil {
+				return ctrl.Result{}, err
+			}
+		}
+		managed = append(managed, pod)
+	}
+
+	// Rotation errors are returned after the status is updated, the pods rotated so far are recorded
+	var rotationErr error
+	if AMTD.Spec.Rotation != nil {
+		rotationErr = r.rotate(ctx, AMTD, managed)
+	}
+
+	if err := r.updateStatus(ctx, original, AMTD, managedPods, collisions); err != nil {
+		return ctrl.Result{}, err
+	}
+	if rotationErr != nil {
+		return ctrl.Result{}, rotationErr
 	}
 
 	return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
 }
 
+// updateStatus computes the pod inventory and the conditions of the AMTD and patches its status
+func (r *AdaptiveMovingTargetDefenseReconciler) updateStatus(ctx context.Context, original *amtdv1beta1.AdaptiveMovingTargetDefense, AMTD *amtdv1beta1.AdaptiveMovingTargetDefense, managedPods []string, collisions []string) error {
+	summarizeAMTDStatus(AMTD, managedPods, collisions)
+	metrics.SetManagedPods(AMTD.Namespace, AMTD.Name, len(managedPods))
+
+	err := r.Client.Status().Patch(ctx, AMTD, client.MergeFrom(original))
+	if err != nil {
+		log.FromContext(ctx).Error(err, fmt.Sprintf(`Failed to update status of AdaptiveMovingTargetDefense "%s": %s`, AMTD.Name, err.Error()))
+	}
+	return err
+}
+
 func removeAMTDAnnotationFromPod(pod corev1.Pod, log logr.Logger, reqNamespace string, reqName string) {
 	// Already AMTD member
 	var amtdManageInfoList []AMTDManageInfo
@@ -227,7 +302,18 @@ func removeAMTDAnnotationFromPod(pod corev1.Pod, log logr.Logger, reqNamespace s
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *AdaptiveMovingTargetDefenseReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor(EVENT_SOURCE)
+	}
+	if r.Actions == nil {
+		r.Actions = NewActionRegistry(r.Client, mgr.GetAPIReader(), r.Scheme)
+	}
+	if r.Resolution == "" {
+		r.Resolution = rules.HighestPriority
+	}
+
+	// Status updates must not trigger a new reconciliation, pods are rechecked periodically anyway
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&amtdv1beta1.AdaptiveMovingTargetDefense{}).
+		For(&amtdv1beta1.AdaptiveMovingTargetDefense{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
@@ -0,0 +1,70 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/rules"
+)
+
+func TestAMTDSkipsPodsWhereRulesCollide(t *testing.T) {
+	for resolution, managedPods := range map[rules.ResolutionMode][]string{
+		rules.HighestPriority: {"b"},
+		rules.RunAll:          {"a", "b"},
+	} {
+		t.Run(string(resolution), func(t *testing.T) {
+			ctx := context.Background()
+			// pod a is already managed by an AMTD that quarantines on the same events
+			first := newTestAMTD("first", amtdv1beta1.ResponseStrategy{
+				Rule:   amtdv1beta1.Rule{Type: "proc-*"},
+				Action: amtdv1beta1.AMTDAction{Quarantine: &amtdv1beta1.QuarantineAction{}},
+			})
+			second := newTestAMTD("second", amtdv1beta1.ResponseStrategy{
+				Rule:   amtdv1beta1.Rule{Type: "proc-a"},
+				Action: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{}},
+			})
+			c := newTestClient(t, first, second, newManagedTestPod(t, "a", first), newTestPod("b", true, nil))
+			r := &AdaptiveMovingTargetDefenseReconciler{Client: c, Scheme: c.Scheme(), Recorder: record.NewFakeRecorder(100), Resolution: resolution}
+
+			result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Namespace: "default", Name: "second"}})
+			if err != nil || result.RequeueAfter == 0 {
+				t.Fatalf("expected a periodic recheck, got %v, %v", result, err)
+			}
+			AMTD := &amtdv1beta1.AdaptiveMovingTargetDefense{}
+			if err := c.Get(ctx, client.ObjectKeyFromObject(second), AMTD); err != nil {
+				t.Fatal(err)
+			}
+			if len(AMTD.Status.ManagedPods) != len(managedPods) || AMTD.Status.ManagedPods[0] != managedPods[0] {
+				t.Errorf("expected managed pods %v, got %v", managedPods, AMTD.Status.ManagedPods)
+			}
+			collide := len(managedPods) == 1
+			if meta.IsStatusConditionTrue(AMTD.Status.Conditions, amtdv1beta1.AMTDRuleCollision) != collide {
+				t.Errorf("expected collision %v, got conditions %+v", collide, AMTD.Status.Conditions)
+			}
+		})
+	}
+}
@@ -0,0 +1,240 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/internal/metrics"
+	"github.com/r6security/phoenix/pkg/actions"
+	"github.com/r6security/phoenix/pkg/schedule"
+)
+
+// rotationCandidate is a managed pod together with the time it is due for rotation
+type rotationCandidate struct {
+	pod *corev1.Pod
+	due time.Time
+}
+
+// rotate deletes the managed pods that are due for rotation with the delete action, at most
+// MaxConcurrent at a time. The pods are rechecked at every reconciliation, so the ones that do not
+// fit into the limit are rotated later.
+func (r *AdaptiveMovingTargetDefenseReconciler) rotate(ctx context.Context, AMTD *amtdv1beta1.AdaptiveMovingTargetDefense, pods []corev1.Pod) error {
+	log := log.FromContext(ctx)
+	rotation := AMTD.Spec.Rotation
+	now := time.Now()
+
+	if AMTD.Status.Rotation == nil {
+		AMTD.Status.Rotation = &amtdv1beta1.RotationStatus{}
+	}
+	status := AMTD.Status.Rotation
+
+	// ---------------------------------------------------
+	// Find the last activation of the schedule
+	// ---------------------------------------------------
+	var scheduled time.Time
+	if rotation.Schedule != "" {
+		cron, err := schedule.ParseCron(rotation.Schedule)
+		if err != nil {
+			// Retrying does not help, the AMTD has to be fixed
+			log.Info(fmt.Sprintf(`Invalid rotation schedule of AdaptiveMovingTargetDefense "%s": %s`, AMTD.Name, err.Error()))
+			r.Recorder.Eventf(AMTD, corev1.EventTypeWarning, EVENT_REASON_ROTATION_FAILED, "Invalid rotation schedule: %s", err.Error())
+			return nil
+		}
+		from := AMTD.CreationTimestamp.Time
+		if status.LastScheduleTime != nil {
+			from = status.LastScheduleTime.Time
+		}
+		for next := cron.Next(from.UTC()); !next.IsZero() && !next.After(now); next = cron.Next(next) {
+			status.LastScheduleTime = &metav1.Time{Time: next}
+		}
+		if status.LastScheduleTime == nil {
+			// the schedule did not activate since the AMTD exists
+			return nil
+		}
+		scheduled = status.LastScheduleTime.Time
+	}
+
+	// ---------------------------------------------------
+	// Collect the pods that are due and the ones being rotated
+	// ---------------------------------------------------
+	inProgress := 0
+	candidates := []rotationCandidate{}
+	for i := range pods {
+		pod := &pods[i]
+		_, disabled := pod.Annotations[AMTD_DISABLED]
+		_, quarantined := pod.Annotations[AMTD_QUARANTINE]
+		switch {
+		case pod.DeletionTimestamp != nil:
+			inProgress++
+			continue
+		case disabled || quarantined:
+			// isolated pods are kept for the responders, they are not ready on purpose
+			continue
+		case !podReady(pod):
+			// a replacement that is still starting
+			inProgress++
+			continue
+		}
+
+		jitter := rotationJitter(pod, rotation.Jitter)
+		var due time.Time
+		switch {
+		case rotation.Interval != nil:
+			due = pod.CreationTimestamp.Add(rotation.Interval.Duration + jitter)
+		case pod.CreationTimestamp.Time.Before(scheduled):
+			due = scheduled.Add(jitter)
+		default:
+			continue
+		}
+		if !rotatable(pod) {
+			// nothing would recreate the pod, deleting it would shrink the workload
+			if !due.After(now) {
+				log.Info(fmt.Sprintf(`Pod "%s" is not rotated, it has no controller that recreates it`, pod.Name))
+				r.Recorder.Eventf(pod, corev1.EventTypeWarning, EVENT_REASON_ROTATION_SKIPPED, `Not rotated by AdaptiveMovingTargetDefense "%s": the pod has no controller that recreates it`, AMTD.Namespace+"/"+AMTD.Name)
+				r.Recorder.Eventf(AMTD, corev1.EventTypeWarning, EVENT_REASON_ROTATION_SKIPPED, `Pod "%s" is not rotated: it has no controller that recreates it`, pod.Name)
+			}
+			continue
+		}
+		candidates = append(candidates, rotationCandidate{pod, due})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].due.Before(candidates[j].due)
+	})
+
+	maxConcurrent := 1
+	if rotation.MaxConcurrent > 0 {
+		maxConcurrent = int(rotation.MaxConcurrent)
+	}
+
+	// ---------------------------------------------------
+	// Rotate the pods that are due, the earliest first
+	// ---------------------------------------------------
+	if r.audited(AMTD) {
+		// In audit mode the pods are not modified at all
+		r.auditRotation(ctx, AMTD, candidates, now)
+		return nil
+	}
+	for _, candidate := range candidates {
+		if inProgress >= maxConcurrent || candidate.due.After(now) {
+			break
+		}
+		pod := candidate.pod
+		_, action, err := r.Actions.Resolve(amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{}})
+		if err == nil {
+			_, err = action.Execute(ctx, &actions.Target{
+				Pod:  pod,
+				AMTD: AMTD,
+				Spec: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{}},
+			})
+		}
+		if deferred, ok := actions.IsDeferred(err); ok {
+			// the remaining pods are rotated when the disruption limits allow it
+			log.Info(fmt.Sprintf(`Rotation of pod "%s" is deferred: %s`, pod.Name, deferred.Reason))
+			return nil
+		}
+		if err != nil {
+			log.Error(err, fmt.Sprintf(`Failed to rotate pod "%s"`, pod.Name))
+			r.Recorder.Eventf(pod, corev1.EventTypeWarning, EVENT_REASON_ROTATION_FAILED, "Rotation failed: %s", err.Error())
+			r.Recorder.Eventf(AMTD, corev1.EventTypeWarning, EVENT_REASON_ROTATION_FAILED, `Rotation of pod "%s" failed: %s`, pod.Name, err.Error())
+			metrics.RecordRotation(AMTD.Namespace, AMTD.Name, metrics.RESULT_FAILED)
+			return err
+		}
+
+		log.Info(fmt.Sprintf(`Pod "%s" was rotated`, pod.Name))
+		r.Recorder.Eventf(pod, corev1.EventTypeNormal, EVENT_REASON_ROTATED, `Pod was rotated by AdaptiveMovingTargetDefense "%s"`, AMTD.Namespace+"/"+AMTD.Name)
+		r.Recorder.Eventf(AMTD, corev1.EventTypeNormal, EVENT_REASON_ROTATED, `Pod "%s" was rotated`, pod.Name)
+		metrics.RecordRotation(AMTD.Namespace, AMTD.Name, metrics.RESULT_SUCCEEDED)
+		status.RotatedPods++
+		status.LastRotationTime = &metav1.Time{Time: now}
+		inProgress++
+	}
+	return nil
+}
+
+// auditRotation reports the pods that would be rotated in audit mode. A pod is reported once, in
+// the first reconciliation after it became due.
+func (r *AdaptiveMovingTargetDefenseReconciler) auditRotation(ctx context.Context, AMTD *amtdv1beta1.AdaptiveMovingTargetDefense, candidates []rotationCandidate, now time.Time) {
+	status := AMTD.Status.Rotation
+	audited := false
+	for _, candidate := range candidates {
+		if candidate.due.After(now) {
+			break
+		}
+		if status.LastAuditTime != nil && !candidate.due.After(status.LastAuditTime.Time) {
+			continue
+		}
+		pod := candidate.pod
+		log.FromContext(ctx).Info(fmt.Sprintf(`Audit mode: pod "%s" would be rotated`, pod.Name))
+		r.Recorder.Eventf(pod, corev1.EventTypeNormal, EVENT_REASON_ACTION_AUDITED, `Would be rotated by AdaptiveMovingTargetDefense "%s" (audit mode)`, AMTD.Namespace+"/"+AMTD.Name)
+		r.Recorder.Eventf(AMTD, corev1.EventTypeNormal, EVENT_REASON_ACTION_AUDITED, `Would rotate pod "%s" (audit mode)`, pod.Name)
+		metrics.RecordRotation(AMTD.Namespace, AMTD.Name, metrics.RESULT_AUDITED)
+		audited = true
+	}
+	if audited {
+		status.LastAuditTime = &metav1.Time{Time: now}
+	}
+}
+
+// audited reports whether the pods of the AMTD must not be modified
+func (r *AdaptiveMovingTargetDefenseReconciler) audited(AMTD *amtdv1beta1.AdaptiveMovingTargetDefense) bool {
+	return r.Audit || AMTD.Spec.Mode == amtdv1beta1.AMTDAudit
+}
+
+// rotatableOwners are the controllers that recreate a deleted pod
+var rotatableOwners = map[string]bool{
+	"apps/v1/ReplicaSet":  true,
+	"apps/v1/DaemonSet":   true,
+	"apps/v1/StatefulSet": true,
+}
+
+// rotatable reports whether the pod has a controller that recreates it when it is rotated
+func rotatable(pod *corev1.Pod) bool {
+	owner := metav1.GetControllerOf(pod)
+	return owner != nil && rotatableOwners[owner.APIVersion+"/"+owner.Kind]
+}
+
+// rotationJitter returns the delay of the rotation of the pod, it is derived from the UID of the
+// pod so that it does not change between reconciliations but differs between the pods
+func rotationJitter(pod *corev1.Pod, jitter *metav1.Duration) time.Duration {
+	if jitter == nil || jitter.Duration <= 0 {
+		return 0
+	}
+	hash := fnv.New64a()
+	hash.Write([]byte(pod.UID))
+	return time.Duration(hash.Sum64() % uint64(jitter.Duration))
+}
+
+// podReady reports whether the Ready condition of the pod is True
+func podReady(pod *corev1.Pod) bool {
+	for _, condition := range pod.Status.Conditions {
+		if condition.Type == corev1.PodReady {
+			return condition.Status == corev1.ConditionTrue
+		}
+	}
+	return false
+}
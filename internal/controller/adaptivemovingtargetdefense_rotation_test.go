@@ -0,0 +1,102 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// newRotatedTestPod returns a managed pod of a ReplicaSet created the given time ago
+func newRotatedTestPod(t *testing.T, name string, age time.Duration, AMTD *amtdv1beta1.AdaptiveMovingTargetDefense) *corev1.Pod {
+	pod := newManagedTestPod(t, name, AMTD)
+	pod.CreationTimestamp = metav1.NewTime(time.Now().Add(-age))
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: "demo", UID: "demo", Controller: ptr.To(true)}}
+	return pod
+}
+
+func TestRotationInAuditMode(t *testing.T) {
+	ctx := context.Background()
+	AMTD := newTestAMTD("rotating")
+	AMTD.Spec.Mode = amtdv1beta1.AMTDAudit
+	AMTD.Spec.Rotation = &amtdv1beta1.Rotation{Interval: &metav1.Duration{Duration: time.Hour}}
+	due := newRotatedTestPod(t, "due", 2*time.Hour, AMTD)
+	fresh := newRotatedTestPod(t, "fresh", 0, AMTD)
+	c := newTestClient(t, AMTD, due, fresh)
+	recorder := record.NewFakeRecorder(100)
+	r := &AdaptiveMovingTargetDefenseReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder, Actions: NewActionRegistry(c, c, c.Scheme())}
+
+	// the pod is reported in the first reconciliation only
+	for range 2 {
+		if err := r.rotate(ctx, AMTD, []corev1.Pod{*due, *fresh}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	audited := []string{}
+	for len(recorder.Events) > 0 {
+		event := <-recorder.Events
+		if !strings.Contains(event, EVENT_REASON_ACTION_AUDITED) {
+			t.Errorf("unexpected event %q", event)
+		}
+		audited = append(audited, event)
+	}
+	if len(audited) != 2 || !strings.Contains(audited[0], "rotating") || !strings.Contains(audited[1], `"due"`) {
+		t.Errorf("expected the rotation of pod due to be audited on the pod and the AMTD, got %v", audited)
+	}
+	if AMTD.Status.Rotation.LastAuditTime == nil || AMTD.Status.Rotation.RotatedPods != 0 {
+		t.Errorf("expected an audit and no rotation, got %+v", AMTD.Status.Rotation)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(due), &corev1.Pod{}); err != nil {
+		t.Errorf("expected pod due to be kept, got %v", err)
+	}
+}
+
+func TestRotationSkipsPodsWithoutController(t *testing.T) {
+	ctx := context.Background()
+	AMTD := newTestAMTD("rotating")
+	AMTD.Spec.Rotation = &amtdv1beta1.Rotation{Interval: &metav1.Duration{Duration: time.Hour}}
+	bare := newRotatedTestPod(t, "bare", 2*time.Hour, AMTD)
+	bare.OwnerReferences = nil
+	c := newTestClient(t, AMTD, bare)
+	recorder := record.NewFakeRecorder(100)
+	r := &AdaptiveMovingTargetDefenseReconciler{Client: c, Scheme: c.Scheme(), Recorder: recorder, Actions: NewActionRegistry(c, c, c.Scheme())}
+
+	if err := r.rotate(ctx, AMTD, []corev1.Pod{*bare}); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(bare), &corev1.Pod{}); err != nil {
+		t.Errorf("expected the pod without controller to be kept, got %v", err)
+	}
+	if AMTD.Status.Rotation.RotatedPods != 0 {
+		t.Errorf("expected no rotation, got %+v", AMTD.Status.Rotation)
+	}
+	events := recordedEvents(recorder)
+	if len(events) != 2 || !hasEvent(events, EVENT_REASON_ROTATION_SKIPPED) || !strings.HasPrefix(events[0], corev1.EventTypeWarning) {
+		t.Errorf("expected a RotationSkipped warning on the pod and the AMTD, got %v", events)
+	}
+}
@@ -0,0 +1,128 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/util/retry"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// summarizeAMTDStatus sets the pod inventory and the conditions of the AMTD.
+// collisions contains the "<namespace>/<name>" of the AMTDs whose rules collide with the AMTD
+func summarizeAMTDStatus(AMTD *amtdv1beta1.AdaptiveMovingTargetDefense, managedPods []string, collisions []string) {
+	status := &AMTD.Status
+	now := metav1.Now()
+
+	sort.Strings(managedPods)
+	status.ObservedGeneration = AMTD.Generation
+	status.ManagedPods = managedPods
+	status.ManagedPodCount = int32(len(managedPods))
+	status.LastReconcileTime = &now
+
+	ruleCollision := metav1.Condition{
+		Type:               amtdv1beta1.AMTDRuleCollision,
+		Status:             metav1.ConditionFalse,
+		Reason:             "NoCollision",
+		Message:            "Rules do not collide with other AdaptiveMovingTargetDefenses",
+		ObservedGeneration: AMTD.Generation,
+	}
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		ruleCollision.Status = metav1.ConditionTrue
+		ruleCollision.Reason = "RulesCollide"
+		ruleCollision.Message = fmt.Sprintf("Rules collide with: %s", strings.Join(collisions, ", "))
+	}
+	meta.SetStatusCondition(&status.Conditions, ruleCollision)
+
+	active := metav1.Condition{
+		Type:               amtdv1beta1.AMTDActive,
+		Status:             metav1.ConditionTrue,
+		Reason:             "PodsManaged",
+		Message:            fmt.Sprintf("%d pods managed", len(managedPods)),
+		ObservedGeneration: AMTD.Generation,
+	}
+	switch {
+	case len(collisions) > 0 && len(managedPods) == 0:
+		active.Status = metav1.ConditionFalse
+		active.Reason = "RulesCollide"
+		active.Message = ruleCollision.Message
+	case len(managedPods) == 0:
+		active.Status = metav1.ConditionFalse
+		active.Reason = "NoPodsSelected"
+		active.Message = "No pods match the podSelector"
+	case len(collisions) > 0:
+		// pods where the rules collide are not managed, the others are
+		active.Message = fmt.Sprintf("%d pods managed, the pods where the rules collide are not managed", len(managedPods))
+	}
+	meta.SetStatusCondition(&status.Conditions, active)
+}
+
+// recordStrategyExecution increments the execution counter of the strategy
+// identified by rule and action in the status of the AMTD
+func recordStrategyExecution(ctx context.Context, c client.Client, key types.NamespacedName, rule amtdv1beta1.Rule, action string) error {
+	return updateAMTDStatus(ctx, c, key, func(AMTD *amtdv1beta1.AdaptiveMovingTargetDefense) {
+		now := metav1.Now()
+		strategies := AMTD.Status.Strategies
+		for i := range strategies {
+			if strategies[i].Action == action && reflect.DeepEqual(strategies[i].Rule, rule) {
+				strategies[i].Executions++
+				strategies[i].LastExecutionTime = &now
+				return
+			}
+		}
+		AMTD.Status.Strategies = append(strategies, amtdv1beta1.StrategyStatus{
+			Rule:              rule,
+			Action:            action,
+			Executions:        1,
+			LastExecutionTime: &now,
+		})
+	})
+}
+
+// recordDefaultActionExecution increments the execution counter of the default action in the status of the AMTD
+func recordDefaultActionExecution(ctx context.Context, c client.Client, key types.NamespacedName) error {
+	return updateAMTDStatus(ctx, c, key, func(AMTD *amtdv1beta1.AdaptiveMovingTargetDefense) {
+		now := metav1.Now()
+		AMTD.Status.DefaultActionExecutions++
+		AMTD.Status.LastDefaultActionTime = &now
+	})
+}
+
+// updateAMTDStatus applies mutate on the latest version of the AMTD status, retrying on conflicts
+// with the AMTD reconciler or other SecurityEvents
+func updateAMTDStatus(ctx context.Context, c client.Client, key types.NamespacedName, mutate func(*amtdv1beta1.AdaptiveMovingTargetDefense)) error {
+	return retry.RetryOnConflict(retry.DefaultRetry, func() error {
+		AMTD := &amtdv1beta1.AdaptiveMovingTargetDefense{}
+		if err := c.Get(ctx, key, AMTD); err != nil {
+			return err
+		}
+		mutate(AMTD)
+		return c.Status().Update(ctx, AMTD)
+	})
+}
@@ -17,11 +17,25 @@
 
 package controller
 
+import corev1 "k8s.io/api/core/v1"
+
 const (
 	AMTD_MANAGED_TIME   string = "amtd.r6security.com/managed-time"
 	AMTD_MANAGED_BY     string = "amtd.r6security.com/managed-by"
 	AMTD_STRATEGY_BASE  string = "amtd.r6security.com/strategy-"
 	AMTD_NETWORK_POLICY string = "amtd.r6security.com/network-policy"
+	AMTD_DISABLED       string = "amtd.r6security.com/disabled"
+	AMTD_QUARANTINE     string = "amtd.r6security.com/quarantine"
+	AMTD_REPLACEMENT    string = "amtd.r6security.com/replacement"
+
+	// Setting AMTD_RELEASE on a quarantined or disabled pod (value: a note of the requester, e.g. the
+	// name of the responder, which is not verified) releases the pod, the release is recorded in AMTD_RELEASED
+	AMTD_RELEASE  string = "amtd.r6security.com/release"
+	AMTD_RELEASED string = "amtd.r6security.com/released"
+
+	// Pods that list this condition type in their readinessGates are reported
+	// ready by the AMTD controller and not ready while they are disabled
+	AMTD_READINESS_GATE corev1.PodConditionType = "amtd.r6security.com/serving"
 
 	AMTD_APPLIED_SECURITY_EVENTS string = "amtd.r6security.com/applied-sec-events"
 	R6_SECURITY_EVENT_RECEIVED   string = "amtd.r6security.event.received"
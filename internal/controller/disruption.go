@@ -0,0 +1,122 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+//+kubebuilder:rbac:groups=policy,resources=poddisruptionbudgets,verbs=get;list;watch
+
+// DISRUPTION_RETRY_INTERVAL is how long a destructive action waits before it is retried
+// when a disruption limit does not allow it
+const DISRUPTION_RETRY_INTERVAL = 15 * time.Second
+
+// checkDisruption returns a DeferredError if executing the destructive action on the pod would
+// exceed a disruption limit of the AMTD or a PodDisruptionBudget of the ready pod. The budgets are
+// checked for every action since direct deletions and quarantines bypass the Eviction API.
+//
+// The reader must not be a cache: during a burst of SecurityEvents the next action is checked
+// right after the previous one, before the cache sees the terminating or quarantined pod.
+func checkDisruption(ctx context.Context, c client.Reader, AMTD *amtdv1beta1.AdaptiveMovingTargetDefense, pod *corev1.Pod, action string) error {
+	if podReady(pod) {
+		if err := checkPodDisruptionBudgets(ctx, c, pod); err != nil {
+			return err
+		}
+	}
+	if AMTD == nil || AMTD.Spec.Disruption == nil {
+		return nil
+	}
+	limits := AMTD.Spec.Disruption
+
+	podList := &corev1.PodList{}
+	listOptions := &client.ListOptions{Namespace: AMTD.Namespace, LabelSelector: labels.SelectorFromSet(AMTD.Spec.PodSelector)}
+	if err := c.List(ctx, podList, listOptions); err != nil {
+		return err
+	}
+	terminating, quarantined, available := 0, 0, 0
+	targetAvailable := false
+	for i := range podList.Items {
+		selected := &podList.Items[i]
+		_, isQuarantined := selected.Annotations[AMTD_QUARANTINE]
+		_, isDisabled := selected.Annotations[AMTD_DISABLED]
+		switch {
+		case selected.DeletionTimestamp != nil:
+			terminating++
+		case isQuarantined:
+			quarantined++
+		case !isDisabled && podReady(selected):
+			available++
+			if selected.UID == pod.UID {
+				targetAvailable = true
+			}
+		}
+	}
+
+	if action == actions.Delete && limits.MaxConcurrentDeletions != nil && terminating >= int(*limits.MaxConcurrentDeletions) {
+		return deferred(fmt.Sprintf("%d pods are terminating, maxConcurrentDeletions is %d", terminating, *limits.MaxConcurrentDeletions))
+	}
+	if action == actions.Quarantine && limits.MaxConcurrentQuarantines != nil && quarantined >= int(*limits.MaxConcurrentQuarantines) {
+		return deferred(fmt.Sprintf("%d pods are quarantined, maxConcurrentQuarantines is %d", quarantined, *limits.MaxConcurrentQuarantines))
+	}
+	if limits.MinAvailable != nil && targetAvailable {
+		minAvailable, err := intstr.GetScaledValueFromIntOrPercent(limits.MinAvailable, len(podList.Items), true)
+		if err != nil {
+			return fmt.Errorf(`invalid minAvailable of AdaptiveMovingTargetDefense "%s": %w`, AMTD.Name, err)
+		}
+		if available-1 < minAvailable {
+			return deferred(fmt.Sprintf("%d pods are available, minAvailable is %d", available, minAvailable))
+		}
+	}
+	return nil
+}
+
+// checkPodDisruptionBudgets returns a DeferredError if a PodDisruptionBudget selecting the pod
+// does not allow any more disruptions
+func checkPodDisruptionBudgets(ctx context.Context, c client.Reader, pod *corev1.Pod) error {
+	budgets := &policyv1.PodDisruptionBudgetList{}
+	if err := c.List(ctx, budgets, client.InNamespace(pod.Namespace)); err != nil {
+		return err
+	}
+	for _, budget := range budgets.Items {
+		selector, err := metav1.LabelSelectorAsSelector(budget.Spec.Selector)
+		if err != nil || !selector.Matches(labels.Set(pod.Labels)) {
+			continue
+		}
+		if budget.Status.DisruptionsAllowed < 1 {
+			return deferred(fmt.Sprintf(`PodDisruptionBudget "%s" does not allow more disruptions`, budget.Name))
+		}
+	}
+	return nil
+}
+
+func deferred(reason string) *actions.DeferredError {
+	return &actions.DeferredError{Reason: reason, RetryAfter: DISRUPTION_RETRY_INTERVAL}
+}
@@ -0,0 +1,162 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	policyv1 "k8s.io/api/policy/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/utils/ptr"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+func newTestPod(name string, ready bool, annotations map[string]string) *corev1.Pod {
+	status := corev1.ConditionFalse
+	if ready {
+		status = corev1.ConditionTrue
+	}
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Namespace:   "default",
+			Name:        name,
+			UID:         types.UID(name),
+			Labels:      map[string]string{"app": "demo"},
+			Annotations: annotations,
+		},
+		Status: corev1.PodStatus{Conditions: []corev1.PodCondition{{Type: corev1.PodReady, Status: status}}},
+	}
+}
+
+func newTestClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := amtdv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).WithStatusSubresource(&policyv1.PodDisruptionBudget{}, &amtdv1beta1.SecurityEvent{}, &amtdv1beta1.AdaptiveMovingTargetDefense{}).Build()
+}
+
+func TestCheckDisruption(t *testing.T) {
+	quarantined := map[string]string{AMTD_QUARANTINE: "{}"}
+	pods := []client.Object{
+		newTestPod("a", true, nil),
+		newTestPod("b", true, nil),
+		newTestPod("c", false, nil),
+		newTestPod("d", false, quarantined),
+	}
+	c := newTestClient(t, pods...)
+
+	tests := []struct {
+		name     string
+		limits   amtdv1beta1.DisruptionLimits
+		action   string
+		deferred bool
+	}{
+		{"no limits", amtdv1beta1.DisruptionLimits{}, actions.Delete, false},
+		{"quarantine limit reached", amtdv1beta1.DisruptionLimits{MaxConcurrentQuarantines: ptr.To[int32](1)}, actions.Quarantine, true},
+		{"quarantine limit not reached", amtdv1beta1.DisruptionLimits{MaxConcurrentQuarantines: ptr.To[int32](2)}, actions.Quarantine, false},
+		{"min available reached", amtdv1beta1.DisruptionLimits{MinAvailable: ptr.To(intstr.FromInt32(2))}, actions.Delete, true},
+		{"min available percentage", amtdv1beta1.DisruptionLimits{MinAvailable: ptr.To(intstr.FromString("25%"))}, actions.Delete, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			AMTD := &amtdv1beta1.AdaptiveMovingTargetDefense{
+				ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "amtd"},
+				Spec: amtdv1beta1.AdaptiveMovingTargetDefenseSpec{
+					PodSelector: map[string]string{"app": "demo"},
+					Disruption:  &tt.limits,
+				},
+			}
+			err := checkDisruption(context.Background(), c, AMTD, pods[0].(*corev1.Pod), tt.action)
+			if _, deferred := actions.IsDeferred(err); deferred != tt.deferred {
+				t.Errorf("expected deferred %v, got %v", tt.deferred, err)
+			}
+		})
+	}
+}
+
+func TestCheckDisruptionHonoursPodDisruptionBudgets(t *testing.T) {
+	pod := newTestPod("a", true, nil)
+	budget := &policyv1.PodDisruptionBudget{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo"},
+		Spec:       policyv1.PodDisruptionBudgetSpec{Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "demo"}}},
+	}
+	c := newTestClient(t, pod, budget)
+
+	for _, allowed := range []int32{0, 1} {
+		budget.Status.DisruptionsAllowed = allowed
+		if err := c.Status().Update(context.Background(), budget); err != nil {
+			t.Fatal(err)
+		}
+		err := checkDisruption(context.Background(), c, nil, pod, actions.Quarantine)
+		if _, deferred := actions.IsDeferred(err); deferred != (allowed == 0) {
+			t.Errorf("%d disruptions allowed: unexpected result %v", allowed, err)
+		}
+	}
+}
+
+func TestDisruptionLimitsOfBackToBackDeletes(t *testing.T) {
+	objects := func() []client.Object {
+		AMTD := newTestAMTD("demo", amtdv1beta1.ResponseStrategy{
+			Rule:   amtdv1beta1.Rule{Type: "exec"},
+			Action: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{}},
+		})
+		AMTD.Spec.Disruption = &amtdv1beta1.DisruptionLimits{MaxConcurrentDeletions: ptr.To[int32](1)}
+		objects := []client.Object{AMTD, newTestSecurityEvent("event-a", "exec", "a"), newTestSecurityEvent("event-b", "exec", "b")}
+		for _, name := range []string{"a", "b"} {
+			// the finalizer keeps the evicted pod terminating
+			pod := newManagedTestPod(t, name, AMTD)
+			pod.Finalizers = []string{"test/finalizer"}
+			objects = append(objects, pod)
+		}
+		return objects
+	}
+	c := newTestClient(t, objects()...)
+
+	// the cache of the manager has not seen the first eviction yet when the second event arrives
+	stale := newTestClient(t, objects()...)
+	cached := interceptor.NewClient(c.(client.WithWatch), interceptor.Funcs{
+		List: func(ctx context.Context, _ client.WithWatch, list client.ObjectList, opts ...client.ListOption) error {
+			return stale.List(ctx, list, opts...)
+		},
+	})
+	r, _ := newTestSecurityEventReconciler(cached)
+	r.Actions = NewActionRegistry(cached, c, c.Scheme())
+
+	if status, _, err := reconcileSecurityEvent(t, r, "event-a"); err != nil || status.Targets[0].Phase != amtdv1beta1.TargetApplied {
+		t.Fatalf("expected the first delete to be applied, got %+v, %v", status.Targets, err)
+	}
+	status, result, err := reconcileSecurityEvent(t, r, "event-b")
+	if err != nil || status.Targets[0].Phase != amtdv1beta1.TargetPending || result.RequeueAfter != DISRUPTION_RETRY_INTERVAL {
+		t.Errorf("expected the second delete to be deferred, got %+v, %v, %v", status.Targets, result, err)
+	}
+}
@@ -0,0 +1,53 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+// EVENT_SOURCE is the component reported in the Kubernetes Events emitted by the reconcilers
+const EVENT_SOURCE = "phoenix"
+
+// Reasons of the Kubernetes Events emitted by the reconcilers
+const (
+	// A pod started to be managed by an AMTD (Normal, on the pod and the AMTD)
+	EVENT_REASON_ENROLLED = "Enrolled"
+	// A pod is not managed by an AMTD anymore because the AMTD was deleted (Normal, on the pod)
+	EVENT_REASON_UNENROLLED = "Unenrolled"
+	// The rules of an AMTD collide with another AMTD managing the same pod (Warning, on the AMTD)
+	EVENT_REASON_RULE_COLLISION = "RuleCollision"
+	// An action is executed in response to a SecurityEvent (Normal, on the pod and the SecurityEvent)
+	EVENT_REASON_ACTION_STARTED = "ActionStarted"
+	// An action was executed (Normal, on the pod, the SecurityEvent and the AMTD)
+	EVENT_REASON_ACTION_SUCCEEDED = "ActionSucceeded"
+	// An action could not be executed (Warning, on the pod, the SecurityEvent and the AMTD)
+	EVENT_REASON_ACTION_FAILED = "ActionFailed"
+	// An action waits for the disruption limits to allow it (Normal, on the pod and the SecurityEvent)
+	EVENT_REASON_ACTION_DEFERRED = "ActionDeferred"
+	// An action was executed but did not take full effect (Warning, on the pod and the SecurityEvent)
+	EVENT_REASON_ACTION_INCOMPLETE = "ActionIncomplete"
+	// An action was planned but not executed because of audit mode (Normal, on the pod, the SecurityEvent and the AMTD)
+	EVENT_REASON_ACTION_AUDITED = "ActionAudited"
+	// A quarantined or disabled pod was released (Normal, on the pod)
+	EVENT_REASON_RELEASED = "Released"
+	// Reverting an action while releasing a pod failed (Warning, on the pod)
+	EVENT_REASON_RELEASE_FAILED = "ReleaseFailed"
+	// A pod was deleted by the periodic rotation (Normal, on the pod and the AMTD)
+	EVENT_REASON_ROTATED = "Rotated"
+	// A pod could not be rotated or the rotation of the AMTD is invalid (Warning, on the pod and the AMTD)
+	EVENT_REASON_ROTATION_FAILED = "RotationFailed"
+	// A pod that is due for rotation has no controller that would recreate it (Warning, on the pod and the AMTD)
+	EVENT_REASON_ROTATION_SKIPPED = "RotationSkipped"
+)
@@ -19,20 +19,44 @@ package controller
 
 import (
 	"context"
+	"encoding/json"
+	"fmt"
 	"reflect"
 	"strings"
+	"time"
 
-	//corev1 "k8s.io/api/core/v1"
 	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	"github.com/r6security/phoenix/internal/metrics"
+	"github.com/r6security/phoenix/pkg/actions"
 )
 
 // PodReconciler reconciles a Pod object
 type PodReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Actions used to revert quarantine and disable, the built-in actions are used if nil
+	Actions *actions.Registry
+
+	// Recorder emits Kubernetes Events about the release of pods
+	Recorder record.EventRecorder
+}
+
+// ReleaseInfo is stored in the AMTD_RELEASED annotation of a pod that was released
+// from quarantine or re-enabled after being disabled
+type ReleaseInfo struct {
+	// ReleaseNote is the value of the AMTD_RELEASE annotation as the requester declared it, e.g. the
+	// name of the responder. It is not verified, the Kubernetes audit log records who set it.
+	ReleaseNote string   `json:"release-note"`
+	ReleasedAt  string   `json:"released-at"`
+	Actions     []string `json:"actions"`
 }
 
 //+kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;create;update;patch;delete
@@ -41,74 +65,95 @@ type PodReconciler struct {
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
-// TODO(user): Modify the Reconcile function to compare the state specified by
-// the Pod object against the actual cluster state, and then
-// perform operations to make the cluster state reflect the state specified by
-// the user.
+// It releases pods on which the AMTD_RELEASE annotation is set by reverting the
+// quarantine and disable actions executed on them.
 //
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.14.4/pkg/reconcile
 func (r *PodReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
-	//log := log.FromContext(ctx)
-	/*
-		// TODO(user): your logic here
-		// Start by declaring the custom resource to be type "Pod"
-		pod := &corev1.Pod{}
-
-		// Then retrieve from the cluster the resource that triggered this reconciliation.
-		// Store these contents into an object used throughout reconciliation.
-		err := r.Client.Get(context.Background(), req.NamespacedName, pod)
-		if err != nil {
-			if errors.IsNotFound(err) {
-				// If the resource is not found, that is OK. It just means the desired state is to
-				// not have any resources for this Pod but no delete is required.
-				log.Info(fmt.Sprintf(`Pod was deleted for Pod "%s" does not exist, but that's OK, no action is required`, req.Name))
-				return ctrl.Result{}, nil
-			} else {
-				// some other error happend
-				log.Error(err, fmt.Sprintf(`Failed to retrieve pod resource "%s": %s`, req.Name, err.Error()))
-				return ctrl.Result{}, err
-			}
-		}
+	log := log.FromContext(ctx)
 
-		if pod.ObjectMeta.Annotations == nil {
-			// here no scheduled return is needed, since anything modifies a pod (with annotations) Reconcile will catch it
+	pod := &corev1.Pod{}
+	err := r.Client.Get(ctx, req.NamespacedName, pod)
+	if err != nil {
+		if errors.IsNotFound(err) {
+			metrics.SetQuarantined(req.Namespace, req.Name, false)
 			return ctrl.Result{}, nil
 		}
+		log.Error(err, fmt.Sprintf(`Failed to retrieve pod resource "%s": %s`, req.Name, err.Error()))
+		return ctrl.Result{}, err
+	}
 
-		// Does the pod have SecurityEvent on it?
-		if securityEvent, found := pod.ObjectMeta.Annotations[AMTD_SECURITY_EVENT]; found {
-
-			// Filter annotations to find to best action for the security event based on strategies
-			action := getAction(pod.ObjectMeta.Annotations, securityEvent)
-
-			switch action {
-			case "destroy":
-				// Try to delete pod
-				podErr := r.Client.Delete(ctx, pod)
-
-				// Success for this delete is either:
-				// 1. the delete is successful without error
-				// 2. the resource already doesn't exist so delete can't take action
-				if err != nil && !errors.IsNotFound(err) {
-					// If any other error occurs, log it
-					log.Error(podErr, fmt.Sprintf(`Failed to delete pod "%s"`, req.Name))
-				}
-				log.Info(fmt.Sprintf(`Pod: "%s" was sucessfully deleted with ACTION: delete`, pod.Name))
-			default:
-				log.Info(fmt.Sprintf(`ACTION: %s -> POD: %s - NOT IMPLEMENTED YET`, action, pod.Name))
-			}
+	// Every pod event passes here, so this is where the quarantined pods are counted
+	_, quarantined := pod.ObjectMeta.Annotations[AMTD_QUARANTINE]
+	metrics.SetQuarantined(pod.Namespace, pod.Name, quarantined && pod.DeletionTimestamp == nil)
+
+	releaseNote, found := pod.ObjectMeta.Annotations[AMTD_RELEASE]
+	if !found {
+		return ctrl.Result{}, nil
+	}
+
+	// Revert the actions that left a record on the pod
+	revertedActions := []string{}
+	for _, reversible := range []struct {
+		annotation string
+		action     string
+	}{
+		{AMTD_QUARANTINE, actions.Quarantine},
+		{AMTD_DISABLED, actions.Disable},
+	} {
+		if _, found := pod.ObjectMeta.Annotations[reversible.annotation]; !found {
+			continue
 		}
 
-		// here no scheduled return is needed, since anything modifies a pod (with annotations) Reconcile will catch it
-		// return ctrl.Result{RequeueAfter: 10 * time.Second}, nil
-		// TODO: shall we schedule it again if something went wrong? (Should it be handled above?)
-	*/
+		action, found := r.Actions.Get(reversible.action)
+		if !found {
+			log.Info(fmt.Sprintf(`Cannot release pod "%s": ACTION: %s is not registered`, pod.Name, reversible.action))
+			continue
+		}
+
+		err = action.Revert(ctx, &actions.Target{Pod: pod})
+		if err != nil {
+			log.Error(err, fmt.Sprintf(`Failed to revert ACTION: %s on pod "%s"`, reversible.action, pod.Name))
+			r.Recorder.Eventf(pod, corev1.EventTypeWarning, EVENT_REASON_RELEASE_FAILED, "Failed to revert %s: %s", reversible.action, err.Error())
+			return ctrl.Result{}, err
+		}
+		revertedActions = append(revertedActions, reversible.action)
+	}
+
+	// Record the release note and the time of the release, and remove the trigger
+	releaseInfoEncoded, err := json.Marshal(ReleaseInfo{
+		ReleaseNote: releaseNote,
+		ReleasedAt:  time.Now().UTC().Format(time.RFC3339),
+		Actions:     revertedActions,
+	})
+	if err != nil {
+		log.Error(err, fmt.Sprintf(`releaseInfo json encoding does not work: %s`, err.Error()))
+		return ctrl.Result{}, err
+	}
+	pod.ObjectMeta.Annotations[AMTD_RELEASED] = string(releaseInfoEncoded)
+	delete(pod.ObjectMeta.Annotations, AMTD_RELEASE)
+
+	err = r.Client.Update(ctx, pod)
+	if err != nil {
+		log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
+		return ctrl.Result{}, err
+	}
+
+	log.Info(fmt.Sprintf(`Pod "%s" was released (note: "%s"), reverted actions: %v`, pod.Name, releaseNote, revertedActions))
+	r.Recorder.Eventf(pod, corev1.EventTypeNormal, EVENT_REASON_RELEASED, `Released (note: "%s"), reverted actions: %v`, releaseNote, revertedActions)
 	return ctrl.Result{}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *PodReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Actions == nil {
+		r.Actions = NewActionRegistry(r.Client, mgr.GetAPIReader(), r.Scheme)
+	}
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor(EVENT_SOURCE)
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&corev1.Pod{}).
 		Complete(r)
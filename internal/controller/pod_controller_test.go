@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"k8s.io/utils/ptr"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+// releasePod sets the AMTD_RELEASE annotation on the pod and runs a reconciliation of the pod
+func releasePod(t *testing.T, c client.Client, name string, releaseNote string) (*corev1.Pod, []string) {
+	ctx := context.Background()
+	pod := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: name}, pod); err != nil {
+		t.Fatal(err)
+	}
+	pod.Annotations[AMTD_RELEASE] = releaseNote
+	if err := c.Update(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &PodReconciler{Client: c, Scheme: c.Scheme(), Actions: NewActionRegistry(c, c, c.Scheme()), Recorder: recorder}
+	if _, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: client.ObjectKeyFromObject(pod)}); err != nil {
+		t.Fatal(err)
+	}
+	released := &corev1.Pod{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(pod), released); err != nil {
+		t.Fatal(err)
+	}
+	return released, recordedEvents(recorder)
+}
+
+// checkReleaseRecord checks that the release replaced the AMTD_RELEASE trigger with the AMTD_RELEASED record
+func checkReleaseRecord(t *testing.T, pod *corev1.Pod, releaseNote string, reverted []string) {
+	if _, found := pod.Annotations[AMTD_RELEASE]; found {
+		t.Errorf("expected the %s annotation to be removed", AMTD_RELEASE)
+	}
+	var releaseInfo ReleaseInfo
+	if err := json.Unmarshal([]byte(pod.Annotations[AMTD_RELEASED]), &releaseInfo); err != nil {
+		t.Fatal(err)
+	}
+	if releaseInfo.ReleaseNote != releaseNote || releaseInfo.ReleasedAt == "" || !reflect.DeepEqual(releaseInfo.Actions, reverted) {
+		t.Errorf("unexpected release record %+v", releaseInfo)
+	}
+}
+
+func TestPodReleaseFromQuarantine(t *testing.T) {
+	ctx := context.Background()
+	replicaSet := &appsv1.ReplicaSet{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo-5d8f", UID: "rs"},
+		Spec: appsv1.ReplicaSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: map[string]string{"app": "demo", "pod-template-hash": "5d8f"}},
+		},
+	}
+	pod := newTestPod("a", true, nil)
+	pod.Labels["pod-template-hash"] = "5d8f"
+	pod.OwnerReferences = []metav1.OwnerReference{{APIVersion: "apps/v1", Kind: "ReplicaSet", Name: replicaSet.Name, UID: replicaSet.UID, Controller: ptr.To(true)}}
+	pod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: AMTD_READINESS_GATE}}
+	originalLabels := map[string]string{"app": "demo", "pod-template-hash": "5d8f"}
+	c := newTestClient(t, replicaSet, pod)
+
+	action := &quarantineAction{Client: c, Scheme: c.Scheme(), APIReader: c}
+	if _, err := action.Execute(ctx, &actions.Target{Pod: pod, AMTD: newTestAMTD("demo")}); err != nil {
+		t.Fatal(err)
+	}
+
+	released, events := releasePod(t, c, "a", "alice")
+	if !reflect.DeepEqual(released.Labels, originalLabels) {
+		t.Errorf("expected labels %v, got %v", originalLabels, released.Labels)
+	}
+	if !reflect.DeepEqual(released.OwnerReferences, pod.OwnerReferences) {
+		t.Errorf("expected owner references %v, got %v", pod.OwnerReferences, released.OwnerReferences)
+	}
+	if _, found := released.Annotations[AMTD_QUARANTINE]; found {
+		t.Errorf("expected the quarantine record to be removed")
+	}
+	if condition := getPodReadinessGate(released); condition == nil || condition.Status != corev1.ConditionTrue {
+		t.Errorf("expected the readiness gate to be True, got %+v", condition)
+	}
+	if err := c.Get(ctx, client.ObjectKey{Namespace: "default", Name: "default-a-policy"}, &networkingv1.NetworkPolicy{}); !errors.IsNotFound(err) {
+		t.Errorf("expected the NetworkPolicy to be deleted, got %v", err)
+	}
+	checkReleaseRecord(t, released, "alice", []string{actions.Quarantine})
+	if !hasEvent(events, EVENT_REASON_RELEASED) {
+		t.Errorf("expected a %s Event, got %v", EVENT_REASON_RELEASED, events)
+	}
+}
+
+func TestPodReleaseFromDisable(t *testing.T) {
+	ctx := context.Background()
+	service := &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "demo"},
+		Spec:       corev1.ServiceSpec{Selector: map[string]string{"app": "demo", "tier": "web"}},
+	}
+	pod := newTestPod("a", true, nil)
+	pod.Labels["tier"] = "web"
+	pod.Spec.ReadinessGates = []corev1.PodReadinessGate{{ConditionType: AMTD_READINESS_GATE}}
+	c := newTestClient(t, service, pod)
+
+	action := &disableAction{Client: c}
+	if _, err := action.Execute(ctx, &actions.Target{Pod: pod, AMTD: newTestAMTD("demo")}); err != nil {
+		t.Fatal(err)
+	}
+
+	released, events := releasePod(t, c, "a", "bob")
+	if expected := map[string]string{"app": "demo", "tier": "web"}; !reflect.DeepEqual(released.Labels, expected) {
+		t.Errorf("expected labels %v, got %v", expected, released.Labels)
+	}
+	if _, found := released.Annotations[AMTD_DISABLED]; found {
+		t.Errorf("expected the disable record to be removed")
+	}
+	if condition := getPodReadinessGate(released); condition == nil || condition.Status != corev1.ConditionTrue {
+		t.Errorf("expected the readiness gate to be True, got %+v", condition)
+	}
+	checkReleaseRecord(t, released, "bob", []string{actions.Disable})
+	if !hasEvent(events, EVENT_REASON_RELEASED) {
+		t.Errorf("expected a %s Event, got %v", EVENT_REASON_RELEASED, events)
+	}
+}
@@ -0,0 +1,80 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// replaceableOwners are the controllers that release a pod which stops matching their selector and
+// start a replacement for it. StatefulSets are missing on purpose: the replacement would have the
+// same name as the released pod.
+var replaceableOwners = map[string]bool{
+	"apps/v1/ReplicaSet": true,
+	"apps/v1/DaemonSet":  true,
+}
+
+// replaceableOwner returns the controller of the pod and the labels it selects its pods by, or nil
+// if the pod has no controller that would replace it
+func replaceableOwner(ctx context.Context, c client.Client, pod *corev1.Pod) (*metav1.OwnerReference, map[string]string, error) {
+	owner := metav1.GetControllerOf(pod)
+	if owner == nil || !replaceableOwners[owner.APIVersion+"/"+owner.Kind] {
+		return nil, nil, nil
+	}
+
+	controller := &unstructured.Unstructured{}
+	controller.SetAPIVersion(owner.APIVersion)
+	controller.SetKind(owner.Kind)
+	if err := c.Get(ctx, types.NamespacedName{Namespace: pod.Namespace, Name: owner.Name}, controller); err != nil {
+		if errors.IsNotFound(err) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+	if controller.GetUID() != owner.UID {
+		return nil, nil, nil
+	}
+
+	selector, _, err := unstructured.NestedStringMap(controller.Object, "spec", "selector", "matchLabels")
+	if err != nil || len(selector) == 0 {
+		return nil, nil, err
+	}
+	return owner, selector, nil
+}
+
+// replacementLabels returns the labels to remove from the pod so that its owner releases it and
+// starts a replacement, except the ones in kept
+func replacementLabels(pod *corev1.Pod, ownerSelector map[string]string, kept map[string]string) map[string]string {
+	removed := map[string]string{}
+	for key := range ownerSelector {
+		if _, found := kept[key]; found {
+			continue
+		}
+		if value, found := pod.ObjectMeta.Labels[key]; found {
+			removed[key] = value
+		}
+	}
+	return removed
+}
@@ -21,34 +21,57 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
-	"reflect"
+	"slices"
 	"strings"
 	"time"
 
 	corev1 "k8s.io/api/core/v1"
-	v1 "k8s.io/api/networking/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/apimachinery/pkg/types"
-	"k8s.io/utils/strings/slices"
+	kerrors "k8s.io/apimachinery/pkg/util/errors"
+	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
 
 	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/internal/metrics"
+	"github.com/r6security/phoenix/internal/notification"
+	"github.com/r6security/phoenix/pkg/actions"
+	"github.com/r6security/phoenix/pkg/rules"
 )
 
 // SecurityEventReconciler reconciles a SecurityEvent object
 type SecurityEventReconciler struct {
 	client.Client
 	Scheme *runtime.Scheme
+
+	// Actions that can be referred to from AMTD strategies, the built-in actions are used if nil
+	Actions *actions.Registry
+
+	// Resolution decides which strategies are executed when several match, highest-priority if empty
+	Resolution rules.ResolutionMode
+
+	// Notifier reports the outcome of the actions to the NotificationSinks of the AMTDs
+	Notifier *notification.Dispatcher
+
+	// Recorder emits Kubernetes Events about the actions on the pods, SecurityEvents and AMTDs
+	Recorder record.EventRecorder
+
+	// Audit runs every AMTD in audit mode regardless of its mode
+	Audit bool
 }
 
 //+kubebuilder:rbac:groups=amtd.r6security.com,resources=securityevents,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=amtd.r6security.com,resources=securityevents/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=amtd.r6security.com,resources=securityevents/finalizers,verbs=update
-//+kubebuilder:rbac:groups=networking.k8s.io,resources=networkpolicies,verbs=get;list;watch;create
+//+kubebuilder:rbac:groups=amtd.r6security.com,resources=notificationsinks,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get
+//+kubebuilder:rbac:groups=core,resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -77,308 +100,548 @@ func (r *SecurityEventReconciler) Reconcile(ctx context.Context, req ctrl.Reques
 	}
 
 	log.Info(fmt.Sprintf(`SecurityEvent found: "%s, targets: %s"`, securityEvent.Name, securityEvent.Spec.Targets))
+	if securityEvent.Status.Phase == "" {
+		// the status is set at the end of the first reconciliation
+		metrics.RecordSecurityEvent(securityEvent)
+	}
 
 	// ---------------------------------------------------
 	// Process pods in the target list of the SecurityEvent
 	// ---------------------------------------------------
-	var AMTD *amtdv1beta1.AdaptiveMovingTargetDefense
+	// A failing target does not stop processing the others: errors are aggregated
+	// and only the targets that did not reach a final phase are retried later
+	original := securityEvent.DeepCopy()
+	result := ctrl.Result{}
+	var errs []error
 	for _, target := range securityEvent.Spec.Targets {
-		namespace := strings.Split(target, "/")[0]
-		name := strings.Split(target, "/")[1]
-
-		// ---------------------------------------------------
-		// Check that the resource exists and whether to deal with it
-		// ---------------------------------------------------
-		pod := &corev1.Pod{}
-		namespacedName := types.NamespacedName{Namespace: namespace, Name: name}
-		err = r.Client.Get(context.Background(), namespacedName, pod)
+		previous := getTargetStatus(&securityEvent.Status, target)
+		if previous != nil && (previous.Phase == amtdv1beta1.TargetApplied || previous.Phase == amtdv1beta1.TargetIgnored || previous.Phase == amtdv1beta1.TargetAudited) {
+			// the target reached a final phase in an earlier reconciliation
+			continue
+		}
 
+		targetStatus, targetResult, err := r.processTarget(ctx, securityEvent, target, previous)
+		setTargetStatus(&securityEvent.Status, targetStatus)
 		if err != nil {
-			if errors.IsNotFound(err) {
-				log.Info(fmt.Sprintf(`Pod "%s/%s" does not exist`, namespace, name))
-				return ctrl.Result{}, nil
-			} else {
-				// some other error happend
-				log.Error(err, fmt.Sprintf(`Failed to retrieve pod "%s"`, pod.Name))
-				return ctrl.Result{}, err
-			}
+			errs = append(errs, fmt.Errorf(`target "%s": %w`, target, err))
 		}
+		result = lowestRequeue(result, targetResult)
+	}
 
-		// Does the pod have annotation AMTD_MANAGED_BY?
-		if pod.ObjectMeta.Annotations == nil {
-			log.Error(err, fmt.Sprintf(`Pod "%s" is a SecurityEvent target but not AMTD managed`, pod.Name))
-			return ctrl.Result{}, err
-		} else if _, found := pod.ObjectMeta.Annotations[AMTD_MANAGED_BY]; !found {
-			log.Error(err, fmt.Sprintf(`Pod "%s" is a SecurityEvent target but not AMTD managed`, pod.Name))
-			return ctrl.Result{}, err
+	if err := r.updateStatus(ctx, original, securityEvent); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) > 0 {
+		// returning the error makes the controller retry with backoff
+		return ctrl.Result{}, kerrors.NewAggregate(errs)
+	}
+	return result, nil
+}
+
+// lowestRequeue merges the results of two targets so that the earliest requested retry wins
+func lowestRequeue(result ctrl.Result, other ctrl.Result) ctrl.Result {
+	if other.RequeueAfter > 0 && (result.RequeueAfter == 0 || other.RequeueAfter < result.RequeueAfter) {
+		result.RequeueAfter = other.RequeueAfter
+	}
+	return result
+}
+
+// processTarget executes the action of the matching strategy on a single target of the SecurityEvent
+func (r *SecurityEventReconciler) processTarget(ctx context.Context, securityEvent *amtdv1beta1.SecurityEvent, target string, previous *amtdv1beta1.TargetStatus) (amtdv1beta1.TargetStatus, ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	targetStatus := amtdv1beta1.TargetStatus{
+		Target: target,
+		Phase:  amtdv1beta1.TargetPending,
+	}
+	if previous != nil && previous.StartTime != nil {
+		targetStatus.StartTime = previous.StartTime
+	} else {
+		now := metav1.Now()
+		targetStatus.StartTime = &now
+	}
+	if previous != nil && previous.Phase == amtdv1beta1.TargetPending {
+		// the deletion issued by the action in progress is remembered until the pod is gone
+		targetStatus.IssuedTime = previous.IssuedTime
+	}
+	if previous != nil {
+		// the actions that finished are not executed and reported again when the target is retried
+		targetStatus.CompletedActions = previous.CompletedActions
+	}
+	finish := func(phase amtdv1beta1.TargetPhase, message string, err error) amtdv1beta1.TargetStatus {
+		now := metav1.Now()
+		targetStatus.Phase = phase
+		if message != "" {
+			targetStatus.Message = message
+		}
+		if err != nil {
+			targetStatus.Error = err.Error()
 		}
+		targetStatus.CompletionTime = &now
+		return targetStatus
+	}
 
-		// ---------------------------------------------------
-		// Look for the proper action for the SecurityEvent in AMTDs that manage the pod
-		// ---------------------------------------------------
-		var action amtdv1beta1.AMTDAction
-		var AMTDManageInfoList []AMTDManageInfo
-		json.Unmarshal([]byte(pod.ObjectMeta.Annotations[AMTD_MANAGED_BY]), &AMTDManageInfoList)
-		for _, AMTDManageInfo := range AMTDManageInfoList {
-
-			// Get AMTD resource that is in AMTDManageInfo so it belongs to the pod
-			AMTD = &amtdv1beta1.AdaptiveMovingTargetDefense{}
-			err := r.Client.Get(context.Background(), types.NamespacedName{Namespace: AMTDManageInfo.AMTDNamespace, Name: AMTDManageInfo.AMTDName}, AMTD)
-			if err != nil {
-				if errors.IsNotFound(err) {
-					log.Info(fmt.Sprintf(`AdaptiveMovingTargetDefense "%s" does not exist but found in pod annotation`, AMTDManageInfo.AMTDNamespace+"/"+AMTDManageInfo.AMTDName))
-					break
-				} else {
-					log.Error(err, fmt.Sprintf(`Failed to retrieve AdaptiveMovingTargetDefense "%s": %s`, AMTDManageInfo.AMTDName, err.Error()))
-					return ctrl.Result{}, err
-				}
-			}
+	namespace, name, err := amtdv1beta1.ParseTarget(target)
+	if err != nil {
+		// Retrying does not help, the SecurityEvent has to be fixed
+		log.Info(err.Error())
+		return finish(amtdv1beta1.TargetIgnored, "Invalid target", err), ctrl.Result{}, nil
+	}
+
+	// ---------------------------------------------------
+	// Check that the resource exists and whether to deal with it
+	// ---------------------------------------------------
+	pod := &corev1.Pod{}
+	namespacedName := types.NamespacedName{Namespace: namespace, Name: name}
+	err = r.Client.Get(ctx, namespacedName, pod)
 
-			// Check whether there is a specific action to the security event
-			for _, strategy := range AMTD.Spec.Strategy {
-				if reflect.DeepEqual(strategy.Rule, securityEvent.Spec.Rule) {
-					// we found the matching strategy no need to look further
-					action = strategy.Action
-					break
-				}
+	if err != nil {
+		if errors.IsNotFound(err) {
+			if previous != nil && previous.Phase == amtdv1beta1.TargetPending && previous.Action != "" {
+				return r.deletedInProgress(ctx, securityEvent, targetStatus, previous, finish)
 			}
-			// we found the matching strategy no need to look further
-			if action != (amtdv1beta1.AMTDAction{}) {
+			log.Info(fmt.Sprintf(`Pod "%s/%s" does not exist`, namespace, name))
+			return finish(amtdv1beta1.TargetIgnored, "Pod does not exist", nil), ctrl.Result{}, nil
+		} else {
+			// some other error happend
+			log.Error(err, fmt.Sprintf(`Failed to retrieve pod "%s"`, name))
+			return finish(amtdv1beta1.TargetFailed, "", err), ctrl.Result{}, err
+		}
+	}
+
+	// Does the pod have annotation AMTD_MANAGED_BY?
+	if _, found := pod.ObjectMeta.Annotations[AMTD_MANAGED_BY]; !found {
+		log.Info(fmt.Sprintf(`Pod "%s" is a SecurityEvent target but not AMTD managed`, pod.Name))
+		return finish(amtdv1beta1.TargetIgnored, "Pod is not AMTD managed", nil), ctrl.Result{}, nil
+	}
+
+	// ---------------------------------------------------
+	// Look for the proper actions for the SecurityEvent in AMTDs that manage the pod
+	// ---------------------------------------------------
+	matches, err := r.findStrategies(ctx, pod, securityEvent)
+	if err != nil {
+		return finish(amtdv1beta1.TargetFailed, "", err), ctrl.Result{}, err
+	}
+	if len(matches) == 0 {
+		// Fall back to the default actions, they are resolved like the matching strategies
+		matches = r.findDefaultActions(ctx, pod)
+	}
+	if len(matches) > 0 {
+		setTargetMatch(&targetStatus, matches[0])
+	}
+
+	// In audit mode the pod is not modified at all
+	auditOnly := len(matches) > 0
+	for _, match := range matches {
+		if !r.audited(match.AMTD) {
+			auditOnly = false
+		}
+	}
+
+	// ---------------------------------------------------
+	// Add SecurityEvent spec to the annotation of the pod
+	// ---------------------------------------------------
+	var appliedSecurityEvents []amtdv1beta1.SecurityEvent
+	if _, found := pod.ObjectMeta.Annotations[AMTD_APPLIED_SECURITY_EVENTS]; !found {
+		appliedSecurityEvents = append(appliedSecurityEvents, *securityEvent)
+	} else {
+		// Already AMTD member
+		json.Unmarshal([]byte(pod.ObjectMeta.Annotations[AMTD_APPLIED_SECURITY_EVENTS]), &appliedSecurityEvents)
+
+		securityEventExist := false
+		for _, appliedSecurityEvent := range appliedSecurityEvents {
+			if appliedSecurityEvent.Name == securityEvent.Name {
+				securityEventExist = true
 				break
 			}
 		}
 
-		// ---------------------------------------------------
-		// Add SecurityEvent spec to the annotation of the pod
-		// ---------------------------------------------------
-		var appliedSecurityEvents []amtdv1beta1.SecurityEvent
-		if _, found := pod.ObjectMeta.Annotations[AMTD_APPLIED_SECURITY_EVENTS]; !found {
+		if !securityEventExist {
 			appliedSecurityEvents = append(appliedSecurityEvents, *securityEvent)
 		} else {
-			// Already AMTD member
-			json.Unmarshal([]byte(pod.ObjectMeta.Annotations[AMTD_APPLIED_SECURITY_EVENTS]), &appliedSecurityEvents)
-
-			securityEventExist := false
-			for _, appliedSecurityEvent := range appliedSecurityEvents {
-				if appliedSecurityEvent.Name == securityEvent.Name {
-					securityEventExist = true
-					break
-				}
-			}
+			log.Info(fmt.Sprintf(`This SecurityEvent ("%s") was already processed - ignore it`, securityEvent.Name))
+			//return ctrl.Result{}, nil
+		}
+	}
 
-			if !securityEventExist {
-				appliedSecurityEvents = append(appliedSecurityEvents, *securityEvent)
-			} else {
-				log.Info(fmt.Sprintf(`This SecurityEvent ("%s") was already processed - ignore it`, securityEvent.Name))
-				//return ctrl.Result{}, nil
-			}
+	if appliedSecurityEvents != nil && !auditOnly {
+		// the status of the SecurityEvent is not part of the record
+		for i := range appliedSecurityEvents {
+			appliedSecurityEvents[i].Status = amtdv1beta1.SecurityEventStatus{}
 		}
+		appliedSecurityEventsEncoded, err := json.Marshal(appliedSecurityEvents)
+		if err != nil {
+			log.Error(err, fmt.Sprintf(`appliedSecurityEvents json encoding does not work: %s`, err.Error()))
+		}
+		pod.ObjectMeta.Annotations[AMTD_APPLIED_SECURITY_EVENTS] = string(appliedSecurityEventsEncoded)
 
-		if appliedSecurityEvents != nil {
-			appliedSecurityEventsEncoded, err := json.Marshal(appliedSecurityEvents)
-			if err != nil {
-				log.Error(err, fmt.Sprintf(`appliedSecurityEvents json encoding does not work: %s`, err.Error()))
-			}
-			pod.ObjectMeta.Annotations[AMTD_APPLIED_SECURITY_EVENTS] = string(appliedSecurityEventsEncoded)
+		err = r.Client.Update(ctx, pod)
+		if err != nil {
+			log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
+			return finish(amtdv1beta1.TargetFailed, "", err), ctrl.Result{}, err
+		}
+
+		log.Info(fmt.Sprintf(`SecurityEvent was sucessfully applied to the pod`))
+	}
+
+	// ---------------------------------------------------
+	// Execute the proper action
+	// ---------------------------------------------------
+	if len(matches) == 0 {
+		log.Info(fmt.Sprintf(`No matching strategy for SecurityEvent "%s" -> POD: %s`, securityEvent.Name, pod.Name))
+		return finish(amtdv1beta1.TargetIgnored, "No matching strategy", nil), ctrl.Result{}, nil
+	}
 
-			err = r.Client.Update(ctx, pod)
-			if err != nil {
-				log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
-				return ctrl.Result{}, err
+	actionNames := []string{}
+	auditedActions := []string{}
+	for _, match := range matches {
+		action := match.Strategy.Action
+		actionName, actionImpl, err := r.Actions.Resolve(action)
+		actionNames = append(actionNames, actionName)
+		targetStatus.Action = strings.Join(actionNames, ",")
+		if slices.Contains(targetStatus.CompletedActions, actionName) {
+			if r.audited(match.AMTD) {
+				auditedActions = append(auditedActions, actionName)
 			}
+			continue
+		}
+		if err != nil {
+			log.Info(fmt.Sprintf(`ACTION: %v -> POD: %s - NOT IMPLEMENTED YET: %s`, action, pod.Name, err.Error()))
+			r.actionFailed(ctx, match, securityEvent, pod, actionName, err)
+			return finish(amtdv1beta1.TargetFailed, "", err), ctrl.Result{}, retryable(err)
+		}
 
-			log.Info(fmt.Sprintf(`SecurityEvent was sucessfully applied to the pod`))
+		if err := actionImpl.Validate(action); err != nil {
+			log.Error(err, fmt.Sprintf(`Invalid definition of ACTION: %s in AdaptiveMovingTargetDefense "%s"`, actionName, match.AMTD.Namespace+"/"+match.AMTD.Name))
+			r.actionFailed(ctx, match, securityEvent, pod, actionName, err)
+			return finish(amtdv1beta1.TargetFailed, "", err), ctrl.Result{}, retryable(err)
 		}
 
-		// ---------------------------------------------------
-		// Execute the proper action
-		// TODO: replace with Action interface and function call
-		// ---------------------------------------------------
-		if action.Delete != nil {
-			podErr := r.Client.Delete(ctx, pod)
+		// The action is planned in audit mode, but not executed
+		if r.audited(match.AMTD) {
+			log.Info(fmt.Sprintf(`Audit mode: ACTION: %s would be executed on pod "%s"`, actionName, pod.Name))
+			r.actionAudited(ctx, match, securityEvent, pod, actionName)
+			auditedActions = append(auditedActions, actionName)
+			targetStatus.CompletedActions = append(targetStatus.CompletedActions, actionName)
+			continue
+		}
 
-			if err != nil && !errors.IsNotFound(err) {
-				log.Error(podErr, fmt.Sprintf(`Failed to delete pod "%s"`, req.Name))
+		if previous == nil || previous.Phase != amtdv1beta1.TargetPending {
+			// actions in progress are not started again
+			r.Recorder.Eventf(pod, corev1.EventTypeNormal, EVENT_REASON_ACTION_STARTED, `Executing %s in response to SecurityEvent "%s"`, actionName, securityEvent.Name)
+			r.Recorder.Eventf(securityEvent, corev1.EventTypeNormal, EVENT_REASON_ACTION_STARTED, `Executing %s on pod "%s"`, actionName, target)
+		}
+		actionTarget := &actions.Target{
+			Pod:           pod,
+			AMTD:          match.AMTD,
+			SecurityEvent: securityEvent,
+			Spec:          action,
+		}
+		result, err := actionImpl.Execute(ctx, actionTarget)
+		if deferred, ok := actions.IsDeferred(err); ok {
+			// the action stays queued until the disruption limits allow it
+			targetStatus.Message = fmt.Sprintf("Waiting to execute %s: %s", actionName, deferred.Reason)
+			log.Info(fmt.Sprintf(`ACTION: %s on pod "%s" is deferred: %s`, actionName, pod.Name, deferred.Reason))
+			if previous == nil || previous.Message != targetStatus.Message {
+				r.Recorder.Eventf(pod, corev1.EventTypeNormal, EVENT_REASON_ACTION_DEFERRED, `Deferred %s in response to SecurityEvent "%s": %s`, actionName, securityEvent.Name, deferred.Reason)
+				r.Recorder.Eventf(securityEvent, corev1.EventTypeNormal, EVENT_REASON_ACTION_DEFERRED, `Deferred %s on pod "%s": %s`, actionName, target, deferred.Reason)
 			}
-			log.Info(fmt.Sprintf(`Pod: "%s" was sucessfully deleted with ACTION: delete`, pod.Name))
-		} else if action.Debugger != nil {
-			if len(pod.Spec.Containers) == 0 {
-				log.Info("There is no container in the pod which can be used to attach the ephemeral container to")
-				break
+			return targetStatus, ctrl.Result{RequeueAfter: deferred.RetryAfter}, nil
+		}
+		if err != nil {
+			log.Error(err, fmt.Sprintf(`Failed to execute ACTION: %s on pod "%s"`, actionName, pod.Name))
+			r.actionFailed(ctx, match, securityEvent, pod, actionName, err)
+			return finish(amtdv1beta1.TargetFailed, "", err), ctrl.Result{}, err
+		}
+		for _, warning := range actionTarget.Warnings {
+			log.Info(fmt.Sprintf(`ACTION: %s on pod "%s" did not take full effect: %s`, actionName, pod.Name, warning))
+			r.Recorder.Eventf(pod, corev1.EventTypeWarning, EVENT_REASON_ACTION_INCOMPLETE, `%s in response to SecurityEvent "%s" did not take full effect: %s`, actionName, securityEvent.Name, warning)
+			r.Recorder.Eventf(securityEvent, corev1.EventTypeWarning, EVENT_REASON_ACTION_INCOMPLETE, `%s on pod "%s" did not take full effect: %s`, actionName, target, warning)
+		}
+		if len(actionTarget.Warnings) > 0 {
+			targetStatus.Message = strings.Join(actionTarget.Warnings, "; ")
+		}
+		if result.RequeueAfter > 0 {
+			// the action is still in progress, the status points to the strategy it comes from,
+			// so that it can be reported when the pod is gone
+			setTargetMatch(&targetStatus, match)
+			if actionTarget.Issued && targetStatus.IssuedTime == nil {
+				now := metav1.Now()
+				targetStatus.IssuedTime = &now
 			}
+			return targetStatus, result, nil
+		}
+		r.actionCompleted(ctx, match, securityEvent, pod, actionName)
+		targetStatus.CompletedActions = append(targetStatus.CompletedActions, actionName)
+	}
 
-			if action.Debugger.Name == "" {
-				action.Debugger.Name = "amtd-debug-container"
-			}
+	if len(auditedActions) > 0 {
+		message := fmt.Sprintf("Audit mode, not executed: %s", strings.Join(auditedActions, ","))
+		if len(auditedActions) == len(matches) {
+			return finish(amtdv1beta1.TargetAudited, message, nil), ctrl.Result{}, nil
+		}
+		return finish(amtdv1beta1.TargetApplied, message, nil), ctrl.Result{}, nil
+	}
+	return finish(amtdv1beta1.TargetApplied, "", nil), ctrl.Result{}, nil
+}
 
-			ephemeralContainers := make([]string, len(pod.Spec.EphemeralContainers))
-			for _, c := range pod.Spec.EphemeralContainers {
-				ephemeralContainers = append(ephemeralContainers, c.Name)
-			}
+// retryable returns err unless retrying does not help, i.e. the action definition is invalid
+func retryable(err error) error {
+	if actions.IsInvalidSpec(err) {
+		return nil
+	}
+	return err
+}
 
-			if slices.Contains(ephemeralContainers, action.Debugger.Name) {
-				log.Info("Cannot attach a debug container because it is already exists")
-				break
-			}
+// deletedInProgress finishes a target whose pod disappeared while its action was in progress.
+// The action succeeded if it issued the deletion of the pod, otherwise the pod was deleted by
+// someone else before the action could be executed.
+func (r *SecurityEventReconciler) deletedInProgress(ctx context.Context, securityEvent *amtdv1beta1.SecurityEvent, targetStatus amtdv1beta1.TargetStatus, previous *amtdv1beta1.TargetStatus, finish func(amtdv1beta1.TargetPhase, string, error) amtdv1beta1.TargetStatus) (amtdv1beta1.TargetStatus, ctrl.Result, error) {
+	log := log.FromContext(ctx)
 
-			ec := corev1.EphemeralContainer{
-				EphemeralContainerCommon: corev1.EphemeralContainerCommon{
-					Name:  action.Debugger.Name,
-					Image: action.Debugger.Image,
-					Stdin: action.Debugger.Terminal,
-					TTY:   action.Debugger.Terminal,
-				},
-				TargetContainerName: pod.Spec.Containers[0].Name,
-			}
-			pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, ec)
+	targetStatus.AMTD, targetStatus.Rule, targetStatus.Action = previous.AMTD, previous.Rule, previous.Action
+	actionNames := strings.Split(previous.Action, ",")
+	actionName := actionNames[len(actionNames)-1]
+	if previous.IssuedTime == nil {
+		log.Info(fmt.Sprintf(`Pod "%s" was deleted before ACTION: %s was executed`, previous.Target, actionName))
+		return finish(amtdv1beta1.TargetIgnored, fmt.Sprintf("Pod was deleted before %s was executed", actionName), nil), ctrl.Result{}, nil
+	}
+	log.Info(fmt.Sprintf(`Pod "%s" was deleted by ACTION: %s`, previous.Target, actionName))
 
-			err = r.Client.SubResource("ephemeralcontainers").Update(ctx, pod)
+	namespace, name, _ := amtdv1beta1.ParseTarget(previous.AMTD)
+	AMTD := &amtdv1beta1.AdaptiveMovingTargetDefense{}
+	if err := r.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, AMTD); err != nil {
+		if errors.IsNotFound(err) {
+			log.Info(fmt.Sprintf(`AdaptiveMovingTargetDefense "%s" does not exist, the result of ACTION: %s is not reported`, previous.AMTD, actionName))
+			return finish(amtdv1beta1.TargetApplied, "Pod was deleted", nil), ctrl.Result{}, nil
+		}
+		log.Error(err, fmt.Sprintf(`Failed to retrieve AdaptiveMovingTargetDefense "%s": %s`, previous.AMTD, err.Error()))
+		return targetStatus, ctrl.Result{}, err
+	}
 
-			if err != nil {
-				log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
-				break
-			}
-			log.Info("Successfully attached debug container", "containerName", action.Debugger.Name)
-		} else if action.CustomAction != nil {
+	match := strategyMatch{AMTD: AMTD, Strategy: &amtdv1beta1.ResponseStrategy{}, Fallback: previous.Rule == nil}
+	if previous.Rule != nil {
+		match.Strategy.Rule = *previous.Rule
+	}
+	namespace, name, _ = amtdv1beta1.ParseTarget(previous.Target)
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: namespace, Name: name}}
+	r.actionCompleted(ctx, match, securityEvent, pod, actionName)
+	return finish(amtdv1beta1.TargetApplied, "Pod was deleted", nil), ctrl.Result{}, nil
+}
 
-			if len(pod.Spec.Containers) == 0 {
-				log.Info("There is no container in the pod which can be used to attach the ephemeral container to")
-				break
-			}
+// setTargetMatch records the AMTD and the strategy that the action of the target comes from
+func setTargetMatch(targetStatus *amtdv1beta1.TargetStatus, match strategyMatch) {
+	targetStatus.AMTD = match.AMTD.Namespace + "/" + match.AMTD.Name
+	if match.Fallback {
+		targetStatus.Message = "Default action"
+	} else {
+		rule := match.Strategy.Rule
+		targetStatus.Rule = &rule
+	}
+}
 
-			if action.CustomAction.Name == "" {
-				action.CustomAction.Name = "amtd-debug-container"
-			}
+// actionCompleted reports the successful execution of the action and counts it in the status of the AMTD
+func (r *SecurityEventReconciler) actionCompleted(ctx context.Context, match strategyMatch, securityEvent *amtdv1beta1.SecurityEvent, pod *corev1.Pod, actionName string) {
+	r.actionSucceeded(ctx, match, securityEvent, pod, actionName)
+
+	// The counter is informational, failing to update it does not fail the target
+	var err error
+	AMTDKey := types.NamespacedName{Namespace: match.AMTD.Namespace, Name: match.AMTD.Name}
+	if match.Fallback {
+		err = recordDefaultActionExecution(ctx, r.Client, AMTDKey)
+	} else {
+		err = recordStrategyExecution(ctx, r.Client, AMTDKey, match.Strategy.Rule, actionName)
+	}
+	if err != nil {
+		log.FromContext(ctx).Error(err, fmt.Sprintf(`Failed to update status of AdaptiveMovingTargetDefense "%s": %s`, match.AMTD.Name, err.Error()))
+	}
+}
 
-			if action.CustomAction.TargetContainerName == "" {
-				action.CustomAction.TargetContainerName = pod.Spec.Containers[0].Name
-			}
+// audited reports whether the actions of the AMTD are only planned
+func (r *SecurityEventReconciler) audited(AMTD *amtdv1beta1.AdaptiveMovingTargetDefense) bool {
+	return r.Audit || AMTD.Spec.Mode == amtdv1beta1.AMTDAudit
+}
 
-			ephemeralContainers := make([]string, len(pod.Spec.EphemeralContainers))
-			for _, c := range pod.Spec.EphemeralContainers {
-				ephemeralContainers = append(ephemeralContainers, c.Name)
-			}
+// actionSucceeded reports the successful execution of the action with Kubernetes Events, metrics and notifications
+func (r *SecurityEventReconciler) actionSucceeded(ctx context.Context, match strategyMatch, securityEvent *amtdv1beta1.SecurityEvent, pod *corev1.Pod, actionName string) {
+	target := pod.Namespace + "/" + pod.Name
+	r.Recorder.Eventf(pod, corev1.EventTypeNormal, EVENT_REASON_ACTION_SUCCEEDED, `Executed %s in response to SecurityEvent "%s"`, actionName, securityEvent.Name)
+	r.Recorder.Eventf(securityEvent, corev1.EventTypeNormal, EVENT_REASON_ACTION_SUCCEEDED, `Executed %s on pod "%s"`, actionName, target)
+	r.Recorder.Eventf(match.AMTD, corev1.EventTypeNormal, EVENT_REASON_ACTION_SUCCEEDED, `Executed %s on pod "%s" in response to SecurityEvent "%s"`, actionName, pod.Name, securityEvent.Name)
+	metrics.RecordAction(securityEvent, actionName, metrics.RESULT_SUCCEEDED)
+	r.notify(ctx, match, securityEvent, target, actionName, amtdv1beta1.ActionSucceeded, nil)
+}
 
-			if slices.Contains(ephemeralContainers, action.CustomAction.Name) {
-				log.Info("Cannot attach a custom action container because it is already exists")
-				break
-			}
+// actionFailed reports the failed execution of the action with Kubernetes Events, metrics and notifications
+func (r *SecurityEventReconciler) actionFailed(ctx context.Context, match strategyMatch, securityEvent *amtdv1beta1.SecurityEvent, pod *corev1.Pod, actionName string, err error) {
+	target := pod.Namespace + "/" + pod.Name
+	r.Recorder.Eventf(pod, corev1.EventTypeWarning, EVENT_REASON_ACTION_FAILED, `Failed to execute %s in response to SecurityEvent "%s": %s`, actionName, securityEvent.Name, err.Error())
+	r.Recorder.Eventf(securityEvent, corev1.EventTypeWarning, EVENT_REASON_ACTION_FAILED, `Failed to execute %s on pod "%s": %s`, actionName, target, err.Error())
+	r.Recorder.Eventf(match.AMTD, corev1.EventTypeWarning, EVENT_REASON_ACTION_FAILED, `Failed to execute %s on pod "%s" in response to SecurityEvent "%s": %s`, actionName, pod.Name, securityEvent.Name, err.Error())
+	metrics.RecordAction(securityEvent, actionName, metrics.RESULT_FAILED)
+	r.notify(ctx, match, securityEvent, target, actionName, amtdv1beta1.ActionFailed, err)
+}
 
-			ec := corev1.EphemeralContainer{
-				EphemeralContainerCommon: corev1.EphemeralContainerCommon{
-					Name:  action.CustomAction.Name,
-					Image: action.CustomAction.Image,
-					Stdin: action.CustomAction.Stdin,
-					TTY:   action.CustomAction.TTY,
-				},
-				TargetContainerName: pod.Spec.Containers[0].Name,
-			}
-			pod.Spec.EphemeralContainers = append(pod.Spec.EphemeralContainers, ec)
+// actionAudited reports the action that would have been executed with Kubernetes Events, metrics and notifications
+func (r *SecurityEventReconciler) actionAudited(ctx context.Context, match strategyMatch, securityEvent *amtdv1beta1.SecurityEvent, pod *corev1.Pod, actionName string) {
+	target := pod.Namespace + "/" + pod.Name
+	r.Recorder.Eventf(pod, corev1.EventTypeNormal, EVENT_REASON_ACTION_AUDITED, `Would execute %s in response to SecurityEvent "%s" (audit mode)`, actionName, securityEvent.Name)
+	r.Recorder.Eventf(securityEvent, corev1.EventTypeNormal, EVENT_REASON_ACTION_AUDITED, `Would execute %s on pod "%s" (audit mode)`, actionName, target)
+	r.Recorder.Eventf(match.AMTD, corev1.EventTypeNormal, EVENT_REASON_ACTION_AUDITED, `Would execute %s on pod "%s" in response to SecurityEvent "%s" (audit mode)`, actionName, pod.Name, securityEvent.Name)
+	metrics.RecordAction(securityEvent, actionName, metrics.RESULT_AUDITED)
+	r.notify(ctx, match, securityEvent, target, actionName, amtdv1beta1.ActionAudited, nil)
+}
 
-			err = r.Client.SubResource("ephemeralcontainers").Update(ctx, pod)
+// notify reports the outcome of the action on the target to the NotificationSinks of the AMTD
+func (r *SecurityEventReconciler) notify(ctx context.Context, match strategyMatch, securityEvent *amtdv1beta1.SecurityEvent, target string, actionName string, outcome amtdv1beta1.ActionOutcome, err error) {
+	if r.Notifier == nil || len(match.AMTD.Spec.NotificationSinks) == 0 {
+		return
+	}
 
-			if err != nil {
-				log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
-				break
-			}
-			log.Info("Successfully attached custom action container", "containerName", action.CustomAction.Name)
-		} else if action.Quarantine != nil {
-			networkPolicyName := fmt.Sprintf("%s-%s-%s", pod.Namespace, pod.Name, "policy")
-
-			networkPolicy := &v1.NetworkPolicy{}
-			err = r.Client.Get(context.Background(), types.NamespacedName{
-				Namespace: pod.Namespace,
-				Name:      networkPolicyName,
-			}, networkPolicy)
-
-			if err != nil && errors.IsNotFound(err) {
-				networkPolicy := &v1.NetworkPolicy{
-					ObjectMeta: metav1.ObjectMeta{
-						Name:      networkPolicyName,
-						Namespace: pod.Namespace,
-					},
-					Spec: v1.NetworkPolicySpec{
-						PodSelector: metav1.LabelSelector{
-							MatchLabels: map[string]string{AMTD_NETWORK_POLICY: networkPolicyName},
-						},
-						Ingress: []v1.NetworkPolicyIngressRule{},
-						Egress:  []v1.NetworkPolicyEgressRule{},
-						PolicyTypes: []v1.PolicyType{
-							v1.PolicyTypeIngress,
-							v1.PolicyTypeEgress,
-						},
-					},
-				}
-
-				// Set AMTD instance as the owner and controller for the NetworkPolicy
-				err := ctrl.SetControllerReference(AMTD, networkPolicy, r.Scheme)
-				if err != nil {
-					log.Error(err, "Failed to set AMTD as owner and controller reference on NetworkPolicy",
-						"AMTD", AMTD.ObjectMeta.Name,
-						"NetworkPolicy", networkPolicy.Name,
-						"Namespace", networkPolicy.Namespace,
-					)
-				}
-
-				err = r.Create(ctx, networkPolicy)
-				if err != nil {
-					log.Error(err, "Failed to create Networkpolicy in the cluster",
-						"NetworkPolicy", networkPolicy.Name,
-						"Namespace", networkPolicy.Namespace)
-				}
-
-				// Relabel pod:
-				// i) move labels under annotations to preserve them - except those that belong to AMTD management,
-				for key, value := range pod.ObjectMeta.Labels {
-					if !isSetContain(AMTD.Spec.PodSelector, map[string]string{key: value}) {
-						pod.ObjectMeta.Annotations[key] = value
-						delete(pod.ObjectMeta.Labels, key)
-					}
-				}
-				// ii) add new label that match networkPolicy podSelector
-				pod.ObjectMeta.Labels[AMTD_NETWORK_POLICY] = networkPolicyName
-
-				err = r.Client.Update(ctx, pod)
-				if err != nil {
-					log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
-					return ctrl.Result{}, err
-				}
-
-				log.Info(fmt.Sprintf(`Pod %s was put in quarantine`, pod.Name))
-			}
+	message := notification.Notification{
+		Time:          time.Now(),
+		Outcome:       outcome,
+		Action:        actionName,
+		Target:        target,
+		SecurityEvent: securityEvent.Name,
+		Rule:          securityEvent.Spec.Rule,
+		Description:   securityEvent.Spec.Description,
+		AMTD:          match.AMTD.Namespace + "/" + match.AMTD.Name,
+	}
+	if !match.Fallback {
+		rule := match.Strategy.Rule
+		message.Strategy = &rule
+	}
+	if err != nil {
+		message.Error = err.Error()
+	}
+	r.Notifier.Notify(ctx, match.AMTD, message)
+}
 
-			// Set AMTD instance as the owner and controller for the Pod - this
-			// step cannot combined into a single update with relabel, because
-			// until relabel another owner exists that cannot be updated
-			// Actually since it's not immediate that OwnerReference is deleted
-			// by ReplicaSet or sg. we need to reschedule and check it later
-			err = ctrl.SetControllerReference(AMTD, pod, r.Scheme)
-			if err != nil {
-				log.Error(err, "Failed to set AMTD as owner and controller reference on Pod - Rescheduling and trying later",
-					"AMTD", AMTD.ObjectMeta.Name,
-					"Pod", pod.Name,
-					"Namespace", networkPolicy.Namespace,
-				)
-				return ctrl.Result{RequeueAfter: 2 * time.Second}, nil
+// strategyMatch is a strategy of an AMTD selected for a SecurityEvent
+type strategyMatch struct {
+	AMTD     *amtdv1beta1.AdaptiveMovingTargetDefense
+	Strategy *amtdv1beta1.ResponseStrategy
+	// Fallback is true if the strategy is the default action of the AMTD
+	Fallback bool
+}
+
+// findStrategies collects the strategies matching the SecurityEvent in the AMTDs that manage the pod
+// and returns the ones to execute according to the resolution mode, in the order of execution
+func (r *SecurityEventReconciler) findStrategies(ctx context.Context, pod *corev1.Pod, securityEvent *amtdv1beta1.SecurityEvent) ([]strategyMatch, error) {
+	log := log.FromContext(ctx)
+
+	matches := []strategyMatch{}
+	candidates := []rules.Candidate{}
+
+	var AMTDManageInfoList []AMTDManageInfo
+	json.Unmarshal([]byte(pod.ObjectMeta.Annotations[AMTD_MANAGED_BY]), &AMTDManageInfoList)
+	for _, AMTDManageInfo := range AMTDManageInfoList {
+
+		// Get AMTD resource that is in AMTDManageInfo so it belongs to the pod
+		AMTD := &amtdv1beta1.AdaptiveMovingTargetDefense{}
+		err := r.Client.Get(ctx, types.NamespacedName{Namespace: AMTDManageInfo.AMTDNamespace, Name: AMTDManageInfo.AMTDName}, AMTD)
+		if err != nil {
+			if errors.IsNotFound(err) {
+				log.Info(fmt.Sprintf(`AdaptiveMovingTargetDefense "%s" does not exist but found in pod annotation`, AMTDManageInfo.AMTDNamespace+"/"+AMTDManageInfo.AMTDName))
+				continue
+			} else {
+				log.Error(err, fmt.Sprintf(`Failed to retrieve AdaptiveMovingTargetDefense "%s": %s`, AMTDManageInfo.AMTDName, err.Error()))
+				return nil, err
 			}
+		}
 
-			err = r.Client.Update(ctx, pod)
-			if err != nil {
-				log.Error(err, fmt.Sprintf(`Failed to update pod: "%s": %s`, pod.Name, err.Error()))
-				return ctrl.Result{}, err
+		// Collect the strategies whose rule matches the security event
+		for i, strategy := range AMTD.Spec.Strategy {
+			score, ok := rules.Score(strategy.Rule, securityEvent.Spec.Rule)
+			if !ok {
+				continue
 			}
-		} else {
-			log.Info(fmt.Sprintf(`ACTION: %v -> POD: %s - NOT IMPLEMENTED YET`, action, pod.Name))
+			actionName := actions.NameOf(strategy.Action)
+			matches = append(matches, strategyMatch{AMTD: AMTD, Strategy: &AMTD.Spec.Strategy[i]})
+			candidates = append(candidates, rules.Candidate{
+				AMTDPriority: AMTD.Spec.Priority,
+				Priority:     strategy.Priority,
+				Score:        score,
+				Action:       actionName,
+				Severity:     actions.Severity(actionName),
+				Order:        len(candidates),
+			})
+		}
+	}
+
+	selected := []strategyMatch{}
+	for _, index := range rules.Resolve(r.Resolution, candidates) {
+		selected = append(selected, matches[index])
+	}
+	return selected, nil
+}
+
+// findDefaultActions collects the default actions of the AMTDs that manage the pod and returns the
+// ones to execute according to the resolution mode, in the order of execution
+func (r *SecurityEventReconciler) findDefaultActions(ctx context.Context, pod *corev1.Pod) []strategyMatch {
+	matches := []strategyMatch{}
+	candidates := []rules.Candidate{}
+
+	var AMTDManageInfoList []AMTDManageInfo
+	json.Unmarshal([]byte(pod.ObjectMeta.Annotations[AMTD_MANAGED_BY]), &AMTDManageInfoList)
+	for _, AMTDManageInfo := range AMTDManageInfoList {
+		AMTD := &amtdv1beta1.AdaptiveMovingTargetDefense{}
+		err := r.Client.Get(ctx, types.NamespacedName{Namespace: AMTDManageInfo.AMTDNamespace, Name: AMTDManageInfo.AMTDName}, AMTD)
+		if err != nil {
+			// findStrategies already reported the AMTDs that cannot be retrieved
+			continue
 		}
+		if AMTD.Spec.DefaultAction == nil {
+			continue
+		}
+		actionName := actions.NameOf(*AMTD.Spec.DefaultAction)
+		matches = append(matches, strategyMatch{AMTD: AMTD, Strategy: &amtdv1beta1.ResponseStrategy{Action: *AMTD.Spec.DefaultAction}, Fallback: true})
+		candidates = append(candidates, rules.Candidate{
+			AMTDPriority: AMTD.Spec.Priority,
+			Action:       actionName,
+			Severity:     actions.Severity(actionName),
+			Order:        len(candidates),
+		})
+	}
+
+	selected := []strategyMatch{}
+	for _, index := range rules.Resolve(r.Resolution, candidates) {
+		log.FromContext(ctx).Info(fmt.Sprintf(`No matching rule, using the default action of AdaptiveMovingTargetDefense "%s"`, matches[index].AMTD.Namespace+"/"+matches[index].AMTD.Name))
+		selected = append(selected, matches[index])
 	}
+	return selected
+}
+
+// updateStatus computes the phase and conditions of the SecurityEvent and patches its status
+func (r *SecurityEventReconciler) updateStatus(ctx context.Context, original *amtdv1beta1.SecurityEvent, securityEvent *amtdv1beta1.SecurityEvent) error {
+	summarizeSecurityEventStatus(securityEvent)
 
-	return ctrl.Result{}, nil
+	err := r.Client.Status().Patch(ctx, securityEvent, client.MergeFrom(original))
+	if err != nil {
+		log.FromContext(ctx).Error(err, fmt.Sprintf(`Failed to update status of SecurityEvent "%s": %s`, securityEvent.Name, err.Error()))
+	}
+	return err
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *SecurityEventReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Actions == nil {
+		r.Actions = NewActionRegistry(r.Client, mgr.GetAPIReader(), r.Scheme)
+	}
+	if r.Resolution == "" {
+		r.Resolution = rules.HighestPriority
+	}
+	if r.Notifier == nil {
+		r.Notifier = notification.NewDispatcher(r.Client, mgr.GetAPIReader())
+	}
+	if r.Recorder == nil {
+		r.Recorder = mgr.GetEventRecorderFor(EVENT_SOURCE)
+	}
+
+	// Status updates must not trigger a new reconciliation, otherwise actions would be executed again
 	return ctrl.NewControllerManagedBy(mgr).
-		For(&amtdv1beta1.SecurityEvent{}).
+		For(&amtdv1beta1.SecurityEvent{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
 		Complete(r)
 }
 
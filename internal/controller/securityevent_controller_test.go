@@ -0,0 +1,407 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+	"github.com/r6security/phoenix/pkg/rules"
+)
+
+// newTestAMTD returns an AMTD in the default namespace that selects the pods of newTestPod
+func newTestAMTD(name string, strategies ...amtdv1beta1.ResponseStrategy) *amtdv1beta1.AdaptiveMovingTargetDefense {
+	return &amtdv1beta1.AdaptiveMovingTargetDefense{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: amtdv1beta1.AdaptiveMovingTargetDefenseSpec{
+			PodSelector: map[string]string{"app": "demo"},
+			Strategy:    strategies,
+		},
+	}
+}
+
+// newManagedTestPod returns a pod managed by the given AMTDs
+func newManagedTestPod(t *testing.T, name string, AMTDs ...*amtdv1beta1.AdaptiveMovingTargetDefense) *corev1.Pod {
+	manageInfo := []AMTDManageInfo{}
+	for _, AMTD := range AMTDs {
+		manageInfo = append(manageInfo, AMTDManageInfo{ManagedSince: time.Now().UTC().Format(time.RFC3339), AMTDNamespace: AMTD.Namespace, AMTDName: AMTD.Name})
+	}
+	encoded, err := json.Marshal(manageInfo)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return newTestPod(name, true, map[string]string{AMTD_MANAGED_BY: string(encoded)})
+}
+
+// newTestSecurityEvent returns a SecurityEvent of the given rule type on the pods of the default namespace
+func newTestSecurityEvent(name string, ruleType string, pods ...string) *amtdv1beta1.SecurityEvent {
+	targets := []string{}
+	for _, pod := range pods {
+		targets = append(targets, "default/"+pod)
+	}
+	return &amtdv1beta1.SecurityEvent{
+		ObjectMeta: metav1.ObjectMeta{Name: name},
+		Spec: amtdv1beta1.SecurityEventSpec{
+			Targets:     targets,
+			Rule:        amtdv1beta1.Rule{Type: ruleType, ThreatLevel: "warning", Source: "test"},
+			Description: "test",
+		},
+	}
+}
+
+func newTestSecurityEventReconciler(c client.Client) (*SecurityEventReconciler, *record.FakeRecorder) {
+	recorder := record.NewFakeRecorder(100)
+	return &SecurityEventReconciler{
+		Client:     c,
+		Scheme:     c.Scheme(),
+		Actions:    NewActionRegistry(c, c, c.Scheme()),
+		Resolution: rules.HighestPriority,
+		Recorder:   recorder,
+	}, recorder
+}
+
+// reconcileSecurityEvent runs a reconciliation of the SecurityEvent and returns its status afterwards
+func reconcileSecurityEvent(t *testing.T, r *SecurityEventReconciler, name string) (amtdv1beta1.SecurityEventStatus, ctrl.Result, error) {
+	ctx := context.Background()
+	result, err := r.Reconcile(ctx, ctrl.Request{NamespacedName: types.NamespacedName{Name: name}})
+	securityEvent := &amtdv1beta1.SecurityEvent{}
+	if getErr := r.Client.Get(ctx, types.NamespacedName{Name: name}, securityEvent); getErr != nil {
+		t.Fatal(getErr)
+	}
+	return securityEvent.Status, result, err
+}
+
+// recordedEvents drains the Events recorded so far
+func recordedEvents(recorder *record.FakeRecorder) []string {
+	events := []string{}
+	for {
+		select {
+		case event := <-recorder.Events:
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func hasEvent(events []string, reason string) bool {
+	for _, event := range events {
+		if strings.Contains(event, " "+reason+" ") {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSecurityEventFinishesIssuedDelete(t *testing.T) {
+	ctx := context.Background()
+	AMTD := newTestAMTD("demo", amtdv1beta1.ResponseStrategy{
+		Rule:   amtdv1beta1.Rule{Type: "exec"},
+		Action: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{ForceAfter: &metav1.Duration{Duration: time.Minute}}},
+	})
+	c := newTestClient(t, AMTD, newManagedTestPod(t, "a", AMTD), newTestSecurityEvent("event", "exec", "a"))
+	r, recorder := newTestSecurityEventReconciler(c)
+
+	// the pod is evicted, the action waits for it to be gone
+	status, result, err := reconcileSecurityEvent(t, r, "event")
+	if err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("expected the delete to be in progress, got %v, %v", result, err)
+	}
+	if phase := status.Targets[0].Phase; phase != amtdv1beta1.TargetPending || status.Targets[0].IssuedTime == nil {
+		t.Fatalf("expected a pending target with the issued eviction, got %+v", status.Targets[0])
+	}
+	if events := recordedEvents(recorder); hasEvent(events, EVENT_REASON_ACTION_SUCCEEDED) {
+		t.Errorf("expected no %s Event while the delete is in progress, got %v", EVENT_REASON_ACTION_SUCCEEDED, events)
+	}
+
+	// the pod is gone, the delete is reported like any other action
+	status, _, err = reconcileSecurityEvent(t, r, "event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if phase := status.Targets[0].Phase; phase != amtdv1beta1.TargetApplied {
+		t.Errorf("expected phase %s, got %+v", amtdv1beta1.TargetApplied, status.Targets[0])
+	}
+	if events := recordedEvents(recorder); !hasEvent(events, EVENT_REASON_ACTION_SUCCEEDED) {
+		t.Errorf("expected a %s Event, got %v", EVENT_REASON_ACTION_SUCCEEDED, events)
+	}
+	updated := &amtdv1beta1.AdaptiveMovingTargetDefense{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(AMTD), updated); err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Status.Strategies) != 1 || updated.Status.Strategies[0].Executions != 1 {
+		t.Errorf("expected the execution of the strategy to be recorded, got %+v", updated.Status.Strategies)
+	}
+}
+
+func TestSecurityEventIgnoresPodDeletedWhileDeferred(t *testing.T) {
+	ctx := context.Background()
+	AMTD := newTestAMTD("demo", amtdv1beta1.ResponseStrategy{
+		Rule:   amtdv1beta1.Rule{Type: "exec"},
+		Action: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{}},
+	})
+	minAvailable := intstr.FromInt32(1)
+	AMTD.Spec.Disruption = &amtdv1beta1.DisruptionLimits{MinAvailable: &minAvailable}
+	pod := newManagedTestPod(t, "a", AMTD)
+	c := newTestClient(t, AMTD, pod, newTestSecurityEvent("event", "exec", "a"))
+	r, recorder := newTestSecurityEventReconciler(c)
+
+	// deleting the only pod would violate minAvailable
+	status, result, err := reconcileSecurityEvent(t, r, "event")
+	if err != nil || result.RequeueAfter == 0 {
+		t.Fatalf("expected the delete to be deferred, got %v, %v", result, err)
+	}
+	if target := status.Targets[0]; target.Phase != amtdv1beta1.TargetPending || target.IssuedTime != nil {
+		t.Fatalf("expected a pending target without issued deletion, got %+v", target)
+	}
+
+	// someone else deletes the pod before the delete action could run
+	if err := c.Delete(ctx, pod); err != nil {
+		t.Fatal(err)
+	}
+	recordedEvents(recorder)
+	status, _, err = reconcileSecurityEvent(t, r, "event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target := status.Targets[0]; target.Phase != amtdv1beta1.TargetIgnored || !strings.Contains(target.Message, "deleted before delete") {
+		t.Errorf("expected the target to be ignored, got %+v", target)
+	}
+	if events := recordedEvents(recorder); hasEvent(events, EVENT_REASON_ACTION_SUCCEEDED) {
+		t.Errorf("expected no %s Event, got %v", EVENT_REASON_ACTION_SUCCEEDED, events)
+	}
+	updated := &amtdv1beta1.AdaptiveMovingTargetDefense{}
+	if err := c.Get(ctx, client.ObjectKeyFromObject(AMTD), updated); err != nil {
+		t.Fatal(err)
+	}
+	if len(updated.Status.Strategies) != 0 {
+		t.Errorf("expected no execution to be recorded, got %+v", updated.Status.Strategies)
+	}
+}
+
+// countingAction is a plugin action that counts its executions, the first pending ones ask to be
+// executed again later
+type countingAction struct {
+	executions int
+	pending    int
+}
+
+func (a *countingAction) Validate(spec amtdv1beta1.AMTDAction) error {
+	return nil
+}
+
+func (a *countingAction) Execute(ctx context.Context, target *actions.Target) (ctrl.Result, error) {
+	a.executions++
+	if a.executions <= a.pending {
+		return ctrl.Result{RequeueAfter: time.Second}, nil
+	}
+	return ctrl.Result{}, nil
+}
+
+func (a *countingAction) Revert(ctx context.Context, target *actions.Target) error {
+	return actions.ErrNotRevertible
+}
+
+func TestSecurityEventStatusOfMixedTargets(t *testing.T) {
+	AMTD := newTestAMTD("demo", amtdv1beta1.ResponseStrategy{
+		Rule:   amtdv1beta1.Rule{Type: "exec"},
+		Action: amtdv1beta1.AMTDAction{Disable: &amtdv1beta1.DisableAction{}},
+	})
+	// the API server rejects the first update of pod b
+	failed := false
+	c := interceptor.NewClient(newTestClient(t, AMTD, newManagedTestPod(t, "a", AMTD), newManagedTestPod(t, "b", AMTD), newTestSecurityEvent("event", "exec", "a", "b")).(client.WithWatch), interceptor.Funcs{
+		Update: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.UpdateOption) error {
+			if _, isPod := obj.(*corev1.Pod); isPod && obj.GetName() == "b" && !failed {
+				failed = true
+				return errors.New("etcdserver: request timed out")
+			}
+			return c.Update(ctx, obj, opts...)
+		},
+	})
+	r, recorder := newTestSecurityEventReconciler(c)
+
+	status, _, err := reconcileSecurityEvent(t, r, "event")
+	if err == nil {
+		t.Fatalf("expected the failure of pod b to be retried")
+	}
+	if status.Phase != amtdv1beta1.SecurityEventPartiallyFailed {
+		t.Errorf("expected phase %s, got %s", amtdv1beta1.SecurityEventPartiallyFailed, status.Phase)
+	}
+	if len(status.Targets) != 2 || status.Targets[0].Phase != amtdv1beta1.TargetApplied || status.Targets[1].Phase != amtdv1beta1.TargetFailed || status.Targets[1].Error == "" {
+		t.Fatalf("expected pod a to be applied and pod b to fail, got %+v", status.Targets)
+	}
+	recordedEvents(recorder)
+
+	// the retry only processes the failed target
+	status, _, err = reconcileSecurityEvent(t, r, "event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Phase != amtdv1beta1.SecurityEventApplied || status.Targets[1].Phase != amtdv1beta1.TargetApplied {
+		t.Errorf("expected every target to be applied, got %s %+v", status.Phase, status.Targets)
+	}
+	for _, event := range recordedEvents(recorder) {
+		if strings.Contains(event, `"default/a"`) {
+			t.Errorf("expected pod a not to be processed again, got %s", event)
+		}
+	}
+}
+
+func TestSecurityEventRetriesActionsThatCannotBeResolved(t *testing.T) {
+	AMTD := newTestAMTD("demo", amtdv1beta1.ResponseStrategy{
+		Rule:   amtdv1beta1.Rule{Type: "exec"},
+		Action: amtdv1beta1.AMTDAction{Plugin: &amtdv1beta1.PluginAction{Name: "notify"}},
+	})
+	c := newTestClient(t, AMTD, newManagedTestPod(t, "a", AMTD), newTestSecurityEvent("event", "exec", "a"))
+	r, _ := newTestSecurityEventReconciler(c)
+
+	// the plugin is not registered yet
+	status, _, err := reconcileSecurityEvent(t, r, "event")
+	if err == nil || status.Targets[0].Phase != amtdv1beta1.TargetFailed {
+		t.Fatalf("expected a failed target that is retried, got %+v, %v", status.Targets[0], err)
+	}
+
+	plugin := &countingAction{}
+	if err := r.Actions.Register("notify", plugin); err != nil {
+		t.Fatal(err)
+	}
+	status, _, err = reconcileSecurityEvent(t, r, "event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if status.Targets[0].Phase != amtdv1beta1.TargetApplied || status.Targets[0].Error != "" || plugin.executions != 1 {
+		t.Errorf("expected the plugin to be executed by the retry, got %+v, %d executions", status.Targets[0], plugin.executions)
+	}
+}
+
+func TestSecurityEventDoesNotRetryInvalidActions(t *testing.T) {
+	AMTD := newTestAMTD("demo", amtdv1beta1.ResponseStrategy{
+		Rule:   amtdv1beta1.Rule{Type: "exec"},
+		Action: amtdv1beta1.AMTDAction{Debugger: &amtdv1beta1.Debugger{}},
+	})
+	c := newTestClient(t, AMTD, newManagedTestPod(t, "a", AMTD), newTestSecurityEvent("event", "exec", "a"))
+	r, _ := newTestSecurityEventReconciler(c)
+
+	status, result, err := reconcileSecurityEvent(t, r, "event")
+	if err != nil || result.RequeueAfter != 0 {
+		t.Errorf("expected no retry of an invalid action, got %v, %v", result, err)
+	}
+	if target := status.Targets[0]; target.Phase != amtdv1beta1.TargetFailed || !strings.Contains(target.Error, "image must not be empty") {
+		t.Errorf("expected a failed target, got %+v", target)
+	}
+}
+
+func TestSecurityEventResolvesDefaultActions(t *testing.T) {
+	first := newTestAMTD("first")
+	first.Spec.DefaultAction = &amtdv1beta1.AMTDAction{Disable: &amtdv1beta1.DisableAction{}}
+	second := newTestAMTD("second")
+	second.Spec.Priority = 10
+	second.Spec.DefaultAction = &amtdv1beta1.AMTDAction{Plugin: &amtdv1beta1.PluginAction{Name: "notify"}}
+	c := newTestClient(t, first, second, newManagedTestPod(t, "a", first, second), newTestSecurityEvent("event", "exec", "a"))
+	r, _ := newTestSecurityEventReconciler(c)
+	plugin := &countingAction{}
+	if err := r.Actions.Register("notify", plugin); err != nil {
+		t.Fatal(err)
+	}
+
+	// the AMTD with the higher priority wins even though it manages the pod later
+	status, _, err := reconcileSecurityEvent(t, r, "event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target := status.Targets[0]; target.Phase != amtdv1beta1.TargetApplied || target.AMTD != "default/second" || plugin.executions != 1 {
+		t.Errorf("expected the default action of AMTD second to be executed, got %+v, %d executions", target, plugin.executions)
+	}
+	pod := &corev1.Pod{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "a"}, pod); err != nil {
+		t.Fatal(err)
+	}
+	if _, disabled := pod.Annotations[AMTD_DISABLED]; disabled {
+		t.Errorf("expected the default action of AMTD first not to be executed")
+	}
+}
+
+func TestSecurityEventDoesNotRepeatCompletedActions(t *testing.T) {
+	AMTD := newTestAMTD("demo", amtdv1beta1.ResponseStrategy{
+		Rule:     amtdv1beta1.Rule{Type: "exec"},
+		Priority: 10,
+		Action:   amtdv1beta1.AMTDAction{Plugin: &amtdv1beta1.PluginAction{Name: "notify"}},
+	}, amtdv1beta1.ResponseStrategy{
+		Rule:   amtdv1beta1.Rule{Type: "exec"},
+		Action: amtdv1beta1.AMTDAction{Plugin: &amtdv1beta1.PluginAction{Name: "wait"}},
+	})
+	c := newTestClient(t, AMTD, newManagedTestPod(t, "a", AMTD), newTestSecurityEvent("event", "exec", "a"))
+	r, recorder := newTestSecurityEventReconciler(c)
+	r.Resolution = rules.RunAll
+	notify, wait := &countingAction{}, &countingAction{pending: 1}
+	if err := r.Actions.Register("notify", notify); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Actions.Register("wait", wait); err != nil {
+		t.Fatal(err)
+	}
+
+	// wait keeps the target pending after notify finished
+	status, result, err := reconcileSecurityEvent(t, r, "event")
+	if err != nil || result.RequeueAfter == 0 || status.Targets[0].Phase != amtdv1beta1.TargetPending {
+		t.Fatalf("expected a pending target, got %+v, %v, %v", status.Targets[0], result, err)
+	}
+	status, _, err = reconcileSecurityEvent(t, r, "event")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if target := status.Targets[0]; target.Phase != amtdv1beta1.TargetApplied || len(target.CompletedActions) != 2 {
+		t.Errorf("expected both actions to be completed, got %+v", target)
+	}
+	if notify.executions != 1 || wait.executions != 2 {
+		t.Errorf("expected notify to be executed once and wait twice, got %d and %d", notify.executions, wait.executions)
+	}
+	succeeded := 0
+	for _, event := range recordedEvents(recorder) {
+		if strings.Contains(event, EVENT_REASON_ACTION_SUCCEEDED) && strings.Contains(event, "Executed notify") {
+			succeeded++
+		}
+	}
+	if succeeded != 3 {
+		t.Errorf("expected the success of notify to be reported once on the pod, the SecurityEvent and the AMTD, got %d events", succeeded)
+	}
+	AMTD = &amtdv1beta1.AdaptiveMovingTargetDefense{}
+	if err := c.Get(context.Background(), types.NamespacedName{Namespace: "default", Name: "demo"}, AMTD); err != nil {
+		t.Fatal(err)
+	}
+	for _, strategy := range AMTD.Status.Strategies {
+		if strategy.Executions != 1 {
+			t.Errorf("expected every strategy to be counted once, got %+v", AMTD.Status.Strategies)
+		}
+	}
+}
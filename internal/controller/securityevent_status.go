@@ -0,0 +1,123 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package controller
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// getTargetStatus returns the status of target, nil if the target has no status yet
+func getTargetStatus(status *amtdv1beta1.SecurityEventStatus, target string) *amtdv1beta1.TargetStatus {
+	for i := range status.Targets {
+		if status.Targets[i].Target == target {
+			return &status.Targets[i]
+		}
+	}
+	return nil
+}
+
+// setTargetStatus adds targetStatus to status or replaces the existing status of the same target
+func setTargetStatus(status *amtdv1beta1.SecurityEventStatus, targetStatus amtdv1beta1.TargetStatus) {
+	if existing := getTargetStatus(status, targetStatus.Target); existing != nil {
+		*existing = targetStatus
+		return
+	}
+	status.Targets = append(status.Targets, targetStatus)
+}
+
+// summarizeSecurityEventStatus sets the phase and the conditions of the SecurityEvent
+// based on the status of its targets
+func summarizeSecurityEventStatus(securityEvent *amtdv1beta1.SecurityEvent) {
+	status := &securityEvent.Status
+
+	counts := map[amtdv1beta1.TargetPhase]int{}
+	for _, target := range securityEvent.Spec.Targets {
+		targetStatus := getTargetStatus(status, target)
+		if targetStatus == nil {
+			counts[amtdv1beta1.TargetPending]++
+			continue
+		}
+		counts[targetStatus.Phase]++
+	}
+	pending := counts[amtdv1beta1.TargetPending]
+	applied := counts[amtdv1beta1.TargetApplied]
+	failed := counts[amtdv1beta1.TargetFailed]
+	ignored := counts[amtdv1beta1.TargetIgnored]
+	audited := counts[amtdv1beta1.TargetAudited]
+
+	switch {
+	case pending > 0 && pending == len(securityEvent.Spec.Targets) && len(status.Targets) == 0:
+		status.Phase = amtdv1beta1.SecurityEventPending
+	case pending > 0:
+		status.Phase = amtdv1beta1.SecurityEventProcessing
+	case failed > 0:
+		status.Phase = amtdv1beta1.SecurityEventPartiallyFailed
+	case applied > 0:
+		status.Phase = amtdv1beta1.SecurityEventApplied
+	case audited > 0:
+		status.Phase = amtdv1beta1.SecurityEventAudited
+	default:
+		status.Phase = amtdv1beta1.SecurityEventIgnored
+	}
+
+	message := fmt.Sprintf("%d applied, %d failed, %d ignored, %d pending", applied, failed, ignored, pending)
+	if audited > 0 {
+		message += fmt.Sprintf(", %d audited", audited)
+	}
+
+	processed := metav1.Condition{
+		Type:               amtdv1beta1.SecurityEventProcessed,
+		Status:             metav1.ConditionTrue,
+		Reason:             "AllTargetsProcessed",
+		Message:            message,
+		ObservedGeneration: securityEvent.Generation,
+	}
+	if pending > 0 {
+		processed.Status = metav1.ConditionFalse
+		processed.Reason = "TargetsPending"
+	}
+	meta.SetStatusCondition(&status.Conditions, processed)
+
+	actionsApplied := metav1.Condition{
+		Type:               amtdv1beta1.SecurityEventActionsApplied,
+		Status:             metav1.ConditionTrue,
+		Reason:             "ActionsSucceeded",
+		Message:            message,
+		ObservedGeneration: securityEvent.Generation,
+	}
+	switch {
+	case failed > 0:
+		actionsApplied.Status = metav1.ConditionFalse
+		actionsApplied.Reason = "ActionsFailed"
+	case pending > 0:
+		actionsApplied.Status = metav1.ConditionUnknown
+		actionsApplied.Reason = "TargetsPending"
+	case applied == 0 && audited > 0:
+		actionsApplied.Status = metav1.ConditionFalse
+		actionsApplied.Reason = "AuditMode"
+	case applied == 0:
+		actionsApplied.Status = metav1.ConditionFalse
+		actionsApplied.Reason = "NoMatchingStrategy"
+	}
+	meta.SetStatusCondition(&status.Conditions, actionsApplied)
+}
@@ -0,0 +1,258 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// AlertmanagerPath is the default path of the Alertmanager endpoint
+const AlertmanagerPath = "/alertmanager"
+
+// alertResolved is the status of resolved Alertmanager alerts
+const alertResolved = "resolved"
+
+// DefaultAlertmanagerMapping maps the alerts based on the common Prometheus alert conventions
+var DefaultAlertmanagerMapping = RuleMapping{
+	Type:        "{{ .Labels.alertname }}",
+	ThreatLevel: "{{ .Labels.severity }}",
+	Source:      "Alertmanager",
+	Description: "{{ with .Annotations.summary }}{{ . }}{{ else }}{{ .Annotations.description }}{{ end }}",
+}
+
+// AlertmanagerMessage is the payload of the webhook receiver of Alertmanager
+type AlertmanagerMessage struct {
+	Version           string              `json:"version"`
+	GroupKey          string              `json:"groupKey"`
+	Status            string              `json:"status"`
+	Receiver          string              `json:"receiver"`
+	GroupLabels       map[string]string   `json:"groupLabels"`
+	CommonLabels      map[string]string   `json:"commonLabels"`
+	CommonAnnotations map[string]string   `json:"commonAnnotations"`
+	ExternalURL       string              `json:"externalURL"`
+	Alerts            []AlertmanagerAlert `json:"alerts"`
+}
+
+// AlertmanagerAlert is a single alert of an AlertmanagerMessage, the templates of the mapping
+// are executed on it
+type AlertmanagerAlert struct {
+	Status       string            `json:"status"`
+	Labels       map[string]string `json:"labels"`
+	Annotations  map[string]string `json:"annotations"`
+	StartsAt     time.Time         `json:"startsAt"`
+	EndsAt       time.Time         `json:"endsAt"`
+	GeneratorURL string            `json:"generatorURL"`
+	Fingerprint  string            `json:"fingerprint"`
+}
+
+// AlertmanagerHandler creates a SecurityEvent for each firing alert that has the namespace and
+// pod labels and records the resolution of the alert on the SecurityEvent. An alert that
+// Alertmanager sends again while it is firing does not create a new SecurityEvent.
+type AlertmanagerHandler struct {
+	Client client.Client
+
+	// NamespaceLabel and PodLabel are the alert labels the target pod is taken from
+	NamespaceLabel string
+	PodLabel       string
+
+	mapping *compiledRuleMapping
+}
+
+// NewAlertmanagerHandler returns a handler that maps the alerts with mapping and takes the
+// targets from the given labels
+func NewAlertmanagerHandler(c client.Client, mapping RuleMapping, namespaceLabel string, podLabel string) (*AlertmanagerHandler, error) {
+	compiled, err := mapping.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &AlertmanagerHandler{Client: c, NamespaceLabel: namespaceLabel, PodLabel: podLabel, mapping: compiled}, nil
+}
+
+func (h *AlertmanagerHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := log.FromContext(ctx).WithName("alertmanager")
+
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	var message AlertmanagerMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		writeResponse(w, http.StatusBadRequest, response{Error: fmt.Sprintf("invalid Alertmanager message: %s", err.Error())})
+		return
+	}
+
+	result := response{}
+	ignored, resolved, rejectedAlerts := 0, 0, 0
+	for _, alert := range message.Alerts {
+		fingerprint := alertFingerprint(alert)
+
+		if alert.Status == alertResolved {
+			count, err := h.resolve(ctx, fingerprint, alert)
+			if err != nil {
+				result.Error = err.Error()
+				writeResponse(w, http.StatusInternalServerError, result)
+				return
+			}
+			resolved += count
+			continue
+		}
+
+		spec, err := h.securityEventSpec(alert)
+		if err != nil {
+			log.Info(fmt.Sprintf(`Alert "%s" is ignored: %s`, alert.Labels["alertname"], err.Error()))
+			ignored++
+			continue
+		}
+
+		existing, err := h.firingSecurityEvents(ctx, fingerprint)
+		if err != nil {
+			result.Error = err.Error()
+			writeResponse(w, http.StatusInternalServerError, result)
+			return
+		}
+		if len(existing) > 0 {
+			// Alertmanager repeats the notification of firing alerts
+			ignored++
+			continue
+		}
+
+		securityEvent, err := createSecurityEvent(ctx, h.Client, "alertmanager", spec, map[string]string{ALERT_FINGERPRINT_LABEL: fingerprint})
+		if err != nil && rejected(err) {
+			// Alertmanager would send the rejected alert again with every notification of the group
+			log.Info(fmt.Sprintf(`SecurityEvent of alert "%s" is rejected: %s`, alert.Labels["alertname"], err.Error()))
+			result.Errors = append(result.Errors, err.Error())
+			rejectedAlerts++
+			continue
+		}
+		if err != nil {
+			result.Error = err.Error()
+			writeResponse(w, http.StatusInternalServerError, result)
+			return
+		}
+		result.SecurityEvents = append(result.SecurityEvents, securityEvent.Name)
+	}
+
+	result.Message = fmt.Sprintf("%d created, %d resolved, %d ignored, %d rejected", len(result.SecurityEvents), resolved, ignored, rejectedAlerts)
+	if rejectedAlerts > 0 {
+		writeResponse(w, http.StatusUnprocessableEntity, result)
+		return
+	}
+	if len(result.SecurityEvents) == 0 {
+		writeResponse(w, http.StatusOK, result)
+		return
+	}
+	writeResponse(w, http.StatusCreated, result)
+}
+
+// securityEventSpec maps the alert to a SecurityEvent spec
+func (h *AlertmanagerHandler) securityEventSpec(alert AlertmanagerAlert) (amtdv1beta1.SecurityEventSpec, error) {
+	namespace, pod := alert.Labels[h.NamespaceLabel], alert.Labels[h.PodLabel]
+	if namespace == "" || pod == "" {
+		return amtdv1beta1.SecurityEventSpec{}, fmt.Errorf("the alert has no %s and %s labels", h.NamespaceLabel, h.PodLabel)
+	}
+
+	rule, description, err := h.mapping.render(alert)
+	if err != nil {
+		return amtdv1beta1.SecurityEventSpec{}, err
+	}
+	if rule.Type == "" {
+		return amtdv1beta1.SecurityEventSpec{}, fmt.Errorf("the mapped type is empty")
+	}
+
+	return amtdv1beta1.SecurityEventSpec{
+		Targets:     []string{namespace + "/" + pod},
+		Rule:        rule,
+		Description: description,
+	}, nil
+}
+
+// firingSecurityEvents returns the SecurityEvents of the alert that are not resolved yet
+func (h *AlertmanagerHandler) firingSecurityEvents(ctx context.Context, fingerprint string) ([]amtdv1beta1.SecurityEvent, error) {
+	list := &amtdv1beta1.SecurityEventList{}
+	if err := h.Client.List(ctx, list, client.MatchingLabels{ALERT_FINGERPRINT_LABEL: fingerprint}); err != nil {
+		return nil, err
+	}
+
+	firing := []amtdv1beta1.SecurityEvent{}
+	for _, securityEvent := range list.Items {
+		if _, found := securityEvent.Annotations[ALERT_RESOLVED_ANNOTATION]; !found {
+			firing = append(firing, securityEvent)
+		}
+	}
+	return firing, nil
+}
+
+// resolve records the resolution time of the alert on its SecurityEvents
+func (h *AlertmanagerHandler) resolve(ctx context.Context, fingerprint string, alert AlertmanagerAlert) (int, error) {
+	securityEvents, err := h.firingSecurityEvents(ctx, fingerprint)
+	if err != nil {
+		return 0, err
+	}
+
+	resolvedAt := alert.EndsAt
+	if resolvedAt.IsZero() {
+		resolvedAt = time.Now()
+	}
+	for i := range securityEvents {
+		securityEvent := &securityEvents[i]
+		patch := client.MergeFrom(securityEvent.DeepCopy())
+		if securityEvent.Annotations == nil {
+			securityEvent.Annotations = map[string]string{}
+		}
+		securityEvent.Annotations[ALERT_RESOLVED_ANNOTATION] = resolvedAt.UTC().Format(time.RFC3339)
+		if err := h.Client.Patch(ctx, securityEvent, patch); err != nil {
+			return i, err
+		}
+		log.FromContext(ctx).Info(fmt.Sprintf(`Alert of SecurityEvent "%s" resolved`, securityEvent.Name))
+	}
+	return len(securityEvents), nil
+}
+
+// alertFingerprint returns the fingerprint of the alert, it is computed from the labels
+// for Alertmanager versions that do not send it
+func alertFingerprint(alert AlertmanagerAlert) string {
+	if alert.Fingerprint != "" {
+		return alert.Fingerprint
+	}
+
+	keys := make([]string, 0, len(alert.Labels))
+	for key := range alert.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	hash := sha256.New()
+	for _, key := range keys {
+		fmt.Fprintf(hash, "%s=%s\n", key, alert.Labels[key])
+	}
+	return hex.EncodeToString(hash.Sum(nil))[:16]
+}
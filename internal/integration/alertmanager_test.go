@@ -0,0 +1,89 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"net/http"
+	"testing"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+func TestAlertmanagerHandler(t *testing.T) {
+	c := newFakeClient(t)
+	handler, err := NewAlertmanagerHandler(c, DefaultAlertmanagerMapping, "namespace", "pod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := postFixture(t, handler, AlertmanagerPath, "alertmanager/firing.json")
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	// the node level alert is ignored
+	securityEvents := listSecurityEvents(t, c)
+	if len(securityEvents) != 1 {
+		t.Fatalf("expected 1 SecurityEvent, got %d", len(securityEvents))
+	}
+	spec := securityEvents[0].Spec
+	if len(spec.Targets) != 1 || spec.Targets[0] != "shop/checkout-5f8d7c9b4-l9qzt" {
+		t.Errorf("unexpected targets %v", spec.Targets)
+	}
+	want := amtdv1beta1.Rule{Type: "CryptoMiningCPUSpike", ThreatLevel: "critical", Source: "Alertmanager"}
+	if spec.Rule != want {
+		t.Errorf("unexpected rule %+v, want %+v", spec.Rule, want)
+	}
+	if spec.Description != "Sustained CPU usage of checkout-5f8d7c9b4-l9qzt matches a crypto miner" {
+		t.Errorf("unexpected description %q", spec.Description)
+	}
+
+	// repeated notifications of a firing alert do not create new SecurityEvents
+	postFixture(t, handler, AlertmanagerPath, "alertmanager/firing.json")
+	if securityEvents := listSecurityEvents(t, c); len(securityEvents) != 1 {
+		t.Fatalf("expected 1 SecurityEvent after the repeated notification, got %d", len(securityEvents))
+	}
+
+	recorder = postFixture(t, handler, AlertmanagerPath, "alertmanager/resolved.json")
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	securityEvents = listSecurityEvents(t, c)
+	if resolvedAt := securityEvents[0].Annotations[ALERT_RESOLVED_ANNOTATION]; resolvedAt != "2024-03-01T15:35:00Z" {
+		t.Errorf("unexpected resolution time %q", resolvedAt)
+	}
+
+	// the alert fires again after it was resolved
+	postFixture(t, handler, AlertmanagerPath, "alertmanager/firing.json")
+	if securityEvents := listSecurityEvents(t, c); len(securityEvents) != 2 {
+		t.Errorf("expected 2 SecurityEvents after the alert fired again, got %d", len(securityEvents))
+	}
+}
+
+func TestAlertmanagerHandlerRejectedByWebhook(t *testing.T) {
+	handler, err := NewAlertmanagerHandler(newRejectingClient(t), DefaultAlertmanagerMapping, "namespace", "pod")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Alertmanager must not retry a notification whose alert is rejected by the admission webhook
+	recorder := postFixture(t, handler, AlertmanagerPath, "alertmanager/firing.json")
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
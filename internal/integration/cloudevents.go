@@ -0,0 +1,227 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	webhookv1beta1 "github.com/r6security/phoenix/internal/webhook/v1beta1"
+)
+
+// CloudEventsPath is the default path of the CloudEvents endpoint
+const CloudEventsPath = "/cloudevents"
+
+// SecurityEventCloudEventType is the type of the CloudEvents that describe a SecurityEvent
+const SecurityEventCloudEventType = "com.r6security.phoenix.securityevent"
+
+const (
+	cloudEventsSpecVersion = "1.0"
+	structuredContentType  = "application/cloudevents+json"
+	batchContentType       = "application/cloudevents-batch+json"
+	jsonContentType        = "application/json"
+)
+
+// CloudEvent holds the attributes of a CloudEvent in structured mode, the same attributes
+// are sent as ce- headers in binary mode
+type CloudEvent struct {
+	SpecVersion     string          `json:"specversion"`
+	Type            string          `json:"type"`
+	Source          string          `json:"source"`
+	ID              string          `json:"id"`
+	Subject         string          `json:"subject,omitempty"`
+	Time            string          `json:"time,omitempty"`
+	DataContentType string          `json:"datacontenttype,omitempty"`
+	DataSchema      string          `json:"dataschema,omitempty"`
+	Data            json.RawMessage `json:"data,omitempty"`
+	DataBase64      string          `json:"data_base64,omitempty"`
+}
+
+// SecurityEventData is the data of a com.r6security.phoenix.securityevent CloudEvent.
+// The source of the CloudEvent is used if rule.source is empty.
+type SecurityEventData struct {
+	Targets     []string         `json:"targets"`
+	Rule        amtdv1beta1.Rule `json:"rule"`
+	Description string           `json:"description"`
+}
+
+// CloudEventsHandler creates a SecurityEvent from each com.r6security.phoenix.securityevent
+// CloudEvent received in binary or structured HTTP mode. Invalid events are rejected with the
+// list of problems, a CloudEvent that is received again (same source and id) is not duplicated.
+type CloudEventsHandler struct {
+	Client client.Client
+
+	// RequiredRuleFields are the rule fields that must not be empty, the ones the SecurityEvent
+	// webhook requires by default if nil
+	RequiredRuleFields []string
+}
+
+func (h *CloudEventsHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	contentType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	var event CloudEvent
+	switch contentType {
+	case structuredContentType:
+		// attributes of CloudEvents extensions, e.g. traceparent, are ignored
+		if err := json.Unmarshal(body, &event); err != nil {
+			writeResponse(w, http.StatusBadRequest, response{Error: fmt.Sprintf("invalid structured CloudEvent: %s", err.Error())})
+			return
+		}
+	case batchContentType:
+		writeResponse(w, http.StatusUnsupportedMediaType, response{Error: "batched CloudEvents are not supported"})
+		return
+	default:
+		event = CloudEvent{
+			SpecVersion:     r.Header.Get("ce-specversion"),
+			Type:            r.Header.Get("ce-type"),
+			Source:          r.Header.Get("ce-source"),
+			ID:              r.Header.Get("ce-id"),
+			Subject:         r.Header.Get("ce-subject"),
+			Time:            r.Header.Get("ce-time"),
+			DataContentType: r.Header.Get("Content-Type"),
+			DataSchema:      r.Header.Get("ce-dataschema"),
+			Data:            body,
+		}
+	}
+
+	requiredRuleFields := h.RequiredRuleFields
+	if requiredRuleFields == nil {
+		requiredRuleFields = webhookv1beta1.DefaultSecurityEventPolicy.RequiredRuleFields
+	}
+	spec, errs := securityEventSpecFromCloudEvent(event, requiredRuleFields)
+	if len(errs) > 0 {
+		writeResponse(w, http.StatusBadRequest, response{Error: "invalid CloudEvent", Errors: errs})
+		return
+	}
+
+	// CloudEvents are identified by their source and id, senders may deliver them more than once
+	id := cloudEventID(event)
+	list := &amtdv1beta1.SecurityEventList{}
+	if err := h.Client.List(ctx, list, client.MatchingLabels{CLOUDEVENT_ID_LABEL: id}); err != nil {
+		writeResponse(w, http.StatusInternalServerError, response{Error: err.Error()})
+		return
+	}
+	if len(list.Items) > 0 {
+		log.FromContext(ctx).Info(fmt.Sprintf(`CloudEvent "%s" from "%s" was already received`, event.ID, event.Source))
+		writeResponse(w, http.StatusOK, response{SecurityEvents: []string{list.Items[0].Name}, Message: "duplicate"})
+		return
+	}
+
+	securityEvent, err := createSecurityEvent(ctx, h.Client, "cloudevent", spec, map[string]string{CLOUDEVENT_ID_LABEL: id})
+	if err != nil {
+		writeResponse(w, createErrorStatus(err), response{Error: err.Error()})
+		return
+	}
+	writeResponse(w, http.StatusCreated, response{SecurityEvents: []string{securityEvent.Name}})
+}
+
+// securityEventSpecFromCloudEvent validates the CloudEvent and maps its data to a SecurityEvent spec,
+// the rule must have the required fields
+func securityEventSpecFromCloudEvent(event CloudEvent, requiredRuleFields []string) (amtdv1beta1.SecurityEventSpec, []string) {
+	errs := []string{}
+	if event.SpecVersion != cloudEventsSpecVersion {
+		errs = append(errs, fmt.Sprintf(`specversion must be "%s"`, cloudEventsSpecVersion))
+	}
+	if event.Type != SecurityEventCloudEventType {
+		errs = append(errs, fmt.Sprintf(`type must be "%s"`, SecurityEventCloudEventType))
+	}
+	if event.Source == "" {
+		errs = append(errs, "source is required")
+	}
+	if event.ID == "" {
+		errs = append(errs, "id is required")
+	}
+	if contentType, _, _ := mime.ParseMediaType(event.DataContentType); event.DataContentType != "" && contentType != jsonContentType {
+		errs = append(errs, fmt.Sprintf(`datacontenttype must be "%s"`, jsonContentType))
+	}
+
+	data := []byte(event.Data)
+	if event.DataBase64 != "" {
+		decoded, err := base64.StdEncoding.DecodeString(event.DataBase64)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("invalid data_base64: %s", err.Error()))
+		}
+		data = decoded
+	}
+	if len(bytes.TrimSpace(data)) == 0 {
+		return amtdv1beta1.SecurityEventSpec{}, append(errs, "data is required")
+	}
+
+	var securityEventData SecurityEventData
+	if err := json.Unmarshal(data, &securityEventData); err != nil {
+		return amtdv1beta1.SecurityEventSpec{}, append(errs, fmt.Sprintf("invalid data: %s", err.Error()))
+	}
+
+	if len(securityEventData.Targets) == 0 {
+		errs = append(errs, "data.targets must contain at least one target")
+	}
+	for _, target := range securityEventData.Targets {
+		if _, _, err := amtdv1beta1.ParseTarget(target); err != nil {
+			errs = append(errs, fmt.Sprintf("data.targets: %s", err.Error()))
+		}
+	}
+	rule := securityEventData.Rule
+	if rule.Source == "" {
+		rule.Source = event.Source
+	}
+	values := map[string]string{
+		webhookv1beta1.RULE_FIELD_TYPE:         rule.Type,
+		webhookv1beta1.RULE_FIELD_THREAT_LEVEL: rule.ThreatLevel,
+		webhookv1beta1.RULE_FIELD_SOURCE:       rule.Source,
+	}
+	for _, name := range requiredRuleFields {
+		if strings.TrimSpace(values[name]) == "" {
+			errs = append(errs, fmt.Sprintf("data.rule.%s is required", name))
+		}
+	}
+	if strings.TrimSpace(securityEventData.Description) == "" {
+		errs = append(errs, "data.description is required")
+	}
+
+	if len(errs) > 0 {
+		return amtdv1beta1.SecurityEventSpec{}, errs
+	}
+	return amtdv1beta1.SecurityEventSpec{
+		Targets:     securityEventData.Targets,
+		Rule:        rule,
+		Description: securityEventData.Description,
+	}, nil
+}
+
+// cloudEventID returns a label value that identifies the CloudEvent by its source and id
+func cloudEventID(event CloudEvent) string {
+	hash := sha256.Sum256([]byte(event.Source + "\x00" + event.ID))
+	return hex.EncodeToString(hash[:])[:32]
+}
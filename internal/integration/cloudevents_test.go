@@ -0,0 +1,167 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+func postCloudEvent(t *testing.T, handler http.Handler, fixture string, headers map[string]string) *httptest.ResponseRecorder {
+	body, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	request := httptest.NewRequest(http.MethodPost, CloudEventsPath, bytes.NewReader(body))
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	return recorder
+}
+
+func TestCloudEventsHandlerStructuredMode(t *testing.T) {
+	c := newFakeClient(t)
+	handler := &CloudEventsHandler{Client: c}
+	headers := map[string]string{"Content-Type": "application/cloudevents+json; charset=utf-8"}
+
+	recorder := postCloudEvent(t, handler, "cloudevents/structured.json", headers)
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	securityEvents := listSecurityEvents(t, c)
+	if len(securityEvents) != 1 {
+		t.Fatalf("expected 1 SecurityEvent, got %d", len(securityEvents))
+	}
+	spec := securityEvents[0].Spec
+	if len(spec.Targets) != 1 || spec.Targets[0] != "shop/checkout-5f8d7c9b4-l9qzt" {
+		t.Errorf("unexpected targets %v", spec.Targets)
+	}
+	// the source of the CloudEvent is used when the rule has none
+	want := amtdv1beta1.Rule{Type: "reverse-shell", ThreatLevel: "critical", Source: "https://scanner.example.com/runtime"}
+	if spec.Rule != want {
+		t.Errorf("unexpected rule %+v, want %+v", spec.Rule, want)
+	}
+
+	// redelivery of the same CloudEvent does not create a new SecurityEvent
+	recorder = postCloudEvent(t, handler, "cloudevents/structured.json", headers)
+	if recorder.Code != http.StatusOK {
+		t.Errorf("unexpected status %d for the redelivered CloudEvent", recorder.Code)
+	}
+	if securityEvents := listSecurityEvents(t, c); len(securityEvents) != 1 {
+		t.Errorf("expected 1 SecurityEvent after the redelivery, got %d", len(securityEvents))
+	}
+}
+
+func TestCloudEventsHandlerBinaryMode(t *testing.T) {
+	c := newFakeClient(t)
+	handler := &CloudEventsHandler{Client: c}
+
+	recorder := postCloudEvent(t, handler, "cloudevents/data.json", map[string]string{
+		"Content-Type":   "application/json",
+		"ce-specversion": "1.0",
+		"ce-type":        SecurityEventCloudEventType,
+		"ce-source":      "https://scanner.example.com/network",
+		"ce-id":          "42",
+	})
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	securityEvents := listSecurityEvents(t, c)
+	if len(securityEvents) != 1 {
+		t.Fatalf("expected 1 SecurityEvent, got %d", len(securityEvents))
+	}
+	spec := securityEvents[0].Spec
+	if len(spec.Targets) != 2 {
+		t.Errorf("unexpected targets %v", spec.Targets)
+	}
+	want := amtdv1beta1.Rule{Type: "crypto-mining", ThreatLevel: "high", Source: "scanner"}
+	if spec.Rule != want {
+		t.Errorf("unexpected rule %+v, want %+v", spec.Rule, want)
+	}
+}
+
+func TestCloudEventsHandlerRejectsInvalidEvents(t *testing.T) {
+	c := newFakeClient(t)
+	handler := &CloudEventsHandler{Client: c}
+
+	recorder := postCloudEvent(t, handler, "cloudevents/invalid.json", map[string]string{"Content-Type": "application/cloudevents+json"})
+	if recorder.Code != http.StatusBadRequest {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var body response
+	if err := json.Unmarshal(recorder.Body.Bytes(), &body); err != nil {
+		t.Fatal(err)
+	}
+	// wrong type, target without namespace, empty rule and empty description
+	if len(body.Errors) != 4 {
+		t.Errorf("expected 4 errors, got %v", body.Errors)
+	}
+
+	// binary mode without the required attributes
+	recorder = postCloudEvent(t, handler, "cloudevents/data.json", map[string]string{"Content-Type": "application/json"})
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	recorder = postCloudEvent(t, handler, "cloudevents/structured.json", map[string]string{"Content-Type": "application/cloudevents-batch+json"})
+	if recorder.Code != http.StatusUnsupportedMediaType {
+		t.Errorf("unexpected status %d for a batch", recorder.Code)
+	}
+
+	if securityEvents := listSecurityEvents(t, c); len(securityEvents) != 0 {
+		t.Errorf("expected no SecurityEvents, got %d", len(securityEvents))
+	}
+}
+
+func TestCloudEventsHandlerRequiresRuleFields(t *testing.T) {
+	c := newFakeClient(t)
+	handler := &CloudEventsHandler{Client: c}
+
+	// the default policy of the SecurityEvent webhook requires a type
+	body := `{"targets":["shop/checkout"],"rule":{"threatLevel":"high"},"description":"Suspicious process"}`
+	request := httptest.NewRequest(http.MethodPost, CloudEventsPath, bytes.NewReader([]byte(body)))
+	for key, value := range map[string]string{"Content-Type": "application/json", "ce-specversion": "1.0", "ce-type": SecurityEventCloudEventType, "ce-source": "scanner", "ce-id": "1"} {
+		request.Header.Set(key, value)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusBadRequest || !bytes.Contains(recorder.Body.Bytes(), []byte("data.rule.type is required")) {
+		t.Errorf("unexpected response %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestCloudEventsHandlerRejectedByWebhook(t *testing.T) {
+	handler := &CloudEventsHandler{Client: newRejectingClient(t)}
+
+	// the sender must not retry an event that is rejected by the admission webhook
+	recorder := postCloudEvent(t, handler, "cloudevents/structured.json", map[string]string{"Content-Type": "application/cloudevents+json"})
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,27 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+// Label of the SecurityEvents created from Alertmanager alerts, it identifies the alert
+const ALERT_FINGERPRINT_LABEL = "amtd.r6security.com/alert-fingerprint"
+
+// Annotation of the SecurityEvents created from Alertmanager alerts, it is set when the alert is resolved
+const ALERT_RESOLVED_ANNOTATION = "amtd.r6security.com/alert-resolved-at"
+
+// Label of the SecurityEvents created from CloudEvents, it identifies the CloudEvent by its source and id
+const CLOUDEVENT_ID_LABEL = "amtd.r6security.com/cloudevent-id"
@@ -0,0 +1,125 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// FalcoPath is the default path of the Falco endpoint
+const FalcoPath = "/falco"
+
+// FalcoSource is the default source of the SecurityEvents created from Falco alerts,
+// it is the same as the one used by the standalone falco-integrator
+const FalcoSource = "FalcoIntegrator"
+
+// Falco output fields that identify the pod
+const (
+	falcoNamespaceField = "k8s.ns.name"
+	falcoPodField       = "k8s.pod.name"
+)
+
+// FalcoAlert is the JSON payload sent by the http_output of Falco
+type FalcoAlert struct {
+	Hostname     string                 `json:"hostname"`
+	Output       string                 `json:"output"`
+	Priority     string                 `json:"priority"`
+	Rule         string                 `json:"rule"`
+	Source       string                 `json:"source"`
+	Tags         []string               `json:"tags"`
+	Time         string                 `json:"time"`
+	OutputFields map[string]interface{} `json:"output_fields"`
+}
+
+// FalcoHandler creates a SecurityEvent for each Falco alert that refers to a pod.
+// The rule of the alert becomes the type and its priority the threat level.
+type FalcoHandler struct {
+	Client client.Client
+
+	// Source of the created SecurityEvents, the source of the Falco alert (e.g. "syscall") is used if empty
+	Source string
+}
+
+func (h *FalcoHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := log.FromContext(ctx).WithName("falco")
+
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	var alert FalcoAlert
+	if err := json.Unmarshal(body, &alert); err != nil {
+		writeResponse(w, http.StatusBadRequest, response{Error: fmt.Sprintf("invalid Falco alert: %s", err.Error())})
+		return
+	}
+
+	spec, err := h.securityEventSpec(alert)
+	if err != nil {
+		// Falco also reports events that do not belong to a pod, e.g. host events
+		log.Info(fmt.Sprintf(`Falco alert "%s" is ignored: %s`, alert.Rule, err.Error()))
+		writeResponse(w, http.StatusOK, response{Message: fmt.Sprintf("ignored: %s", err.Error())})
+		return
+	}
+
+	securityEvent, err := createSecurityEvent(ctx, h.Client, "falco", spec, nil)
+	if err != nil {
+		writeResponse(w, createErrorStatus(err), response{Error: err.Error()})
+		return
+	}
+	writeResponse(w, http.StatusCreated, response{SecurityEvents: []string{securityEvent.Name}})
+}
+
+// securityEventSpec maps the Falco alert to a SecurityEvent spec
+func (h *FalcoHandler) securityEventSpec(alert FalcoAlert) (amtdv1beta1.SecurityEventSpec, error) {
+	namespace, _ := alert.OutputFields[falcoNamespaceField].(string)
+	pod, _ := alert.OutputFields[falcoPodField].(string)
+	if namespace == "" || pod == "" {
+		return amtdv1beta1.SecurityEventSpec{}, fmt.Errorf("the alert has no %s and %s output fields", falcoNamespaceField, falcoPodField)
+	}
+	if alert.Rule == "" {
+		return amtdv1beta1.SecurityEventSpec{}, fmt.Errorf("the alert has no rule")
+	}
+
+	source := h.Source
+	if source == "" {
+		source = alert.Source
+	}
+	description := alert.Output
+	if description == "" {
+		description = fmt.Sprintf("Falco: %s", alert.Rule)
+	}
+
+	return amtdv1beta1.SecurityEventSpec{
+		Targets: []string{namespace + "/" + pod},
+		Rule: amtdv1beta1.Rule{
+			Type:        alert.Rule,
+			ThreatLevel: alert.Priority,
+			Source:      source,
+		},
+		Description: description,
+	}, nil
+}
@@ -0,0 +1,125 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+func newFakeClient(t *testing.T) client.Client {
+	scheme := runtime.NewScheme()
+	if err := amtdv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).Build()
+}
+
+// newRejectingClient returns a client whose SecurityEvents are rejected like by the admission webhook
+func newRejectingClient(t *testing.T) client.Client {
+	return interceptor.NewClient(newFakeClient(t).(client.WithWatch), interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			return apierrors.NewInvalid(amtdv1beta1.GroupVersion.WithKind("SecurityEvent").GroupKind(), obj.GetName(), field.ErrorList{field.TooMany(field.NewPath("spec", "targets"), 2, 1)})
+		},
+	})
+}
+
+func listSecurityEvents(t *testing.T, c client.Client) []amtdv1beta1.SecurityEvent {
+	list := &amtdv1beta1.SecurityEventList{}
+	if err := c.List(context.Background(), list); err != nil {
+		t.Fatal(err)
+	}
+	return list.Items
+}
+
+func TestFalcoHandler(t *testing.T) {
+	c := newFakeClient(t)
+	handler := &FalcoHandler{Client: c, Source: FalcoSource}
+
+	alert := `{
+		"output": "16:31:56.746609046: Notice A shell was spawned in a container (user=root k8s.ns=default k8s.pod=demo-page-6c7d5b8b8-x2xk5)",
+		"priority": "Notice",
+		"rule": "Terminal shell in container",
+		"source": "syscall",
+		"time": "2024-03-01T16:31:56.746609046Z",
+		"output_fields": {"k8s.ns.name": "default", "k8s.pod.name": "demo-page-6c7d5b8b8-x2xk5", "proc.name": "bash"}
+	}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, FalcoPath, strings.NewReader(alert)))
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	securityEvents := listSecurityEvents(t, c)
+	if len(securityEvents) != 1 {
+		t.Fatalf("expected 1 SecurityEvent, got %d", len(securityEvents))
+	}
+	spec := securityEvents[0].Spec
+	if len(spec.Targets) != 1 || spec.Targets[0] != "default/demo-page-6c7d5b8b8-x2xk5" {
+		t.Errorf("unexpected targets %v", spec.Targets)
+	}
+	want := amtdv1beta1.Rule{Type: "Terminal shell in container", ThreatLevel: "Notice", Source: FalcoSource}
+	if spec.Rule != want {
+		t.Errorf("unexpected rule %+v, want %+v", spec.Rule, want)
+	}
+}
+
+func TestFalcoHandlerIgnoresHostEvents(t *testing.T) {
+	c := newFakeClient(t)
+	handler := &FalcoHandler{Client: c}
+
+	alert := `{"priority": "Warning", "rule": "Read sensitive file untrusted", "source": "syscall", "output_fields": {"proc.name": "cat"}}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, FalcoPath, strings.NewReader(alert)))
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if securityEvents := listSecurityEvents(t, c); len(securityEvents) != 0 {
+		t.Errorf("expected no SecurityEvent, got %d", len(securityEvents))
+	}
+
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, FalcoPath, strings.NewReader("not json")))
+	if recorder.Code != http.StatusBadRequest {
+		t.Errorf("unexpected status %d for invalid JSON", recorder.Code)
+	}
+}
+
+func TestFalcoHandlerRejectedByWebhook(t *testing.T) {
+	handler := &FalcoHandler{Client: newRejectingClient(t), Source: FalcoSource}
+
+	// Falcosidekick must not retry an alert that is rejected by the admission webhook
+	alert := `{"priority": "Notice", "rule": "Terminal shell in container", "output_fields": {"k8s.ns.name": "default", "k8s.pod.name": "demo-page-6c7d5b8b8-x2xk5"}}`
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, FalcoPath, strings.NewReader(alert)))
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Errorf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
@@ -0,0 +1,226 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// KubeArmorPath is the default path of the KubeArmor endpoint
+const KubeArmorPath = "/kubearmor"
+
+// DefaultKubeArmorMapping maps the alerts the same way as the standalone kubearmor-integrator
+var DefaultKubeArmorMapping = RuleMapping{
+	Type:        "{{ .PolicyName }}",
+	ThreatLevel: "{{ .Severity }}",
+	Source:      "KubeArmorIntegrator",
+	Description: "KubeArmor: {{ .Message }} ({{ .Action }} {{ .Operation }} {{ .Resource }} by {{ .ProcessName }})",
+}
+
+// KubeArmorAlert is an alert or log record of KubeArmor as it is streamed by kubearmor-relay
+// or printed by "karmor logs --json"
+type KubeArmorAlert struct {
+	Timestamp      int64  `json:"Timestamp"`
+	UpdatedTime    string `json:"UpdatedTime"`
+	ClusterName    string `json:"ClusterName"`
+	HostName       string `json:"HostName"`
+	NamespaceName  string `json:"NamespaceName"`
+	PodName        string `json:"PodName"`
+	Labels         string `json:"Labels"`
+	ContainerID    string `json:"ContainerID"`
+	ContainerName  string `json:"ContainerName"`
+	ContainerImage string `json:"ContainerImage"`
+	ProcessName    string `json:"ProcessName"`
+	PolicyName     string `json:"PolicyName"`
+	Severity       string `json:"Severity"`
+	Tags           string `json:"Tags"`
+	Message        string `json:"Message"`
+	Type           string `json:"Type"`
+	Source         string `json:"Source"`
+	Operation      string `json:"Operation"`
+	Resource       string `json:"Resource"`
+	Data           string `json:"Data"`
+	Enforcer       string `json:"Enforcer"`
+	Action         string `json:"Action"`
+	Result         string `json:"Result"`
+}
+
+// KubeArmorHandler creates a SecurityEvent for each KubeArmor alert that refers to a pod.
+// The body is a single alert, a JSON array of alerts or newline delimited alerts, the
+// response lists the result of each alert in the order of the body.
+type KubeArmorHandler struct {
+	Client client.Client
+
+	mapping *compiledRuleMapping
+}
+
+// NewKubeArmorHandler returns a handler that maps the alerts with mapping
+func NewKubeArmorHandler(c client.Client, mapping RuleMapping) (*KubeArmorHandler, error) {
+	compiled, err := mapping.compile()
+	if err != nil {
+		return nil, err
+	}
+	return &KubeArmorHandler{Client: c, mapping: compiled}, nil
+}
+
+func (h *KubeArmorHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	log := log.FromContext(ctx).WithName("kubearmor")
+
+	body, ok := readBody(w, r)
+	if !ok {
+		return
+	}
+
+	alerts, err := decodeKubeArmorAlerts(body)
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, response{Error: fmt.Sprintf("invalid KubeArmor alert: %s", err.Error())})
+		return
+	}
+
+	result := response{}
+	status := http.StatusOK
+	ignored := 0
+	for _, alert := range alerts {
+		item := h.handleAlert(ctx, alert)
+		result.Results = append(result.Results, item)
+		switch item.Status {
+		case resultIgnored:
+			log.Info(fmt.Sprintf(`KubeArmor alert of policy "%s" is ignored: %s`, alert.PolicyName, item.Error))
+			ignored++
+		case resultCreated:
+			result.SecurityEvents = append(result.SecurityEvents, item.SecurityEvent)
+			if status == http.StatusOK {
+				status = http.StatusCreated
+			}
+		case resultRejected:
+			log.Info(fmt.Sprintf(`SecurityEvent of the KubeArmor alert of policy "%s" is rejected: %s`, alert.PolicyName, item.Error))
+			if status != http.StatusInternalServerError {
+				status = http.StatusUnprocessableEntity
+			}
+			result.Error = "some alerts could not be turned into SecurityEvents"
+		case resultFailed:
+			// the alerts get the same SecurityEvent names when the batch is sent again
+			status = http.StatusInternalServerError
+			result.Error = "some alerts could not be turned into SecurityEvents"
+		}
+	}
+
+	if ignored > 0 {
+		result.Message = fmt.Sprintf("%d alerts ignored", ignored)
+	}
+	writeResponse(w, status, result)
+}
+
+// handleAlert creates the SecurityEvent of the alert. The name of the SecurityEvent is derived
+// from the alert, so an alert that is delivered again does not create a new SecurityEvent.
+func (h *KubeArmorHandler) handleAlert(ctx context.Context, alert KubeArmorAlert) itemResult {
+	spec, err := h.securityEventSpec(alert)
+	if err != nil {
+		return itemResult{Status: resultIgnored, Error: err.Error()}
+	}
+
+	name, err := kubeArmorSecurityEventName(alert)
+	if err != nil {
+		return itemResult{Status: resultFailed, Error: err.Error()}
+	}
+	found, err := createNamedSecurityEvent(ctx, h.Client, name, spec, nil)
+	switch {
+	case err != nil && rejected(err):
+		return itemResult{Status: resultRejected, SecurityEvent: name, Error: err.Error()}
+	case err != nil:
+		return itemResult{Status: resultFailed, SecurityEvent: name, Error: err.Error()}
+	case found:
+		return itemResult{Status: resultExists, SecurityEvent: name}
+	default:
+		return itemResult{Status: resultCreated, SecurityEvent: name}
+	}
+}
+
+// kubeArmorSecurityEventName derives the name of the SecurityEvent from the content of the alert
+func kubeArmorSecurityEventName(alert KubeArmorAlert) (string, error) {
+	encoded, err := json.Marshal(alert)
+	if err != nil {
+		return "", err
+	}
+	hash := sha256.Sum256(encoded)
+	return "kubearmor-" + hex.EncodeToString(hash[:])[:20], nil
+}
+
+// securityEventSpec maps the KubeArmor alert to a SecurityEvent spec
+func (h *KubeArmorHandler) securityEventSpec(alert KubeArmorAlert) (amtdv1beta1.SecurityEventSpec, error) {
+	if alert.NamespaceName == "" || alert.PodName == "" {
+		return amtdv1beta1.SecurityEventSpec{}, fmt.Errorf("the alert has no NamespaceName and PodName")
+	}
+
+	rule, description, err := h.mapping.render(alert)
+	if err != nil {
+		return amtdv1beta1.SecurityEventSpec{}, err
+	}
+	if rule.Type == "" {
+		return amtdv1beta1.SecurityEventSpec{}, fmt.Errorf("the mapped type is empty")
+	}
+
+	return amtdv1beta1.SecurityEventSpec{
+		Targets:     []string{alert.NamespaceName + "/" + alert.PodName},
+		Rule:        rule,
+		Description: description,
+	}, nil
+}
+
+// decodeKubeArmorAlerts decodes a single alert, an array of alerts or newline delimited alerts
+func decodeKubeArmorAlerts(body []byte) ([]KubeArmorAlert, error) {
+	body = bytes.TrimSpace(body)
+	if len(body) == 0 {
+		return nil, fmt.Errorf("empty body")
+	}
+
+	if body[0] == '[' {
+		var alerts []KubeArmorAlert
+		if err := json.Unmarshal(body, &alerts); err != nil {
+			return nil, err
+		}
+		return alerts, nil
+	}
+
+	alerts := []KubeArmorAlert{}
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	for {
+		var alert KubeArmorAlert
+		err := decoder.Decode(&alert)
+		if err == io.EOF {
+			return alerts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, alert)
+	}
+}
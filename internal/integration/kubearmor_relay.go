@@ -0,0 +1,249 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"slices"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/protobuf/encoding/protowire"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// kubeArmorWatchAlerts is the streaming method of the LogService of kubearmor-relay
+const kubeArmorWatchAlerts = "/feeder.LogService/WatchAlerts"
+
+// Delays between the attempts to reconnect to kubearmor-relay
+const (
+	kubeArmorRelayInitialBackoff = time.Second
+	kubeArmorRelayMaxBackoff     = time.Minute
+)
+
+// KubeArmorRelayWatcher consumes the WatchAlerts stream of kubearmor-relay and creates a
+// SecurityEvent for each alert with the KubeArmorHandler. It implements manager.Runnable and
+// reconnects until the manager is stopped.
+type KubeArmorRelayWatcher struct {
+	Handler *KubeArmorHandler
+	// Address is the address of the gRPC service of kubearmor-relay, e.g. kubearmor.kubearmor.svc:32767
+	Address string
+	// Filter selects the alerts the relay streams: all, policy or system
+	Filter string
+	// CAFile is the CA certificate of the relay, if it is set the connection uses TLS
+	CAFile string
+
+	// dialOptions are added to the options of the connection
+	dialOptions []grpc.DialOption
+}
+
+// Start watches the alerts until ctx is cancelled
+func (w *KubeArmorRelayWatcher) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("kubearmor")
+
+	transport := insecure.NewCredentials()
+	if w.CAFile != "" {
+		var err error
+		if transport, err = credentials.NewClientTLSFromFile(w.CAFile, ""); err != nil {
+			return fmt.Errorf("unable to load the CA of kubearmor-relay: %w", err)
+		}
+	}
+	conn, err := grpc.NewClient(w.Address, append([]grpc.DialOption{grpc.WithTransportCredentials(transport)}, w.dialOptions...)...)
+	if err != nil {
+		return fmt.Errorf("unable to connect to kubearmor-relay: %w", err)
+	}
+	defer conn.Close()
+
+	backoff := kubeArmorRelayInitialBackoff
+	for {
+		log.Info("Watching KubeArmor alerts", "address", w.Address)
+		received, err := w.watch(ctx, conn)
+		if ctx.Err() != nil {
+			return nil
+		}
+		if received {
+			backoff = kubeArmorRelayInitialBackoff
+		}
+		log.Error(err, fmt.Sprintf(`Watching KubeArmor alerts failed, reconnecting in %s`, backoff))
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, kubeArmorRelayMaxBackoff)
+	}
+}
+
+// watch creates the SecurityEvents of the alerts of a WatchAlerts stream until the stream breaks,
+// received reports whether any alert arrived
+func (w *KubeArmorRelayWatcher) watch(ctx context.Context, conn *grpc.ClientConn) (received bool, err error) {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stream, err := conn.NewStream(ctx, &grpc.StreamDesc{StreamName: "WatchAlerts", ServerStreams: true}, kubeArmorWatchAlerts, grpc.ForceCodec(kubeArmorCodec{}))
+	if err != nil {
+		return false, err
+	}
+	if err := stream.SendMsg(&kubeArmorRequest{Filter: w.Filter}); err != nil {
+		return false, err
+	}
+	if err := stream.CloseSend(); err != nil {
+		return false, err
+	}
+
+	for {
+		var alert KubeArmorAlert
+		if err := stream.RecvMsg(&alert); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = errors.New("the stream was closed by kubearmor-relay")
+			}
+			return received, err
+		}
+		received = true
+		item := w.Handler.handleAlert(ctx, alert)
+		if item.Status == resultFailed || item.Status == resultRejected {
+			// the stream does not redeliver the alert, it is lost
+			log.FromContext(ctx).Error(errors.New(item.Error), fmt.Sprintf(`KubeArmor alert of policy "%s" could not be turned into a SecurityEvent`, alert.PolicyName))
+		}
+	}
+}
+
+// NeedLeaderElection returns true, a single replica of the operator watches the alerts
+func (w *KubeArmorRelayWatcher) NeedLeaderElection() bool {
+	return true
+}
+
+// kubeArmorRequest is the RequestMessage of the LogService
+type kubeArmorRequest struct {
+	Filter string
+}
+
+// kubeArmorAlertStrings are the numbers of the string fields of the Alert message of the LogService
+func kubeArmorAlertStrings(alert *KubeArmorAlert) map[protowire.Number]*string {
+	return map[protowire.Number]*string{
+		2:  &alert.UpdatedTime,
+		3:  &alert.ClusterName,
+		4:  &alert.HostName,
+		5:  &alert.NamespaceName,
+		6:  &alert.PodName,
+		7:  &alert.ContainerID,
+		8:  &alert.ContainerName,
+		13: &alert.PolicyName,
+		14: &alert.Severity,
+		15: &alert.Tags,
+		16: &alert.Message,
+		17: &alert.Type,
+		18: &alert.Source,
+		19: &alert.Operation,
+		20: &alert.Resource,
+		21: &alert.Data,
+		22: &alert.Action,
+		23: &alert.Result,
+		24: &alert.ContainerImage,
+		26: &alert.ProcessName,
+		28: &alert.Enforcer,
+		29: &alert.Labels,
+	}
+}
+
+// kubeArmorCodec encodes the messages of the LogService in the protobuf wire format, fields that
+// are not needed for the SecurityEvents are skipped
+type kubeArmorCodec struct{}
+
+func (kubeArmorCodec) Name() string {
+	return "proto"
+}
+
+func (kubeArmorCodec) Marshal(v any) ([]byte, error) {
+	switch message := v.(type) {
+	case *kubeArmorRequest:
+		return appendString(nil, 1, message.Filter), nil
+	case *KubeArmorAlert:
+		data := []byte{}
+		if message.Timestamp != 0 {
+			data = protowire.AppendTag(data, 1, protowire.VarintType)
+			data = protowire.AppendVarint(data, uint64(message.Timestamp))
+		}
+		fields := kubeArmorAlertStrings(message)
+		for _, number := range slices.Sorted(maps.Keys(fields)) {
+			data = appendString(data, number, *fields[number])
+		}
+		return data, nil
+	default:
+		return nil, fmt.Errorf("unsupported message %T", v)
+	}
+}
+
+func (kubeArmorCodec) Unmarshal(data []byte, v any) error {
+	switch message := v.(type) {
+	case *kubeArmorRequest:
+		return consumeFields(data, map[protowire.Number]*string{1: &message.Filter}, nil)
+	case *KubeArmorAlert:
+		return consumeFields(data, kubeArmorAlertStrings(message), &message.Timestamp)
+	default:
+		return fmt.Errorf("unsupported message %T", v)
+	}
+}
+
+func appendString(data []byte, number protowire.Number, value string) []byte {
+	if value == "" {
+		return data
+	}
+	data = protowire.AppendTag(data, number, protowire.BytesType)
+	return protowire.AppendString(data, value)
+}
+
+// consumeFields decodes the string fields and the varint field 1 (if timestamp is not nil) of a message
+func consumeFields(data []byte, stringFields map[protowire.Number]*string, timestamp *int64) error {
+	for len(data) > 0 {
+		number, wireType, n := protowire.ConsumeTag(data)
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+
+		field, isString := stringFields[number]
+		switch {
+		case isString && wireType == protowire.BytesType:
+			var value string
+			value, n = protowire.ConsumeString(data)
+			if n >= 0 {
+				*field = value
+			}
+		case number == 1 && timestamp != nil && wireType == protowire.VarintType:
+			var value uint64
+			value, n = protowire.ConsumeVarint(data)
+			if n >= 0 {
+				*timestamp = int64(value)
+			}
+		default:
+			n = protowire.ConsumeFieldValue(number, wireType, data)
+		}
+		if n < 0 {
+			return protowire.ParseError(n)
+		}
+		data = data[n:]
+	}
+	return nil
+}
@@ -0,0 +1,149 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"context"
+	"net"
+	"reflect"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// serveKubeArmorRelay serves a LogService that streams the alerts on the first WatchAlerts call
+func serveKubeArmorRelay(t *testing.T, alerts []KubeArmorAlert) (*bufconn.Listener, chan kubeArmorRequest) {
+	requests := make(chan kubeArmorRequest, 10)
+	var calls atomic.Int32
+	server := grpc.NewServer(grpc.ForceServerCodec(kubeArmorCodec{}))
+	server.RegisterService(&grpc.ServiceDesc{
+		ServiceName: "feeder.LogService",
+		HandlerType: (*any)(nil),
+		Streams: []grpc.StreamDesc{{
+			StreamName:    "WatchAlerts",
+			ServerStreams: true,
+			Handler: func(_ any, stream grpc.ServerStream) error {
+				var request kubeArmorRequest
+				if err := stream.RecvMsg(&request); err != nil {
+					return err
+				}
+				requests <- request
+				if calls.Add(1) > 1 {
+					<-stream.Context().Done()
+					return nil
+				}
+				for i := range alerts {
+					if err := stream.SendMsg(&alerts[i]); err != nil {
+						return err
+					}
+				}
+				// the relay restarts, the watcher has to reconnect
+				return nil
+			},
+		}},
+	}, nil)
+
+	listener := bufconn.Listen(1 << 20)
+	go server.Serve(listener)
+	t.Cleanup(server.Stop)
+	return listener, requests
+}
+
+func TestKubeArmorCodec(t *testing.T) {
+	alert := KubeArmorAlert{
+		Timestamp:     1709310716,
+		NamespaceName: "default",
+		PodName:       "demo-page-6c7d5b8b8-x2xk5",
+		PolicyName:    "block-pkg-mgmt-tools-exec",
+		Severity:      "1",
+		Labels:        "app=demo",
+		Enforcer:      "AppArmor",
+	}
+	data, err := kubeArmorCodec{}.Marshal(&alert)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// fields unknown to the codec, e.g. the HostPID (9) and the ATags (30), are skipped
+	data = append(data, 0x48, 0x2a, 0xf2, 0x01, 0x03, 'f', 'o', 'o')
+
+	var decoded KubeArmorAlert
+	if err := (kubeArmorCodec{}).Unmarshal(data, &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, alert) {
+		t.Errorf("expected %+v, got %+v", alert, decoded)
+	}
+	if err := (kubeArmorCodec{}).Unmarshal([]byte{0x2a, 0x05, 'a'}, &decoded); err == nil {
+		t.Errorf("expected an error for a truncated message")
+	}
+}
+
+func TestKubeArmorRelayWatcher(t *testing.T) {
+	alert := KubeArmorAlert{UpdatedTime: "2024-03-01T16:31:56.746609Z", NamespaceName: "default", PodName: "demo-page-6c7d5b8b8-x2xk5", PolicyName: "block-pkg-mgmt-tools-exec", Severity: "1"}
+	other := alert
+	other.PodName = "demo-page-6c7d5b8b8-zt4rq"
+	host := KubeArmorAlert{HostName: "node-1", PolicyName: "host-policy"}
+	listener, requests := serveKubeArmorRelay(t, []KubeArmorAlert{alert, other, alert, host})
+
+	c := newFakeClient(t)
+	handler, err := NewKubeArmorHandler(c, DefaultKubeArmorMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	watcher := &KubeArmorRelayWatcher{
+		Handler: handler,
+		Address: "passthrough:///kubearmor-relay",
+		Filter:  "policy",
+		dialOptions: []grpc.DialOption{grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return listener.DialContext(ctx)
+		})},
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error)
+	go func() { done <- watcher.Start(ctx) }()
+
+	// the watcher reconnects after the stream is closed
+	for i := 0; i < 2; i++ {
+		select {
+		case request := <-requests:
+			if request.Filter != "policy" {
+				t.Errorf("expected the policy filter, got %q", request.Filter)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected WatchAlerts call %d", i+1)
+		}
+	}
+	cancel()
+	if err := <-done; err != nil {
+		t.Errorf("expected the watcher to stop without error, got %v", err)
+	}
+
+	// the repeated alert does not create a new SecurityEvent, the host alert is ignored
+	securityEvents := listSecurityEvents(t, c)
+	if len(securityEvents) != 2 {
+		t.Fatalf("expected 2 SecurityEvents, got %d", len(securityEvents))
+	}
+	for _, securityEvent := range securityEvents {
+		if securityEvent.Spec.Rule.Type != "block-pkg-mgmt-tools-exec" {
+			t.Errorf("unexpected rule %+v", securityEvent.Spec.Rule)
+		}
+	}
+}
@@ -0,0 +1,194 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/interceptor"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+func postFixture(t *testing.T, handler http.Handler, path string, fixture string) *httptest.ResponseRecorder {
+	body, err := os.ReadFile(filepath.Join("testdata", fixture))
+	if err != nil {
+		t.Fatal(err)
+	}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, path, bytes.NewReader(body)))
+	return recorder
+}
+
+func TestKubeArmorHandlerDefaultMapping(t *testing.T) {
+	c := newFakeClient(t)
+	handler, err := NewKubeArmorHandler(c, DefaultKubeArmorMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := postFixture(t, handler, KubeArmorPath, "kubearmor/block-pkg-mgmt-tools-exec.json")
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	securityEvents := listSecurityEvents(t, c)
+	if len(securityEvents) != 1 {
+		t.Fatalf("expected 1 SecurityEvent, got %d", len(securityEvents))
+	}
+	spec := securityEvents[0].Spec
+	if len(spec.Targets) != 1 || spec.Targets[0] != "default/demo-page-6c7d5b8b8-x2xk5" {
+		t.Errorf("unexpected targets %v", spec.Targets)
+	}
+	// the rule of the kubearmor-integrator demo AMTD must keep matching
+	want := amtdv1beta1.Rule{Type: "block-pkg-mgmt-tools-exec", ThreatLevel: "1", Source: "KubeArmorIntegrator"}
+	if spec.Rule != want {
+		t.Errorf("unexpected rule %+v, want %+v", spec.Rule, want)
+	}
+}
+
+func TestKubeArmorHandlerCustomMapping(t *testing.T) {
+	mapping, err := LoadRuleMapping(filepath.Join("testdata", "kubearmor", "mapping.yaml"), DefaultKubeArmorMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c := newFakeClient(t)
+	handler, err := NewKubeArmorHandler(c, mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	recorder := postFixture(t, handler, KubeArmorPath, "kubearmor/alerts.jsonl")
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	// the host alert is ignored
+	securityEvents := listSecurityEvents(t, c)
+	if len(securityEvents) != 2 {
+		t.Fatalf("expected 2 SecurityEvents, got %d", len(securityEvents))
+	}
+	sort.Slice(securityEvents, func(i, j int) bool {
+		return securityEvents[i].Spec.Targets[0] < securityEvents[j].Spec.Targets[0]
+	})
+
+	want := []amtdv1beta1.SecurityEventSpec{
+		{
+			Targets:     []string{"default/demo-page-6c7d5b8b8-x2xk5"},
+			Rule:        amtdv1beta1.Rule{Type: "kubearmor/audit-sensitive-files", ThreatLevel: "medium", Source: "eBPF Monitor"},
+			Description: "KubeArmor: Sensitive file read (Audit File /etc/shadow by /usr/bin/cat)",
+		},
+		{
+			Targets:     []string{"shop/checkout-5f8d7c9b4-l9qzt"},
+			Rule:        amtdv1beta1.Rule{Type: "kubearmor/block-egress-tools", ThreatLevel: "high", Source: "AppArmor"},
+			Description: "KubeArmor: Network tool executed (Block Process /usr/bin/curl by /usr/bin/curl)",
+		},
+	}
+	for i := range want {
+		got := securityEvents[i].Spec
+		if got.Targets[0] != want[i].Targets[0] || got.Rule != want[i].Rule || got.Description != want[i].Description {
+			t.Errorf("unexpected spec %+v, want %+v", got, want[i])
+		}
+	}
+}
+
+func TestKubeArmorHandlerRetriesFailedAlerts(t *testing.T) {
+	failed := false
+	c := interceptor.NewClient(newFakeClient(t).(client.WithWatch), interceptor.Funcs{
+		Create: func(ctx context.Context, c client.WithWatch, obj client.Object, opts ...client.CreateOption) error {
+			securityEvent := obj.(*amtdv1beta1.SecurityEvent)
+			if !failed && securityEvent.Spec.Targets[0] == "shop/checkout-5f8d7c9b4-l9qzt" {
+				failed = true
+				return errors.New("etcdserver: request timed out")
+			}
+			return c.Create(ctx, obj, opts...)
+		},
+	})
+	handler, err := NewKubeArmorHandler(c, DefaultKubeArmorMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the alert of the shop namespace fails, the others are reported individually
+	recorder := postFixture(t, handler, KubeArmorPath, "kubearmor/alerts.jsonl")
+	if recorder.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var result response
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{}
+	for _, item := range result.Results {
+		statuses = append(statuses, item.Status)
+	}
+	if want := []string{resultCreated, resultIgnored, resultFailed}; !reflect.DeepEqual(statuses, want) {
+		t.Fatalf("expected results %v, got %+v", want, result.Results)
+	}
+
+	// sending the batch again only creates the SecurityEvent of the failed alert
+	recorder = postFixture(t, handler, KubeArmorPath, "kubearmor/alerts.jsonl")
+	if recorder.Code != http.StatusCreated {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	retried := response{}
+	if err := json.Unmarshal(recorder.Body.Bytes(), &retried); err != nil {
+		t.Fatal(err)
+	}
+	if retried.Results[0].Status != resultExists || retried.Results[0].SecurityEvent != result.Results[0].SecurityEvent || retried.Results[2].Status != resultCreated {
+		t.Errorf("unexpected results %+v", retried.Results)
+	}
+	if securityEvents := listSecurityEvents(t, c); len(securityEvents) != 2 {
+		t.Errorf("expected 2 SecurityEvents, got %d", len(securityEvents))
+	}
+}
+
+func TestKubeArmorHandlerDoesNotRetryRejectedAlerts(t *testing.T) {
+	handler, err := NewKubeArmorHandler(newRejectingClient(t), DefaultKubeArmorMapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the alerts rejected by the admission webhook must not make the forwarder send the batch again
+	recorder := postFixture(t, handler, KubeArmorPath, "kubearmor/alerts.jsonl")
+	if recorder.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("unexpected status %d: %s", recorder.Code, recorder.Body.String())
+	}
+	var result response
+	if err := json.Unmarshal(recorder.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	statuses := []string{}
+	for _, item := range result.Results {
+		statuses = append(statuses, item.Status)
+	}
+	if want := []string{resultRejected, resultIgnored, resultRejected}; !reflect.DeepEqual(statuses, want) {
+		t.Errorf("expected results %v, got %+v", want, result.Results)
+	}
+}
@@ -0,0 +1,127 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"sigs.k8s.io/yaml"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// RuleMapping maps a notification onto the rule and the description of a SecurityEvent.
+// Each field is a text/template that is executed on the notification, e.g. "{{ .PolicyName }}".
+type RuleMapping struct {
+	Type        string `json:"type,omitempty"`
+	ThreatLevel string `json:"threatLevel,omitempty"`
+	Source      string `json:"source,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// LoadRuleMapping reads a RuleMapping from a YAML or JSON file, fields missing from the
+// file are taken from defaults
+func LoadRuleMapping(path string, defaults RuleMapping) (RuleMapping, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return defaults, err
+	}
+
+	var mapping RuleMapping
+	if err := yaml.UnmarshalStrict(data, &mapping); err != nil {
+		return defaults, fmt.Errorf(`invalid mapping in "%s": %w`, path, err)
+	}
+	if mapping.Type == "" {
+		mapping.Type = defaults.Type
+	}
+	if mapping.ThreatLevel == "" {
+		mapping.ThreatLevel = defaults.ThreatLevel
+	}
+	if mapping.Source == "" {
+		mapping.Source = defaults.Source
+	}
+	if mapping.Description == "" {
+		mapping.Description = defaults.Description
+	}
+	return mapping, nil
+}
+
+// compiledRuleMapping is a RuleMapping with parsed templates
+type compiledRuleMapping struct {
+	typ, threatLevel, source, description *template.Template
+}
+
+// compile parses the templates of the mapping
+func (m RuleMapping) compile() (*compiledRuleMapping, error) {
+	parse := func(name string, text string) (*template.Template, error) {
+		t, err := template.New(name).Option("missingkey=zero").Parse(text)
+		if err != nil {
+			return nil, fmt.Errorf("invalid %s template: %w", name, err)
+		}
+		return t, nil
+	}
+
+	var compiled compiledRuleMapping
+	var err error
+	if compiled.typ, err = parse("type", m.Type); err != nil {
+		return nil, err
+	}
+	if compiled.threatLevel, err = parse("threatLevel", m.ThreatLevel); err != nil {
+		return nil, err
+	}
+	if compiled.source, err = parse("source", m.Source); err != nil {
+		return nil, err
+	}
+	if compiled.description, err = parse("description", m.Description); err != nil {
+		return nil, err
+	}
+	return &compiled, nil
+}
+
+// render executes the templates on data
+func (c *compiledRuleMapping) render(data interface{}) (amtdv1beta1.Rule, string, error) {
+	execute := func(t *template.Template) (string, error) {
+		var out bytes.Buffer
+		if err := t.Execute(&out, data); err != nil {
+			return "", err
+		}
+		// missing map keys of map[string]string are rendered as "<no value>"
+		return strings.TrimSpace(strings.ReplaceAll(out.String(), "<no value>", "")), nil
+	}
+
+	var rule amtdv1beta1.Rule
+	var description string
+	var err error
+	if rule.Type, err = execute(c.typ); err != nil {
+		return rule, "", err
+	}
+	if rule.ThreatLevel, err = execute(c.threatLevel); err != nil {
+		return rule, "", err
+	}
+	if rule.Source, err = execute(c.source); err != nil {
+		return rule, "", err
+	}
+	if description, err = execute(c.description); err != nil {
+		return rule, "", err
+	}
+	return rule, description, nil
+}
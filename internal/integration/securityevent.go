@@ -0,0 +1,139 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// maxBodySize limits the size of the notifications
+const maxBodySize = 1 << 20
+
+// response is the JSON body the endpoints answer with
+type response struct {
+	SecurityEvents []string     `json:"securityEvents,omitempty"`
+	Message        string       `json:"message,omitempty"`
+	Error          string       `json:"error,omitempty"`
+	Errors         []string     `json:"errors,omitempty"`
+	Results        []itemResult `json:"results,omitempty"`
+}
+
+// Outcomes of the notifications of a batch
+const (
+	resultCreated  = "created"
+	resultExists   = "exists"
+	resultIgnored  = "ignored"
+	resultRejected = "rejected"
+	resultFailed   = "failed"
+)
+
+// itemResult is the outcome of one notification of a batch, in the order of the batch
+type itemResult struct {
+	Status        string `json:"status"`
+	SecurityEvent string `json:"securityEvent,omitempty"`
+	Error         string `json:"error,omitempty"`
+}
+
+// createSecurityEvent creates a SecurityEvent with a generated name that starts with prefix
+func createSecurityEvent(ctx context.Context, c client.Client, prefix string, spec amtdv1beta1.SecurityEventSpec, labels map[string]string) (*amtdv1beta1.SecurityEvent, error) {
+	securityEvent := &amtdv1beta1.SecurityEvent{
+		ObjectMeta: metav1.ObjectMeta{
+			GenerateName: prefix + "-",
+			Labels:       labels,
+		},
+		Spec: spec,
+	}
+	if err := c.Create(ctx, securityEvent); err != nil {
+		log.FromContext(ctx).Error(err, fmt.Sprintf(`Failed to create SecurityEvent for targets %v: %s`, spec.Targets, err.Error()))
+		return nil, err
+	}
+	log.FromContext(ctx).Info(fmt.Sprintf(`SecurityEvent "%s" created for targets %v`, securityEvent.Name, spec.Targets))
+	return securityEvent, nil
+}
+
+// createNamedSecurityEvent creates a SecurityEvent with the given name, a notification that is
+// delivered again finds its SecurityEvent already existing: found is true and nothing is created
+func createNamedSecurityEvent(ctx context.Context, c client.Client, name string, spec amtdv1beta1.SecurityEventSpec, labels map[string]string) (found bool, err error) {
+	securityEvent := &amtdv1beta1.SecurityEvent{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Spec: spec,
+	}
+	if err := c.Create(ctx, securityEvent); err != nil {
+		if apierrors.IsAlreadyExists(err) {
+			log.FromContext(ctx).Info(fmt.Sprintf(`SecurityEvent "%s" already exists`, name))
+			return true, nil
+		}
+		log.FromContext(ctx).Error(err, fmt.Sprintf(`Failed to create SecurityEvent for targets %v: %s`, spec.Targets, err.Error()))
+		return false, err
+	}
+	log.FromContext(ctx).Info(fmt.Sprintf(`SecurityEvent "%s" created for targets %v`, name, spec.Targets))
+	return false, nil
+}
+
+// createErrorStatus returns the HTTP status for a SecurityEvent that could not be created. The
+// SecurityEvents rejected by the admission webhooks are answered with a client error, so that the
+// sender does not retry them.
+func createErrorStatus(err error) int {
+	switch {
+	case rejected(err):
+		return http.StatusUnprocessableEntity
+	case apierrors.IsAlreadyExists(err):
+		return http.StatusConflict
+	}
+	return http.StatusInternalServerError
+}
+
+// rejected returns true when the SecurityEvent was rejected by the API server or the admission
+// webhooks, sending it again gives the same result
+func rejected(err error) bool {
+	return apierrors.IsInvalid(err) || apierrors.IsForbidden(err) || apierrors.IsBadRequest(err)
+}
+
+// readBody reads the body of a POST request
+func readBody(w http.ResponseWriter, r *http.Request) ([]byte, bool) {
+	if r.Method != http.MethodPost {
+		writeResponse(w, http.StatusMethodNotAllowed, response{Error: "only POST is supported"})
+		return nil, false
+	}
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+	if err != nil {
+		writeResponse(w, http.StatusBadRequest, response{Error: err.Error()})
+		return nil, false
+	}
+	return body, true
+}
+
+func writeResponse(w http.ResponseWriter, status int, body response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(body)
+}
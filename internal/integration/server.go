@@ -0,0 +1,191 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+// Package integration contains the built-in Integration Backends: HTTP endpoints that
+// receive the notifications of security analytics tools and create SecurityEvents from them.
+package integration
+
+import (
+	"context"
+	"crypto/subtle"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/certwatcher"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// Server serves the endpoints of the built-in Integration Backends. It implements
+// manager.Runnable so that it is started and stopped together with the manager.
+// Every request must either present the bearer token read from TokenFile or a client
+// certificate signed by the CA in CertDir.
+type Server struct {
+	// Addr is the address the server listens on
+	Addr string
+	// TokenFile is the path of the file that contains the bearer token, typically a key of a
+	// Secret mounted into the pod. It is read on every request so that the token can be rotated
+	// by updating the Secret.
+	TokenFile string
+	// CertDir is the directory with the serving certificate (tls.crt and tls.key), if it is set
+	// the server serves HTTPS. If the directory contains a ca.crt too, the clients presenting a
+	// certificate signed by it are authenticated without a bearer token.
+	CertDir string
+
+	mux *http.ServeMux
+}
+
+// NewServer returns a server without any endpoints
+func NewServer(addr string, tokenFile string, certDir string) *Server {
+	return &Server{Addr: addr, TokenFile: tokenFile, CertDir: certDir, mux: http.NewServeMux()}
+}
+
+// Handle registers the handler of an Integration Backend for the given path
+func (s *Server) Handle(path string, handler http.Handler) {
+	s.mux.Handle(path, handler)
+}
+
+// Start runs the server until ctx is cancelled
+func (s *Server) Start(ctx context.Context) error {
+	log := log.FromContext(ctx).WithName("integration")
+
+	server := &http.Server{
+		Addr:              s.Addr,
+		Handler:           s.authenticate(s.mux),
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+	clientCAs, err := s.configureTLS(ctx, server)
+	if err != nil {
+		return err
+	}
+	if s.TokenFile != "" || clientCAs == nil {
+		if _, err := s.token(); err != nil {
+			return err
+		}
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		log.Info("Starting Integration Backend server", "address", s.Addr, "tls", server.TLSConfig != nil)
+		listen := server.ListenAndServe
+		if server.TLSConfig != nil {
+			listen = func() error { return server.ListenAndServeTLS("", "") }
+		}
+		if err := listen(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			errCh <- err
+		}
+		close(errCh)
+	}()
+
+	select {
+	case err := <-errCh:
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		return server.Shutdown(shutdownCtx)
+	}
+}
+
+// NeedLeaderElection returns false, every replica of the operator accepts notifications
+func (s *Server) NeedLeaderElection() bool {
+	return false
+}
+
+// configureTLS sets up the serving certificate and the client CAs of the server if CertDir is set,
+// the client CAs are nil if client certificates are not accepted
+func (s *Server) configureTLS(ctx context.Context, server *http.Server) (*x509.CertPool, error) {
+	if s.CertDir == "" {
+		return nil, nil
+	}
+	watcher, err := certwatcher.New(filepath.Join(s.CertDir, "tls.crt"), filepath.Join(s.CertDir, "tls.key"))
+	if err != nil {
+		return nil, fmt.Errorf("unable to load the serving certificate of the Integration Backends: %w", err)
+	}
+	go func() {
+		if err := watcher.Start(ctx); err != nil {
+			log.FromContext(ctx).Error(err, "Failed to watch the serving certificate of the Integration Backends")
+		}
+	}()
+	server.TLSConfig = &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: watcher.GetCertificate,
+	}
+
+	caFile := filepath.Join(s.CertDir, "ca.crt")
+	content, err := os.ReadFile(caFile)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read the client CA of the Integration Backends: %w", err)
+	}
+	clientCAs := x509.NewCertPool()
+	if !clientCAs.AppendCertsFromPEM(content) {
+		return nil, fmt.Errorf(`no certificate found in "%s"`, caFile)
+	}
+	server.TLSConfig.ClientCAs = clientCAs
+	server.TLSConfig.ClientAuth = tls.VerifyClientCertIfGiven
+	return clientCAs, nil
+}
+
+// token returns the bearer token the requests must present
+func (s *Server) token() (string, error) {
+	content, err := os.ReadFile(s.TokenFile)
+	if err != nil {
+		return "", fmt.Errorf("unable to read the token of the Integration Backends: %w", err)
+	}
+	token := strings.TrimSpace(string(content))
+	if token == "" {
+		return "", fmt.Errorf(`the token file "%s" of the Integration Backends is empty`, s.TokenFile)
+	}
+	return token, nil
+}
+
+// authenticate rejects the requests that present neither a verified client certificate nor the bearer token
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if s.TokenFile == "" {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeResponse(w, http.StatusUnauthorized, response{Error: "a client certificate is required"})
+			return
+		}
+		token, err := s.token()
+		if err != nil {
+			log.FromContext(r.Context()).Error(err, "Failed to authenticate request")
+			writeResponse(w, http.StatusInternalServerError, response{Error: "unable to authenticate the request"})
+			return
+		}
+		scheme, presented, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+		if !strings.EqualFold(scheme, "Bearer") || subtle.ConstantTimeCompare([]byte(strings.TrimSpace(presented)), []byte(token)) != 1 {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			writeResponse(w, http.StatusUnauthorized, response{Error: "a valid bearer token is required"})
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
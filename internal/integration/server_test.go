@@ -0,0 +1,119 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package integration
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestServerRequiresToken(t *testing.T) {
+	tokenFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(tokenFile, []byte("secret\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	server := NewServer(":0", tokenFile, "")
+	server.Handle("/test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	handler := server.authenticate(server.mux)
+
+	tests := []struct {
+		name          string
+		authorization string
+		status        int
+	}{
+		{"no token", "", http.StatusUnauthorized},
+		{"wrong token", "Bearer other", http.StatusUnauthorized},
+		{"wrong scheme", "Basic secret", http.StatusUnauthorized},
+		{"valid token", "Bearer secret", http.StatusNoContent},
+		{"case insensitive scheme", "bearer secret", http.StatusNoContent},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/test", nil)
+			if tt.authorization != "" {
+				request.Header.Set("Authorization", tt.authorization)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, request)
+			if recorder.Code != tt.status {
+				t.Errorf("expected status %d, got %d: %s", tt.status, recorder.Code, recorder.Body.String())
+			}
+		})
+	}
+
+	// the token is read on every request so that it can be rotated
+	if err := os.WriteFile(tokenFile, []byte("rotated"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	request := httptest.NewRequest(http.MethodPost, "/test", nil)
+	request.Header.Set("Authorization", "Bearer rotated")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("expected the rotated token to be accepted, got %d", recorder.Code)
+	}
+}
+
+func TestServerDoesNotStartWithoutToken(t *testing.T) {
+	emptyFile := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(emptyFile, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	for _, tokenFile := range []string{"", emptyFile, filepath.Join(t.TempDir(), "missing")} {
+		if err := NewServer(":0", tokenFile, "").Start(t.Context()); err == nil {
+			t.Errorf("expected an error for token file %q", tokenFile)
+		}
+	}
+	if err := NewServer(":0", "", t.TempDir()).Start(t.Context()); err == nil {
+		t.Errorf("expected an error for a certificate directory without serving certificate")
+	}
+}
+
+func TestServerAcceptsClientCertificates(t *testing.T) {
+	server := NewServer(":0", "", "")
+	server.Handle("/test", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	handler := server.authenticate(server.mux)
+
+	// the TLS handshake verified the client certificate against the client CA
+	request := httptest.NewRequest(http.MethodPost, "/test", nil)
+	request.TLS = &tls.ConnectionState{VerifiedChains: [][]*x509.Certificate{{{}}}}
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusNoContent {
+		t.Errorf("expected the client certificate to be accepted, got %d", recorder.Code)
+	}
+
+	// without a token file only client certificates are accepted
+	request = httptest.NewRequest(http.MethodPost, "/test", nil)
+	request.TLS = &tls.ConnectionState{}
+	request.Header.Set("Authorization", "Bearer ")
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, request)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Errorf("expected status %d without a client certificate, got %d", http.StatusUnauthorized, recorder.Code)
+	}
+}
@@ -0,0 +1,138 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+// Package metrics defines the Prometheus metrics of the detection-to-response pipeline.
+// They are served by the metrics server of the manager together with the controller-runtime metrics.
+package metrics
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	ctrlmetrics "sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+const namespace = "phoenix"
+
+// Results of the actions
+const (
+	RESULT_SUCCEEDED = "succeeded"
+	RESULT_FAILED    = "failed"
+	// RESULT_AUDITED is the result of actions that were planned but not executed in audit mode
+	RESULT_AUDITED = "audited"
+)
+
+var (
+	securityEventsReceived = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "security_events_received_total",
+		Help:      "Number of SecurityEvents received, by the fields of their rule.",
+	}, []string{"source", "type", "threat_level"})
+
+	actionsExecuted = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "actions_total",
+		Help:      "Number of actions executed on pods, by action and result. Actions planned in audit mode have the audited result.",
+	}, []string{"action", "result"})
+
+	responseLatency = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "response_latency_seconds",
+		Help:      "Time from the creation of a SecurityEvent to the completion of the action on a target.",
+		Buckets:   []float64{0.5, 1, 2, 5, 10, 30, 60, 120, 300, 600},
+	}, []string{"action", "result"})
+
+	quarantinedPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "quarantined_pods",
+		Help:      "Number of pods that are currently quarantined, by namespace.",
+	}, []string{"namespace"})
+
+	managedPods = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "managed_pods",
+		Help:      "Number of pods managed by an AdaptiveMovingTargetDefense.",
+	}, []string{"namespace", "amtd"})
+
+	rotations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rotations_total",
+		Help:      "Number of pods rotated periodically by an AdaptiveMovingTargetDefense, by result. Rotations planned in audit mode have the audited result.",
+	}, []string{"namespace", "amtd", "result"})
+)
+
+func init() {
+	ctrlmetrics.Registry.MustRegister(securityEventsReceived, actionsExecuted, responseLatency, quarantinedPods, managedPods, rotations)
+}
+
+// RecordSecurityEvent counts a SecurityEvent seen for the first time
+func RecordSecurityEvent(securityEvent *amtdv1beta1.SecurityEvent) {
+	rule := securityEvent.Spec.Rule
+	securityEventsReceived.WithLabelValues(rule.Source, rule.Type, rule.ThreatLevel).Inc()
+}
+
+// RecordAction counts an action executed in response to the SecurityEvent and observes the time
+// passed since the SecurityEvent was created
+func RecordAction(securityEvent *amtdv1beta1.SecurityEvent, action string, result string) {
+	actionsExecuted.WithLabelValues(action, result).Inc()
+	if created := securityEvent.CreationTimestamp; !created.IsZero() {
+		responseLatency.WithLabelValues(action, result).Observe(time.Since(created.Time).Seconds())
+	}
+}
+
+// SetManagedPods sets the number of pods managed by the AMTD
+func SetManagedPods(AMTDNamespace string, AMTDName string, count int) {
+	managedPods.WithLabelValues(AMTDNamespace, AMTDName).Set(float64(count))
+}
+
+// DeleteManagedPods removes the series of a deleted AMTD
+func DeleteManagedPods(AMTDNamespace string, AMTDName string) {
+	managedPods.DeleteLabelValues(AMTDNamespace, AMTDName)
+}
+
+// RecordRotation counts a pod rotated periodically by the AMTD
+func RecordRotation(AMTDNamespace string, AMTDName string, result string) {
+	rotations.WithLabelValues(AMTDNamespace, AMTDName, result).Inc()
+}
+
+// quarantined holds the quarantined pods per namespace, pod events are seen more than once
+// so the gauge is derived from the set instead of being incremented
+var quarantined = struct {
+	sync.Mutex
+	pods map[string]map[string]bool
+}{pods: map[string]map[string]bool{}}
+
+// SetQuarantined records whether the pod is quarantined
+func SetQuarantined(podNamespace string, podName string, isQuarantined bool) {
+	quarantined.Lock()
+	defer quarantined.Unlock()
+
+	pods := quarantined.pods[podNamespace]
+	if isQuarantined {
+		if pods == nil {
+			pods = map[string]bool{}
+			quarantined.pods[podNamespace] = pods
+		}
+		pods[podName] = true
+	} else {
+		delete(pods, podName)
+	}
+	quarantinedPods.WithLabelValues(podNamespace).Set(float64(len(pods)))
+}
@@ -0,0 +1,76 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package metrics
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+func TestRecordAction(t *testing.T) {
+	securityEvent := &amtdv1beta1.SecurityEvent{
+		ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(time.Now().Add(-3 * time.Second))},
+		Spec:       amtdv1beta1.SecurityEventSpec{Rule: amtdv1beta1.Rule{Type: "reverse-shell", ThreatLevel: "critical", Source: "falco"}},
+	}
+	RecordSecurityEvent(securityEvent)
+	RecordAction(securityEvent, "quarantine", RESULT_SUCCEEDED)
+	RecordAction(securityEvent, "quarantine", RESULT_SUCCEEDED)
+	RecordAction(securityEvent, "delete", RESULT_FAILED)
+
+	if got := testutil.ToFloat64(securityEventsReceived.WithLabelValues("falco", "reverse-shell", "critical")); got != 1 {
+		t.Errorf("expected 1 received SecurityEvent, got %v", got)
+	}
+	if got := testutil.ToFloat64(actionsExecuted.WithLabelValues("quarantine", RESULT_SUCCEEDED)); got != 2 {
+		t.Errorf("expected 2 quarantines, got %v", got)
+	}
+	if got := testutil.CollectAndCount(responseLatency); got != 2 {
+		t.Errorf("expected latency series for 2 action results, got %d", got)
+	}
+}
+
+func TestSetQuarantined(t *testing.T) {
+	SetQuarantined("shop", "checkout-1", true)
+	SetQuarantined("shop", "checkout-2", true)
+	// pod events are seen repeatedly
+	SetQuarantined("shop", "checkout-2", true)
+	if got := testutil.ToFloat64(quarantinedPods.WithLabelValues("shop")); got != 2 {
+		t.Errorf("expected 2 quarantined pods, got %v", got)
+	}
+
+	SetQuarantined("shop", "checkout-1", false)
+	SetQuarantined("web", "frontend-1", false)
+	if got := testutil.ToFloat64(quarantinedPods.WithLabelValues("shop")); got != 1 {
+		t.Errorf("expected 1 quarantined pod, got %v", got)
+	}
+}
+
+func TestManagedPods(t *testing.T) {
+	SetManagedPods("shop", "checkout", 3)
+	if got := testutil.ToFloat64(managedPods.WithLabelValues("shop", "checkout")); got != 3 {
+		t.Errorf("expected 3 managed pods, got %v", got)
+	}
+	DeleteManagedPods("shop", "checkout")
+	if got := testutil.CollectAndCount(managedPods); got != 0 {
+		t.Errorf("expected no series after the AMTD was deleted, got %d", got)
+	}
+}
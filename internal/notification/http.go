@@ -0,0 +1,160 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/uuid"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// ActionCloudEventTypePrefix is the prefix of the type of the CloudEvents sent about action outcomes,
+// it is followed by the outcome in lower case, e.g. com.r6security.phoenix.action.succeeded
+const ActionCloudEventTypePrefix = "com.r6security.phoenix.action."
+
+// webhookSender posts the notification as JSON
+func (d *Dispatcher) webhookSender(url string, headers map[string]string) sender {
+	return func(ctx context.Context, notification Notification) error {
+		return d.post(ctx, url, headers, "application/json", notification)
+	}
+}
+
+// slackMessage is the payload of Slack-compatible incoming webhooks
+type slackMessage struct {
+	Text        string            `json:"text"`
+	Channel     string            `json:"channel,omitempty"`
+	Username    string            `json:"username,omitempty"`
+	Attachments []slackAttachment `json:"attachments,omitempty"`
+}
+
+type slackAttachment struct {
+	Color  string       `json:"color"`
+	Fields []slackField `json:"fields"`
+}
+
+type slackField struct {
+	Title string `json:"title"`
+	Value string `json:"value"`
+	Short bool   `json:"short"`
+}
+
+// slackSender posts the notification as a message with the details in an attachment
+func (d *Dispatcher) slackSender(url string, slack *amtdv1beta1.SlackSink) sender {
+	return func(ctx context.Context, notification Notification) error {
+		color := "good"
+		if notification.Outcome == amtdv1beta1.ActionFailed {
+			color = "danger"
+		}
+		fields := []slackField{
+			{Title: "Rule", Value: fmt.Sprintf("type=%s threatLevel=%s source=%s", notification.Rule.Type, notification.Rule.ThreatLevel, notification.Rule.Source)},
+			{Title: "AdaptiveMovingTargetDefense", Value: notification.AMTD, Short: true},
+			{Title: "Action", Value: notification.Action, Short: true},
+		}
+		if notification.Description != "" {
+			fields = append(fields, slackField{Title: "Description", Value: notification.Description})
+		}
+		if notification.Error != "" {
+			fields = append(fields, slackField{Title: "Error", Value: notification.Error})
+		}
+		message := slackMessage{
+			Text:        notification.Summary(),
+			Channel:     slack.Channel,
+			Username:    slack.Username,
+			Attachments: []slackAttachment{{Color: color, Fields: fields}},
+		}
+		return d.post(ctx, url, slack.Headers, "application/json", message)
+	}
+}
+
+// cloudEvent is a structured mode CloudEvent with a notification as data
+type cloudEvent struct {
+	SpecVersion     string       `json:"specversion"`
+	Type            string       `json:"type"`
+	Source          string       `json:"source"`
+	ID              string       `json:"id"`
+	Time            string       `json:"time"`
+	Subject         string       `json:"subject"`
+	DataContentType string       `json:"datacontenttype"`
+	Data            Notification `json:"data"`
+}
+
+// cloudEventsSender posts the notification as a structured CloudEvent
+func (d *Dispatcher) cloudEventsSender(url string, sink *amtdv1beta1.CloudEventsSink) sender {
+	source := sink.Source
+	if source == "" {
+		source = "phoenix"
+	}
+	// the sender is created for each notification, the id is kept across retries so that
+	// receivers can drop duplicates
+	id := string(uuid.NewUUID())
+	return func(ctx context.Context, notification Notification) error {
+		event := cloudEvent{
+			SpecVersion:     "1.0",
+			Type:            ActionCloudEventTypePrefix + strings.ToLower(string(notification.Outcome)),
+			Source:          source,
+			ID:              id,
+			Time:            notification.Time.UTC().Format(time.RFC3339),
+			Subject:         notification.Target,
+			DataContentType: "application/json",
+			Data:            notification,
+		}
+		return d.post(ctx, url, sink.Headers, "application/cloudevents+json", event)
+	}
+}
+
+// post sends the payload as JSON, 4xx responses other than 408 and 429 are not retried
+func (d *Dispatcher) post(ctx context.Context, url string, headers map[string]string, contentType string, payload any) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return permanentError{err}
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return permanentError{err}
+	}
+	for key, value := range headers {
+		request.Header.Set(key, value)
+	}
+	request.Header.Set("Content-Type", contentType)
+
+	response, err := d.HTTPClient.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	message, _ := io.ReadAll(io.LimitReader(response.Body, 1024))
+
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return nil
+	}
+	err = fmt.Errorf("%s responded %s: %s", url, response.Status, strings.TrimSpace(string(message)))
+	if response.StatusCode >= 400 && response.StatusCode < 500 &&
+		response.StatusCode != http.StatusRequestTimeout && response.StatusCode != http.StatusTooManyRequests {
+		return permanentError{err}
+	}
+	return err
+}
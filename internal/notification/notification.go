@@ -0,0 +1,253 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package notification
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+const (
+	// DEFAULT_ATTEMPTS is the number of delivery attempts if the sink does not configure it
+	DEFAULT_ATTEMPTS = 3
+	// DEFAULT_BACKOFF is the delay before the first redelivery if the sink does not configure it
+	DEFAULT_BACKOFF = time.Second
+	// MAX_BACKOFF caps the exponential growth of the delay between redeliveries
+	MAX_BACKOFF = time.Minute
+	// DEFAULT_TIMEOUT limits the delivery of a notification to a sink including the retries
+	DEFAULT_TIMEOUT = 2 * time.Minute
+	// MAX_CONCURRENT_DELIVERIES limits the notifications delivered in the background at the same time
+	MAX_CONCURRENT_DELIVERIES = 32
+)
+
+// Notification describes the outcome of an action executed on a target of a SecurityEvent
+type Notification struct {
+	// Time of the outcome
+	Time time.Time `json:"time"`
+	// Outcome of the action
+	Outcome amtdv1beta1.ActionOutcome `json:"outcome"`
+	// Action is the name of the executed action, e.g. quarantine
+	Action string `json:"action"`
+	// Target is the pod in "<namespace>/<name>" format
+	Target string `json:"target"`
+	// SecurityEvent is the name of the SecurityEvent the action responded to
+	SecurityEvent string `json:"securityEvent"`
+	// Rule of the SecurityEvent
+	Rule amtdv1beta1.Rule `json:"rule"`
+	// Description of the SecurityEvent
+	Description string `json:"description,omitempty"`
+	// AMTD is the AdaptiveMovingTargetDefense in "<namespace>/<name>" format whose strategy was executed
+	AMTD string `json:"amtd"`
+	// Strategy is the rule of the executed strategy, empty if the default action was executed
+	Strategy *amtdv1beta1.Rule `json:"strategy,omitempty"`
+	// Error is the reason of the failure
+	Error string `json:"error,omitempty"`
+}
+
+// Summary is a one line, human readable description of the notification
+func (n Notification) Summary() string {
+	return fmt.Sprintf(`Action "%s" %s on pod "%s" in response to SecurityEvent "%s"`,
+		n.Action, strings.ToLower(string(n.Outcome)), n.Target, n.SecurityEvent)
+}
+
+// sender delivers a notification once
+type sender func(ctx context.Context, notification Notification) error
+
+// permanentError is a delivery error that is not worth retrying, e.g. the sink rejected the notification
+type permanentError struct {
+	error
+}
+
+// Dispatcher delivers notifications to the NotificationSinks referenced by AMTDs
+type Dispatcher struct {
+	Client client.Client
+	// SecretReader reads the Secrets referred by the sinks. It should not be cached: a cache would
+	// watch every Secret of the cluster.
+	SecretReader client.Reader
+	// HTTPClient is used by the webhook, Slack and CloudEvents sinks
+	HTTPClient *http.Client
+	// Timeout limits the delivery of a notification to a sink including the retries
+	Timeout time.Duration
+
+	// deliveries holds a token for every delivery in progress
+	deliveries chan struct{}
+}
+
+// NewDispatcher returns a Dispatcher that reads the NotificationSinks with the client and their
+// Secrets with the secret reader
+func NewDispatcher(c client.Client, secretReader client.Reader) *Dispatcher {
+	return &Dispatcher{
+		Client:       c,
+		SecretReader: secretReader,
+		HTTPClient:   &http.Client{Timeout: 10 * time.Second},
+		Timeout:      DEFAULT_TIMEOUT,
+		deliveries:   make(chan struct{}, MAX_CONCURRENT_DELIVERIES),
+	}
+}
+
+// Notify delivers the notification to the NotificationSinks of the AMTD in the background,
+// so slow or unavailable sinks do not hold up the response to SecurityEvents. When
+// MAX_CONCURRENT_DELIVERIES notifications are already being delivered, the notification is dropped.
+func (d *Dispatcher) Notify(ctx context.Context, AMTD *amtdv1beta1.AdaptiveMovingTargetDefense, notification Notification) {
+	log := log.FromContext(ctx)
+
+	for _, reference := range AMTD.Spec.NotificationSinks {
+		sink := &amtdv1beta1.NotificationSink{}
+		key := types.NamespacedName{Namespace: AMTD.Namespace, Name: reference.Name}
+		if err := d.Client.Get(ctx, key, sink); err != nil {
+			log.Error(err, fmt.Sprintf(`Failed to retrieve NotificationSink "%s" of AdaptiveMovingTargetDefense "%s"`, key, AMTD.Name))
+			continue
+		}
+		if !notifies(sink, notification.Outcome) {
+			continue
+		}
+
+		select {
+		case d.deliveries <- struct{}{}:
+		default:
+			log.Error(errors.New("too many notifications in progress"), fmt.Sprintf(`Dropped notification to NotificationSink "%s": %s`, key, notification.Summary()))
+			continue
+		}
+		go func() {
+			defer func() { <-d.deliveries }()
+			ctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), d.Timeout)
+			defer cancel()
+			if err := d.Deliver(ctx, sink, notification); err != nil {
+				log.Error(err, fmt.Sprintf(`Failed to deliver notification to NotificationSink "%s"`, key))
+			}
+		}()
+	}
+}
+
+// Deliver sends the notification to the sink, retrying with exponential backoff as configured in the sink
+func (d *Dispatcher) Deliver(ctx context.Context, sink *amtdv1beta1.NotificationSink, notification Notification) error {
+	send, err := d.sender(ctx, sink)
+	if err != nil {
+		return err
+	}
+
+	attempts, backoff := int32(DEFAULT_ATTEMPTS), DEFAULT_BACKOFF
+	if retry := sink.Spec.Retry; retry != nil {
+		if retry.Attempts > 0 {
+			attempts = retry.Attempts
+		}
+		if retry.Backoff.Duration > 0 {
+			backoff = retry.Backoff.Duration
+		}
+	}
+
+	for attempt := int32(1); ; attempt++ {
+		err = send(ctx, notification)
+		var permanent permanentError
+		if err == nil || attempt >= attempts || errors.As(err, &permanent) {
+			return err
+		}
+		log.FromContext(ctx).Info(fmt.Sprintf(`Delivery to NotificationSink "%s/%s" failed (attempt %d of %d): %s`, sink.Namespace, sink.Name, attempt, attempts, err.Error()))
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff = min(backoff*2, MAX_BACKOFF)
+	}
+}
+
+// sender returns the sender of the kind of sink that is set
+func (d *Dispatcher) sender(ctx context.Context, sink *amtdv1beta1.NotificationSink) (sender, error) {
+	spec := sink.Spec
+	switch {
+	case spec.Webhook != nil:
+		url, err := d.url(ctx, sink.Namespace, spec.Webhook.HTTPEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		return d.webhookSender(url, spec.Webhook.Headers), nil
+	case spec.Slack != nil:
+		url, err := d.url(ctx, sink.Namespace, spec.Slack.HTTPEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		return d.slackSender(url, spec.Slack), nil
+	case spec.CloudEvents != nil:
+		url, err := d.url(ctx, sink.Namespace, spec.CloudEvents.HTTPEndpoint)
+		if err != nil {
+			return nil, err
+		}
+		return d.cloudEventsSender(url, spec.CloudEvents), nil
+	case spec.SMTP != nil:
+		password := ""
+		if spec.SMTP.PasswordSecretRef != nil {
+			var err error
+			if password, err = d.secretValue(ctx, sink.Namespace, spec.SMTP.PasswordSecretRef); err != nil {
+				return nil, err
+			}
+		}
+		return smtpSender(spec.SMTP, password), nil
+	default:
+		return nil, fmt.Errorf(`NotificationSink "%s/%s" has no webhook, slack, cloudEvents or smtp configuration`, sink.Namespace, sink.Name)
+	}
+}
+
+// url returns the URL of the endpoint, reading it from the referred Secret if any
+func (d *Dispatcher) url(ctx context.Context, namespace string, endpoint amtdv1beta1.HTTPEndpoint) (string, error) {
+	if endpoint.URLSecretRef != nil {
+		return d.secretValue(ctx, namespace, endpoint.URLSecretRef)
+	}
+	if endpoint.URL == "" {
+		return "", errors.New("either url or urlSecretRef must be set")
+	}
+	return endpoint.URL, nil
+}
+
+// secretValue returns the value of the selected key of a Secret
+func (d *Dispatcher) secretValue(ctx context.Context, namespace string, selector *corev1.SecretKeySelector) (string, error) {
+	secret := &corev1.Secret{}
+	if err := d.SecretReader.Get(ctx, types.NamespacedName{Namespace: namespace, Name: selector.Name}, secret); err != nil {
+		return "", err
+	}
+	value, found := secret.Data[selector.Key]
+	if !found {
+		return "", fmt.Errorf(`Secret "%s/%s" has no key "%s"`, namespace, selector.Name, selector.Key)
+	}
+	return strings.TrimSpace(string(value)), nil
+}
+
+// notifies reports whether the sink is interested in the outcome
+func notifies(sink *amtdv1beta1.NotificationSink, outcome amtdv1beta1.ActionOutcome) bool {
+	if len(sink.Spec.Outcomes) == 0 {
+		return true
+	}
+	for _, wanted := range sink.Spec.Outcomes {
+		if wanted == outcome {
+			return true
+		}
+	}
+	return false
+}
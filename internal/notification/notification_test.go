@@ -0,0 +1,321 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package notification
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+var testNotification = Notification{
+	Time:          time.Date(2024, 3, 1, 15, 30, 0, 0, time.UTC),
+	Outcome:       amtdv1beta1.ActionSucceeded,
+	Action:        "quarantine",
+	Target:        "shop/checkout-5f8d7c9b4-l9qzt",
+	SecurityEvent: "falco-x7k2p",
+	Rule:          amtdv1beta1.Rule{Type: "Terminal shell in container", ThreatLevel: "Notice", Source: "FalcoIntegrator"},
+	AMTD:          "shop/checkout",
+}
+
+func newFakeClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := amtdv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := corev1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+// receiver is a stand-in for the HTTP sinks that records the requests and fails the first ones
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, request *http.Request) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var body json.RawMessage
+	json.NewDecoder(request.Body).Decode(&body)
+	r.requests = append(r.requests, request)
+	r.bodies = append(r.bodies, body)
+	if len(r.requests) <= r.failures {
+		w.WriteHeader(r.status)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func newSink(spec amtdv1beta1.NotificationSinkSpec) *amtdv1beta1.NotificationSink {
+	if spec.Retry == nil {
+		spec.Retry = &amtdv1beta1.NotificationRetry{Attempts: 3, Backoff: metav1.Duration{Duration: time.Millisecond}}
+	}
+	return &amtdv1beta1.NotificationSink{
+		ObjectMeta: metav1.ObjectMeta{Name: "sink", Namespace: "shop"},
+		Spec:       spec,
+	}
+}
+
+func TestWebhookRetries(t *testing.T) {
+	stub := &receiver{failures: 2, status: http.StatusServiceUnavailable}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	dispatcher := NewDispatcher(newFakeClient(t), newFakeClient(t))
+	sink := newSink(amtdv1beta1.NotificationSinkSpec{Webhook: &amtdv1beta1.WebhookSink{
+		HTTPEndpoint: amtdv1beta1.HTTPEndpoint{URL: server.URL, Headers: map[string]string{"Authorization": "Bearer token"}},
+	}})
+	if err := dispatcher.Deliver(context.Background(), sink, testNotification); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(stub.requests) != 3 {
+		t.Fatalf("expected 3 attempts, got %d", len(stub.requests))
+	}
+	if got := stub.requests[2].Header.Get("Authorization"); got != "Bearer token" {
+		t.Errorf("unexpected Authorization header %q", got)
+	}
+	var notification Notification
+	if err := json.Unmarshal(stub.bodies[2], &notification); err != nil {
+		t.Fatal(err)
+	}
+	if notification.Target != testNotification.Target || notification.Outcome != amtdv1beta1.ActionSucceeded {
+		t.Errorf("unexpected payload %+v", notification)
+	}
+}
+
+func TestWebhookDoesNotRetryRejectedNotifications(t *testing.T) {
+	stub := &receiver{failures: 3, status: http.StatusBadRequest}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	dispatcher := NewDispatcher(newFakeClient(t), newFakeClient(t))
+	sink := newSink(amtdv1beta1.NotificationSinkSpec{Webhook: &amtdv1beta1.WebhookSink{
+		HTTPEndpoint: amtdv1beta1.HTTPEndpoint{URL: server.URL},
+	}})
+	if err := dispatcher.Deliver(context.Background(), sink, testNotification); err == nil {
+		t.Errorf("expected an error for a rejected notification")
+	}
+	if len(stub.requests) != 1 {
+		t.Errorf("expected 1 attempt, got %d", len(stub.requests))
+	}
+}
+
+func TestSlackURLFromSecret(t *testing.T) {
+	stub := &receiver{}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "slack", Namespace: "shop"},
+		Data:       map[string][]byte{"url": []byte(server.URL + "\n")},
+	}
+	// the Secret is only visible to the uncached reader
+	dispatcher := NewDispatcher(newFakeClient(t), newFakeClient(t, secret))
+	sink := newSink(amtdv1beta1.NotificationSinkSpec{Slack: &amtdv1beta1.SlackSink{
+		HTTPEndpoint: amtdv1beta1.HTTPEndpoint{URLSecretRef: &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: "slack"},
+			Key:                  "url",
+		}},
+		Channel: "#security",
+	}})
+	failed := testNotification
+	failed.Outcome = amtdv1beta1.ActionFailed
+	failed.Error = "pods \"checkout-5f8d7c9b4-l9qzt\" not found"
+	if err := dispatcher.Deliver(context.Background(), sink, failed); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var message slackMessage
+	if err := json.Unmarshal(stub.bodies[0], &message); err != nil {
+		t.Fatal(err)
+	}
+	if message.Channel != "#security" || !strings.Contains(message.Text, "failed") {
+		t.Errorf("unexpected message %+v", message)
+	}
+	if len(message.Attachments) != 1 || message.Attachments[0].Color != "danger" {
+		t.Errorf("unexpected attachments %+v", message.Attachments)
+	}
+}
+
+func TestCloudEvents(t *testing.T) {
+	stub := &receiver{failures: 1, status: http.StatusTooManyRequests}
+	server := httptest.NewServer(stub)
+	defer server.Close()
+
+	dispatcher := NewDispatcher(newFakeClient(t), newFakeClient(t))
+	sink := newSink(amtdv1beta1.NotificationSinkSpec{CloudEvents: &amtdv1beta1.CloudEventsSink{
+		HTTPEndpoint: amtdv1beta1.HTTPEndpoint{URL: server.URL},
+		Source:       "phoenix/test",
+	}})
+	if err := dispatcher.Deliver(context.Background(), sink, testNotification); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got := stub.requests[1].Header.Get("Content-Type"); got != "application/cloudevents+json" {
+		t.Errorf("unexpected Content-Type %q", got)
+	}
+	var first, second cloudEvent
+	json.Unmarshal(stub.bodies[0], &first)
+	json.Unmarshal(stub.bodies[1], &second)
+	if second.Type != "com.r6security.phoenix.action.succeeded" || second.Source != "phoenix/test" || second.Subject != testNotification.Target {
+		t.Errorf("unexpected CloudEvent %+v", second)
+	}
+	if first.ID == "" || first.ID != second.ID {
+		t.Errorf("expected the same id for redeliveries, got %q and %q", first.ID, second.ID)
+	}
+}
+
+// serveSMTP is a minimal stand-in SMTP server that accepts one message and returns it on the channel
+func serveSMTP(listener net.Listener, messages chan<- string) {
+	conn, err := listener.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+	reader := bufio.NewReader(conn)
+	reply := func(line string) { conn.Write([]byte(line + "\r\n")) }
+
+	reply("220 localhost ESMTP")
+	var data strings.Builder
+	inData := false
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+		if inData {
+			if line == ".\r\n" {
+				inData = false
+				messages <- data.String()
+				reply("250 OK")
+				continue
+			}
+			data.WriteString(line)
+			continue
+		}
+		switch command := strings.ToUpper(strings.TrimSpace(line)); {
+		case strings.HasPrefix(command, "EHLO"), strings.HasPrefix(command, "HELO"):
+			reply("250 localhost")
+		case command == "DATA":
+			inData = true
+			reply("354 End data with <CR><LF>.<CR><LF>")
+		case command == "QUIT":
+			reply("221 Bye")
+			return
+		default:
+			reply("250 OK")
+		}
+	}
+}
+
+func TestSMTP(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer listener.Close()
+	messages := make(chan string, 1)
+	go serveSMTP(listener, messages)
+
+	host, port, _ := net.SplitHostPort(listener.Addr().String())
+	portNumber, _ := strconv.Atoi(port)
+	dispatcher := NewDispatcher(newFakeClient(t), newFakeClient(t))
+	sink := newSink(amtdv1beta1.NotificationSinkSpec{SMTP: &amtdv1beta1.SMTPSink{
+		Host: host,
+		Port: int32(portNumber),
+		From: "phoenix@example.com",
+		To:   []string{"soc@example.com"},
+	}})
+	if err := dispatcher.Deliver(context.Background(), sink, testNotification); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	message := <-messages
+	if !strings.Contains(message, "Subject: [Phoenix] quarantine succeeded on shop/checkout-5f8d7c9b4-l9qzt") {
+		t.Errorf("unexpected message:\n%s", message)
+	}
+	if !strings.Contains(message, `"securityEvent": "falco-x7k2p"`) {
+		t.Errorf("the payload is missing from the message:\n%s", message)
+	}
+}
+
+func TestNotifiesSelectedOutcomes(t *testing.T) {
+	sink := newSink(amtdv1beta1.NotificationSinkSpec{Outcomes: []amtdv1beta1.ActionOutcome{amtdv1beta1.ActionFailed}})
+	if notifies(sink, amtdv1beta1.ActionSucceeded) {
+		t.Errorf("the sink only wants failures")
+	}
+	if !notifies(sink, amtdv1beta1.ActionFailed) {
+		t.Errorf("the sink wants failures")
+	}
+}
+
+func TestNotifyBoundsDeliveries(t *testing.T) {
+	arrived, release := make(chan struct{}, 2), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	sink := newSink(amtdv1beta1.NotificationSinkSpec{Webhook: &amtdv1beta1.WebhookSink{
+		HTTPEndpoint: amtdv1beta1.HTTPEndpoint{URL: server.URL},
+	}})
+	AMTD := &amtdv1beta1.AdaptiveMovingTargetDefense{
+		ObjectMeta: metav1.ObjectMeta{Name: "checkout", Namespace: "shop"},
+		Spec:       amtdv1beta1.AdaptiveMovingTargetDefenseSpec{NotificationSinks: []corev1.LocalObjectReference{{Name: sink.Name}}},
+	}
+	dispatcher := NewDispatcher(newFakeClient(t, sink), newFakeClient(t))
+	dispatcher.deliveries = make(chan struct{}, 1)
+
+	// the second notification is dropped while the first one is stuck
+	dispatcher.Notify(context.Background(), AMTD, testNotification)
+	dispatcher.Notify(context.Background(), AMTD, testNotification)
+	<-arrived
+	select {
+	case <-arrived:
+		t.Errorf("expected the second notification to be dropped")
+	case <-time.After(100 * time.Millisecond):
+	}
+	if len(dispatcher.deliveries) != 1 {
+		t.Errorf("expected 1 delivery in progress, got %d", len(dispatcher.deliveries))
+	}
+}
@@ -0,0 +1,67 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package notification
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"strings"
+	"time"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// smtpSender sends the notification in a plain text e-mail with the JSON payload attached inline
+func smtpSender(sink *amtdv1beta1.SMTPSink, password string) sender {
+	port := sink.Port
+	if port == 0 {
+		port = 587
+	}
+	addr := net.JoinHostPort(sink.Host, strconv.Itoa(int(port)))
+
+	var auth smtp.Auth
+	if sink.Username != "" {
+		auth = smtp.PlainAuth("", sink.Username, password, sink.Host)
+	}
+
+	return func(ctx context.Context, notification Notification) error {
+		payload, err := json.MarshalIndent(notification, "", "  ")
+		if err != nil {
+			return permanentError{err}
+		}
+
+		var message bytes.Buffer
+		fmt.Fprintf(&message, "From: %s\r\n", sink.From)
+		fmt.Fprintf(&message, "To: %s\r\n", strings.Join(sink.To, ", "))
+		fmt.Fprintf(&message, "Subject: [Phoenix] %s %s on %s\r\n", notification.Action, strings.ToLower(string(notification.Outcome)), notification.Target)
+		fmt.Fprintf(&message, "Date: %s\r\n", notification.Time.Format(time.RFC1123Z))
+		fmt.Fprintf(&message, "MIME-Version: 1.0\r\n")
+		fmt.Fprintf(&message, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+		fmt.Fprintf(&message, "%s.\r\n\r\n", notification.Summary())
+		message.WriteString(strings.ReplaceAll(string(payload), "\n", "\r\n"))
+		message.WriteString("\r\n")
+
+		// net/smtp does not support contexts, the Dispatcher timeout bounds the retries only
+		return smtp.SendMail(addr, auth, sink.From, sink.To, message.Bytes())
+	}
+}
@@ -0,0 +1,299 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"regexp"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+	"github.com/r6security/phoenix/pkg/rules"
+	"github.com/r6security/phoenix/pkg/schedule"
+)
+
+// log is for logging in this package.
+var adaptivemovingtargetdefenselog = logf.Log.WithName("adaptivemovingtargetdefense-resource")
+
+// SetupAdaptiveMovingTargetDefenseWebhookWithManager registers the webhook for AdaptiveMovingTargetDefense in the manager.
+// The resolution mode is the one of the SecurityEvent controller, rule collisions depend on it.
+func SetupAdaptiveMovingTargetDefenseWebhookWithManager(mgr ctrl.Manager, resolution rules.ResolutionMode) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&amtdv1beta1.AdaptiveMovingTargetDefense{}).
+		WithValidator(&AdaptiveMovingTargetDefenseCustomValidator{Client: mgr.GetClient(), Resolution: resolution}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-amtd-r6security-com-v1beta1-adaptivemovingtargetdefense,mutating=false,failurePolicy=fail,sideEffects=None,groups=amtd.r6security.com,resources=adaptivemovingtargetdefenses,verbs=create;update,versions=v1beta1,name=vadaptivemovingtargetdefense-v1beta1.kb.io,admissionReviewVersions=v1
+
+// AdaptiveMovingTargetDefenseCustomValidator rejects AMTDs that the controller could not apply:
+// empty pod selectors, invalid rule patterns, actions that cannot be executed and rules that
+// collide with other AMTDs selecting the same pods.
+type AdaptiveMovingTargetDefenseCustomValidator struct {
+	// Client looks up the other AMTDs and the pods in the namespace of the validated AMTD
+	Client client.Reader
+
+	// Resolution is the strategy resolution mode that decides which overlapping rules collide
+	Resolution rules.ResolutionMode
+}
+
+var _ admission.CustomValidator = &AdaptiveMovingTargetDefenseCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type AdaptiveMovingTargetDefense.
+func (v *AdaptiveMovingTargetDefenseCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	AMTD, ok := obj.(*amtdv1beta1.AdaptiveMovingTargetDefense)
+	if !ok {
+		return nil, fmt.Errorf("expected an AdaptiveMovingTargetDefense object but got %T", obj)
+	}
+	adaptivemovingtargetdefenselog.Info("Validation for AdaptiveMovingTargetDefense upon creation", "name", AMTD.GetName())
+
+	return nil, v.validate(ctx, AMTD)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type AdaptiveMovingTargetDefense.
+func (v *AdaptiveMovingTargetDefenseCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	AMTD, ok := newObj.(*amtdv1beta1.AdaptiveMovingTargetDefense)
+	if !ok {
+		return nil, fmt.Errorf("expected an AdaptiveMovingTargetDefense object for the newObj but got %T", newObj)
+	}
+	adaptivemovingtargetdefenselog.Info("Validation for AdaptiveMovingTargetDefense upon update", "name", AMTD.GetName())
+
+	// Deleting an AMTD with a finalizer updates its metadata only, that must not be blocked
+	if !AMTD.DeletionTimestamp.IsZero() {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, AMTD)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type AdaptiveMovingTargetDefense.
+func (v *AdaptiveMovingTargetDefenseCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate collects every problem of the AMTD into a single Invalid error
+func (v *AdaptiveMovingTargetDefenseCustomValidator) validate(ctx context.Context, AMTD *amtdv1beta1.AdaptiveMovingTargetDefense) error {
+	spec := field.NewPath("spec")
+
+	var allErrs field.ErrorList
+	if len(AMTD.Spec.PodSelector) == 0 {
+		allErrs = append(allErrs, field.Required(spec.Child("podSelector"), "must select pods by at least one label, an empty selector would manage every pod of the namespace"))
+	}
+	for i, strategy := range AMTD.Spec.Strategy {
+		path := spec.Child("strategy").Index(i)
+		if err := rules.Validate(strategy.Rule); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("rule"), strategy.Rule, err.Error()))
+		}
+		allErrs = append(allErrs, validateAction(path.Child("action"), strategy.Action)...)
+	}
+	if AMTD.Spec.DefaultAction != nil {
+		allErrs = append(allErrs, validateAction(spec.Child("defaultAction"), *AMTD.Spec.DefaultAction)...)
+	}
+	if AMTD.Spec.Rotation != nil {
+		allErrs = append(allErrs, validateRotation(spec.Child("rotation"), AMTD.Spec.Rotation)...)
+	}
+	if AMTD.Spec.Disruption != nil && AMTD.Spec.Disruption.MinAvailable != nil {
+		minAvailable := AMTD.Spec.Disruption.MinAvailable
+		if value, err := intstr.GetScaledValueFromIntOrPercent(minAvailable, 100, true); err != nil || value < 0 {
+			allErrs = append(allErrs, field.Invalid(spec.Child("disruption", "minAvailable"), minAvailable.String(), "must be a non-negative integer or a percentage"))
+		}
+	}
+	if len(allErrs) == 0 {
+		// The collision check relies on a valid selector and valid rules
+		collisions, err := v.collisions(ctx, AMTD)
+		if err != nil {
+			return apierrors.NewInternalError(err)
+		}
+		for _, other := range collisions {
+			allErrs = append(allErrs, field.Forbidden(spec.Child("strategy"), fmt.Sprintf(`rules collide with AdaptiveMovingTargetDefense "%s" that selects the same pods, change the rules or the priority of one of them`, other)))
+		}
+	}
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(amtdv1beta1.GroupVersion.WithKind("AdaptiveMovingTargetDefense").GroupKind(), AMTD.Name, allErrs)
+}
+
+// collisions returns the names of the other AMTDs of the namespace whose rules collide with the
+// rules of AMTD and that select some of the same pods. Selectors overlap when one of them is a
+// subset of the other, so every pod the stricter one selects in the future is selected by both,
+// or when an existing pod matches both of them.
+func (v *AdaptiveMovingTargetDefenseCustomValidator) collisions(ctx context.Context, AMTD *amtdv1beta1.AdaptiveMovingTargetDefense) ([]string, error) {
+	AMTDList := &amtdv1beta1.AdaptiveMovingTargetDefenseList{}
+	if err := v.Client.List(ctx, AMTDList, client.InNamespace(AMTD.Namespace)); err != nil {
+		return nil, err
+	}
+
+	var pods *corev1.PodList
+	collisions := []string{}
+	for i := range AMTDList.Items {
+		AMTDOther := &AMTDList.Items[i]
+		if AMTDOther.Name == AMTD.Name || !AMTDOther.DeletionTimestamp.IsZero() || !rules.Collide(v.Resolution, AMTD, AMTDOther) {
+			continue
+		}
+		selector, selectorOther := labels.Set(AMTD.Spec.PodSelector), labels.Set(AMTDOther.Spec.PodSelector)
+		if selector.AsSelector().Matches(selectorOther) || selectorOther.AsSelector().Matches(selector) {
+			collisions = append(collisions, AMTDOther.Name)
+			continue
+		}
+
+		if pods == nil {
+			pods = &corev1.PodList{}
+			if err := v.Client.List(ctx, pods, client.InNamespace(AMTD.Namespace), client.MatchingLabels(AMTD.Spec.PodSelector)); err != nil {
+				return nil, err
+			}
+		}
+		for _, pod := range pods.Items {
+			if selectorOther.AsSelector().Matches(labels.Set(pod.Labels)) {
+				collisions = append(collisions, AMTDOther.Name)
+				break
+			}
+		}
+	}
+	return collisions, nil
+}
+
+// validateRotation checks the schedule and the durations of the periodic rotation
+func validateRotation(path *field.Path, rotation *amtdv1beta1.Rotation) field.ErrorList {
+	var allErrs field.ErrorList
+	if rotation.Schedule != "" {
+		if _, err := schedule.ParseCron(rotation.Schedule); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("schedule"), rotation.Schedule, err.Error()))
+		}
+	}
+	if rotation.Interval != nil && rotation.Interval.Duration <= 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("interval"), rotation.Interval.Duration.String(), "must be positive"))
+	}
+	if rotation.Jitter != nil && rotation.Jitter.Duration < 0 {
+		allErrs = append(allErrs, field.Invalid(path.Child("jitter"), rotation.Jitter.Duration.String(), "must not be negative"))
+	}
+	return allErrs
+}
+
+// validateAction checks the fields of the built-in actions that the API server would only reject
+// when the action is executed
+func validateAction(path *field.Path, action amtdv1beta1.AMTDAction) field.ErrorList {
+	var allErrs field.ErrorList
+	if action.Debugger != nil {
+		allErrs = append(allErrs, validateImage(path.Child("debugger", "image"), action.Debugger.Image)...)
+	}
+	if action.CustomAction != nil {
+		allErrs = append(allErrs, validateCustomAction(path.Child("customAction"), action.CustomAction)...)
+	}
+	if action.Quarantine != nil {
+		allErrs = append(allErrs, validateQuarantine(path.Child("quarantine"), action.Quarantine)...)
+	}
+	if action.Plugin != nil && actions.IsBuiltin(action.Plugin.Name) {
+		allErrs = append(allErrs, field.Invalid(path.Child("plugin", "name"), action.Plugin.Name, "is reserved for a built-in action, use the action field of the same name instead"))
+	}
+	if action.Delete != nil {
+		if timeout := action.Delete.ReplacementTimeout; timeout != nil && timeout.Duration <= 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("delete", "replacementTimeout"), timeout.Duration.String(), "must be positive"))
+		}
+		if forceAfter := action.Delete.ForceAfter; forceAfter != nil && forceAfter.Duration < 0 {
+			allErrs = append(allErrs, field.Invalid(path.Child("delete", "forceAfter"), forceAfter.Duration.String(), "must not be negative"))
+		}
+	}
+	return allErrs
+}
+
+// validateQuarantine checks the allow-lists of the quarantine NetworkPolicy
+func validateQuarantine(path *field.Path, quarantine *amtdv1beta1.QuarantineAction) field.ErrorList {
+	var allErrs field.ErrorList
+	if namespace := quarantine.ForensicsNamespace; namespace != "" {
+		for _, msg := range validation.IsDNS1123Label(namespace) {
+			allErrs = append(allErrs, field.Invalid(path.Child("forensicsNamespace"), namespace, msg))
+		}
+	}
+	if name := quarantine.TemplateNetworkPolicy; name != "" {
+		for _, msg := range validation.IsDNS1123Subdomain(name) {
+			allErrs = append(allErrs, field.Invalid(path.Child("templateNetworkPolicy"), name, msg))
+		}
+	}
+	for i, endpoint := range quarantine.LoggingEndpoints {
+		if _, _, err := net.ParseCIDR(endpoint.CIDR); err != nil {
+			allErrs = append(allErrs, field.Invalid(path.Child("loggingEndpoints").Index(i).Child("cidr"), endpoint.CIDR, "must be a CIDR, e.g. 10.0.12.7/32"))
+		}
+	}
+	return allErrs
+}
+
+// validateCustomAction rejects the fields of the container that are not allowed for ephemeral containers
+func validateCustomAction(path *field.Path, customAction *amtdv1beta1.CustomAction) field.ErrorList {
+	allErrs := validateImage(path.Child("image"), customAction.Image)
+	if len(customAction.Ports) > 0 {
+		allErrs = append(allErrs, field.Forbidden(path.Child("ports"), "cannot be set for ephemeral containers"))
+	}
+	if len(customAction.Resources.Limits) > 0 || len(customAction.Resources.Requests) > 0 || len(customAction.Resources.Claims) > 0 {
+		allErrs = append(allErrs, field.Forbidden(path.Child("resources"), "cannot be set for ephemeral containers, they use the resources already allocated to the pod"))
+	}
+	if len(customAction.ResizePolicy) > 0 {
+		allErrs = append(allErrs, field.Forbidden(path.Child("resizePolicy"), "cannot be set for ephemeral containers"))
+	}
+	if customAction.RestartPolicy != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("restartPolicy"), "cannot be set for ephemeral containers"))
+	}
+	if customAction.LivenessProbe != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("livenessProbe"), "cannot be set for ephemeral containers"))
+	}
+	if customAction.ReadinessProbe != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("readinessProbe"), "cannot be set for ephemeral containers"))
+	}
+	if customAction.StartupProbe != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("startupProbe"), "cannot be set for ephemeral containers"))
+	}
+	if customAction.Lifecycle != nil {
+		allErrs = append(allErrs, field.Forbidden(path.Child("lifecycle"), "cannot be set for ephemeral containers"))
+	}
+	return allErrs
+}
+
+// imageReference matches the container image references of the form [registry[:port]/]path[:tag][@digest]
+var imageReference = regexp.MustCompile(`^` +
+	// optional registry
+	`(?:(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9])(?:\.(?:[a-zA-Z0-9]|[a-zA-Z0-9][a-zA-Z0-9-]*[a-zA-Z0-9]))*(?::[0-9]+)?/)?` +
+	// path components
+	`[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*(?:/[a-z0-9]+(?:(?:[._]|__|-+)[a-z0-9]+)*)*` +
+	// optional tag
+	`(?::[\w][\w.-]{0,127})?` +
+	// optional digest
+	`(?:@[A-Za-z][A-Za-z0-9]*(?:[-_+.][A-Za-z][A-Za-z0-9]*)*:[0-9a-fA-F]{32,})?` +
+	`$`)
+
+// validateImage checks that image is set and is a valid container image reference
+func validateImage(path *field.Path, image string) field.ErrorList {
+	if image == "" {
+		return field.ErrorList{field.Required(path, "")}
+	}
+	if len(image) > 255 || !imageReference.MatchString(image) {
+		return field.ErrorList{field.Invalid(path, image, "must be a valid container image reference, e.g. busybox:1.36 or ghcr.io/org/debugger@sha256:...")}
+	}
+	return nil
+}
@@ -0,0 +1,278 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/rules"
+)
+
+func newFakeClient(t *testing.T, objects ...client.Object) client.Client {
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := amtdv1beta1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	return fake.NewClientBuilder().WithScheme(scheme).WithObjects(objects...).Build()
+}
+
+func newAMTD(name string, podSelector map[string]string, strategy ...amtdv1beta1.ResponseStrategy) *amtdv1beta1.AdaptiveMovingTargetDefense {
+	return &amtdv1beta1.AdaptiveMovingTargetDefense{
+		ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: name},
+		Spec: amtdv1beta1.AdaptiveMovingTargetDefenseSpec{
+			PodSelector: podSelector,
+			Strategy:    strategy,
+		},
+	}
+}
+
+func deleteOn(ruleType string) amtdv1beta1.ResponseStrategy {
+	return amtdv1beta1.ResponseStrategy{
+		Rule:   amtdv1beta1.Rule{Type: ruleType},
+		Action: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{}},
+	}
+}
+
+func quarantineOn(ruleType string) amtdv1beta1.ResponseStrategy {
+	return amtdv1beta1.ResponseStrategy{
+		Rule:   amtdv1beta1.Rule{Type: ruleType},
+		Action: amtdv1beta1.AMTDAction{Quarantine: &amtdv1beta1.QuarantineAction{}},
+	}
+}
+
+func TestValidateSpec(t *testing.T) {
+	validator := &AdaptiveMovingTargetDefenseCustomValidator{Client: newFakeClient(t)}
+
+	tests := []struct {
+		name   string
+		AMTD   *amtdv1beta1.AdaptiveMovingTargetDefense
+		errors []string
+	}{
+		{
+			name: "valid",
+			AMTD: newAMTD("valid", map[string]string{"app": "demo"}, deleteOn("test"), amtdv1beta1.ResponseStrategy{
+				Rule:   amtdv1beta1.Rule{Type: "shell"},
+				Action: amtdv1beta1.AMTDAction{Debugger: &amtdv1beta1.Debugger{Image: "ghcr.io/r6security/debugger:1.0"}},
+			}),
+		},
+		{
+			name:   "empty selector",
+			AMTD:   newAMTD("empty", map[string]string{}, deleteOn("test")),
+			errors: []string{"spec.podSelector: Required value"},
+		},
+		{
+			name: "invalid rule",
+			AMTD: newAMTD("rule", map[string]string{"app": "demo"}, amtdv1beta1.ResponseStrategy{
+				Rule:   amtdv1beta1.Rule{Type: "regex:("},
+				Action: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{}},
+			}),
+			errors: []string{"spec.strategy[0].rule: Invalid value"},
+		},
+		{
+			name: "invalid debugger image",
+			AMTD: newAMTD("debugger", map[string]string{"app": "demo"}, amtdv1beta1.ResponseStrategy{
+				Rule:   amtdv1beta1.Rule{Type: "shell"},
+				Action: amtdv1beta1.AMTDAction{Debugger: &amtdv1beta1.Debugger{Image: "Busybox:latest tag"}},
+			}),
+			errors: []string{"spec.strategy[0].action.debugger.image: Invalid value"},
+		},
+		{
+			name: "invalid rotation schedule",
+			AMTD: func() *amtdv1beta1.AdaptiveMovingTargetDefense {
+				AMTD := newAMTD("rotation", map[string]string{"app": "demo"}, deleteOn("test"))
+				AMTD.Spec.Rotation = &amtdv1beta1.Rotation{Schedule: "0 25 * * *"}
+				return AMTD
+			}(),
+			errors: []string{"spec.rotation.schedule: Invalid value"},
+		},
+		{
+			name: "invalid delete options",
+			AMTD: newAMTD("delete", map[string]string{"app": "demo"}, amtdv1beta1.ResponseStrategy{
+				Rule:   amtdv1beta1.Rule{Type: "shell"},
+				Action: amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{ReplacementTimeout: &metav1.Duration{}}},
+			}),
+			errors: []string{"spec.strategy[0].action.delete.replacementTimeout: Invalid value"},
+		},
+		{
+			name: "invalid quarantine allow-lists",
+			AMTD: newAMTD("quarantine", map[string]string{"app": "demo"}, amtdv1beta1.ResponseStrategy{
+				Rule: amtdv1beta1.Rule{Type: "shell"},
+				Action: amtdv1beta1.AMTDAction{Quarantine: &amtdv1beta1.QuarantineAction{
+					ForensicsNamespace: "Forensics",
+					LoggingEndpoints:   []amtdv1beta1.NetworkEndpoint{{CIDR: "10.0.12.7"}},
+				}},
+			}),
+			errors: []string{
+				"spec.strategy[0].action.quarantine.forensicsNamespace: Invalid value",
+				"spec.strategy[0].action.quarantine.loggingEndpoints[0].cidr: Invalid value",
+			},
+		},
+		{
+			name: "plugin named after a built-in action",
+			AMTD: newAMTD("plugin", map[string]string{"app": "demo"}, amtdv1beta1.ResponseStrategy{
+				Rule:   amtdv1beta1.Rule{Type: "shell"},
+				Action: amtdv1beta1.AMTDAction{Plugin: &amtdv1beta1.PluginAction{Name: "delete"}},
+			}),
+			errors: []string{"spec.strategy[0].action.plugin.name: Invalid value"},
+		},
+		{
+			name: "invalid minAvailable",
+			AMTD: func() *amtdv1beta1.AdaptiveMovingTargetDefense {
+				AMTD := newAMTD("disruption", map[string]string{"app": "demo"}, deleteOn("test"))
+				minAvailable := intstr.FromString("half")
+				AMTD.Spec.Disruption = &amtdv1beta1.DisruptionLimits{MinAvailable: &minAvailable}
+				return AMTD
+			}(),
+			errors: []string{"spec.disruption.minAvailable: Invalid value"},
+		},
+		{
+			name: "custom action without image",
+			AMTD: newAMTD("custom", map[string]string{"app": "demo"}, amtdv1beta1.ResponseStrategy{
+				Rule:   amtdv1beta1.Rule{Type: "shell"},
+				Action: amtdv1beta1.AMTDAction{CustomAction: &amtdv1beta1.CustomAction{Name: "custom"}},
+			}),
+			errors: []string{"spec.strategy[0].action.customAction.image: Required value"},
+		},
+		{
+			name: "custom action with forbidden fields",
+			AMTD: newAMTD("custom", map[string]string{"app": "demo"}, amtdv1beta1.ResponseStrategy{
+				Rule: amtdv1beta1.Rule{Type: "shell"},
+				Action: amtdv1beta1.AMTDAction{CustomAction: &amtdv1beta1.CustomAction{
+					Name:          "custom",
+					Image:         "busybox",
+					Ports:         []amtdv1beta1.CustomContainerPort{{ContainerPort: 8080}},
+					Resources:     corev1.ResourceRequirements{Limits: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("100m")}},
+					LivenessProbe: &corev1.Probe{},
+					Lifecycle:     &corev1.Lifecycle{},
+				}},
+			}),
+			errors: []string{
+				"spec.strategy[0].action.customAction.ports: Forbidden",
+				"spec.strategy[0].action.customAction.resources: Forbidden",
+				"spec.strategy[0].action.customAction.livenessProbe: Forbidden",
+				"spec.strategy[0].action.customAction.lifecycle: Forbidden",
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validator.ValidateCreate(context.Background(), tt.AMTD)
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.errors {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err.Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateCollisions(t *testing.T) {
+	pod := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace: "default",
+		Name:      "demo",
+		Labels:    map[string]string{"app": "demo", "tier": "frontend"},
+	}}
+	existing := newAMTD("existing", map[string]string{"app": "demo"}, quarantineOn("test"))
+	validator := &AdaptiveMovingTargetDefenseCustomValidator{Client: newFakeClient(t, pod, existing), Resolution: rules.HighestPriority}
+
+	tests := []struct {
+		name    string
+		AMTD    *amtdv1beta1.AdaptiveMovingTargetDefense
+		collide bool
+	}{
+		{"subset selector", newAMTD("subset", map[string]string{"app": "demo", "tier": "backend"}, deleteOn("test")), true},
+		{"overlapping pod", newAMTD("overlap", map[string]string{"tier": "frontend"}, deleteOn("test")), true},
+		{"disjoint pods", newAMTD("disjoint", map[string]string{"tier": "backend"}, deleteOn("test")), false},
+		{"different rules", newAMTD("rules", map[string]string{"app": "demo"}, deleteOn("other")), false},
+		{"overlapping glob", newAMTD("glob", map[string]string{"app": "demo"}, deleteOn("te*")), true},
+		{"same action", newAMTD("action", map[string]string{"app": "demo"}, quarantineOn("test")), false},
+		{"self", newAMTD("existing", map[string]string{"app": "demo"}, deleteOn("test")), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validator.ValidateUpdate(context.Background(), existing, tt.AMTD)
+			if tt.collide != (err != nil) {
+				t.Fatalf("expected collision %v, got error %v", tt.collide, err)
+			}
+			if tt.collide && !strings.Contains(err.Error(), `"existing"`) {
+				t.Errorf("error %q does not name the colliding AdaptiveMovingTargetDefense", err.Error())
+			}
+		})
+	}
+}
+
+func TestValidateCollisionsDependOnResolution(t *testing.T) {
+	existing := newAMTD("existing", map[string]string{"app": "demo"}, quarantineOn("test"))
+	AMTD := newAMTD("other", map[string]string{"app": "demo"}, deleteOn("test"))
+	AMTD.Spec.Priority = 1
+
+	for resolution, collide := range map[rules.ResolutionMode]bool{
+		rules.FirstMatch:       true,
+		rules.HighestPriority:  false,
+		rules.MostSevereAction: false,
+		rules.RunAll:           false,
+	} {
+		validator := &AdaptiveMovingTargetDefenseCustomValidator{Client: newFakeClient(t, existing), Resolution: resolution}
+		if _, err := validator.ValidateCreate(context.Background(), AMTD); collide != (err != nil) {
+			t.Errorf("%s: expected collision %v, got error %v", resolution, collide, err)
+		}
+	}
+}
+
+func TestValidateImage(t *testing.T) {
+	for image, valid := range map[string]bool{
+		"busybox":                      true,
+		"busybox:1.36":                 true,
+		"docker.io/library/busybox":    true,
+		"localhost:5000/team/debugger": true,
+		"ghcr.io/org/debugger@sha256:" + strings.Repeat("a", 64): true,
+		"Busybox":       false,
+		"busybox:":      false,
+		"busybox:a b":   false,
+		"/busybox":      false,
+		"busybox@sha1:": false,
+	} {
+		if errs := validateImage(nil, image); (len(errs) == 0) != valid {
+			t.Errorf("image %q: expected valid %v, got %v", image, valid, errs)
+		}
+	}
+}
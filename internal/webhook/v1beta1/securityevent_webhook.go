@@ -0,0 +1,205 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package v1beta1
+
+import (
+	"context"
+	"fmt"
+	"reflect"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	logf "sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/internal/controller"
+)
+
+// log is for logging in this package.
+var securityeventlog = logf.Log.WithName("securityevent-resource")
+
+// Names of the rule fields that a SecurityEventPolicy can require, they match the json field names of Rule
+const (
+	RULE_FIELD_TYPE         string = "type"
+	RULE_FIELD_THREAT_LEVEL string = "threatLevel"
+	RULE_FIELD_SOURCE       string = "source"
+)
+
+// SecurityEventPolicy configures which SecurityEvents the webhook admits
+type SecurityEventPolicy struct {
+	// RequiredRuleFields lists the rule fields that must not be empty
+	RequiredRuleFields []string
+
+	// MaxTargets limits the number of targets of a SecurityEvent, 0 means no limit
+	MaxTargets int
+
+	// CheckTargets rejects SecurityEvents whose targets do not exist or are not AMTD managed.
+	// The targets are only checked on creation since the actions may delete the pods later.
+	CheckTargets bool
+}
+
+// DefaultSecurityEventPolicy requires the fields that every built-in integration fills in
+var DefaultSecurityEventPolicy = SecurityEventPolicy{
+	RequiredRuleFields: []string{RULE_FIELD_TYPE, RULE_FIELD_SOURCE},
+	MaxTargets:         100,
+}
+
+// ParseRuleFields parses a comma separated list of rule field names, e.g. "type,source"
+func ParseRuleFields(value string) ([]string, error) {
+	fields := []string{}
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		switch name {
+		case "":
+			continue
+		case RULE_FIELD_TYPE, RULE_FIELD_THREAT_LEVEL, RULE_FIELD_SOURCE:
+			fields = append(fields, name)
+		default:
+			return nil, fmt.Errorf(`unknown rule field "%s", use %s, %s or %s`, name, RULE_FIELD_TYPE, RULE_FIELD_THREAT_LEVEL, RULE_FIELD_SOURCE)
+		}
+	}
+	return fields, nil
+}
+
+// SetupSecurityEventWebhookWithManager registers the webhook for SecurityEvent in the manager.
+func SetupSecurityEventWebhookWithManager(mgr ctrl.Manager, policy SecurityEventPolicy) error {
+	return ctrl.NewWebhookManagedBy(mgr).For(&amtdv1beta1.SecurityEvent{}).
+		WithValidator(&SecurityEventCustomValidator{Client: mgr.GetClient(), Policy: policy}).
+		Complete()
+}
+
+// +kubebuilder:webhook:path=/validate-amtd-r6security-com-v1beta1-securityevent,mutating=false,failurePolicy=fail,sideEffects=None,groups=amtd.r6security.com,resources=securityevents,verbs=create;update,versions=v1beta1,name=vsecurityevent-v1beta1.kb.io,admissionReviewVersions=v1
+
+// SecurityEventCustomValidator rejects SecurityEvents whose targets are not in the form of
+// "namespace/name" or that do not satisfy the Policy
+type SecurityEventCustomValidator struct {
+	// Client looks up the target pods when the Policy checks them
+	Client client.Reader
+
+	Policy SecurityEventPolicy
+}
+
+var _ admission.CustomValidator = &SecurityEventCustomValidator{}
+
+// ValidateCreate implements webhook.CustomValidator so a webhook will be registered for the type SecurityEvent.
+func (v *SecurityEventCustomValidator) ValidateCreate(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	securityEvent, ok := obj.(*amtdv1beta1.SecurityEvent)
+	if !ok {
+		return nil, fmt.Errorf("expected a SecurityEvent object but got %T", obj)
+	}
+	securityeventlog.Info("Validation for SecurityEvent upon creation", "name", securityEvent.GetName())
+
+	return nil, v.validate(ctx, securityEvent, v.Policy.CheckTargets)
+}
+
+// ValidateUpdate implements webhook.CustomValidator so a webhook will be registered for the type SecurityEvent.
+func (v *SecurityEventCustomValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) (admission.Warnings, error) {
+	securityEvent, ok := newObj.(*amtdv1beta1.SecurityEvent)
+	if !ok {
+		return nil, fmt.Errorf("expected a SecurityEvent object for the newObj but got %T", newObj)
+	}
+	securityEventOld, ok := oldObj.(*amtdv1beta1.SecurityEvent)
+	if !ok {
+		return nil, fmt.Errorf("expected a SecurityEvent object for the oldObj but got %T", oldObj)
+	}
+	securityeventlog.Info("Validation for SecurityEvent upon update", "name", securityEvent.GetName())
+
+	// Labels, annotations and finalizers can always be changed, even if the policy became stricter since the creation
+	if reflect.DeepEqual(securityEvent.Spec, securityEventOld.Spec) {
+		return nil, nil
+	}
+	return nil, v.validate(ctx, securityEvent, false)
+}
+
+// ValidateDelete implements webhook.CustomValidator so a webhook will be registered for the type SecurityEvent.
+func (v *SecurityEventCustomValidator) ValidateDelete(ctx context.Context, obj runtime.Object) (admission.Warnings, error) {
+	return nil, nil
+}
+
+// validate collects every problem of the SecurityEvent into a single Invalid error
+func (v *SecurityEventCustomValidator) validate(ctx context.Context, securityEvent *amtdv1beta1.SecurityEvent, checkTargets bool) error {
+	spec := field.NewPath("spec")
+
+	var allErrs field.ErrorList
+	targetsPath := spec.Child("targets")
+	if len(securityEvent.Spec.Targets) == 0 {
+		allErrs = append(allErrs, field.Required(targetsPath, "must list at least one pod"))
+	}
+	if v.Policy.MaxTargets > 0 && len(securityEvent.Spec.Targets) > v.Policy.MaxTargets {
+		allErrs = append(allErrs, field.TooMany(targetsPath, len(securityEvent.Spec.Targets), v.Policy.MaxTargets))
+	}
+	seen := map[string]bool{}
+	for i, target := range securityEvent.Spec.Targets {
+		path := targetsPath.Index(i)
+		namespace, name, err := amtdv1beta1.ParseTarget(target)
+		if err != nil {
+			allErrs = append(allErrs, field.Invalid(path, target, err.Error()))
+			continue
+		}
+		if seen[target] {
+			allErrs = append(allErrs, field.Duplicate(path, target))
+			continue
+		}
+		seen[target] = true
+
+		if checkTargets {
+			if err := v.checkTarget(ctx, path, namespace, name); err != nil {
+				allErrs = append(allErrs, err)
+			}
+		}
+	}
+
+	rule := securityEvent.Spec.Rule
+	values := map[string]string{
+		RULE_FIELD_TYPE:         rule.Type,
+		RULE_FIELD_THREAT_LEVEL: rule.ThreatLevel,
+		RULE_FIELD_SOURCE:       rule.Source,
+	}
+	for _, name := range v.Policy.RequiredRuleFields {
+		if strings.TrimSpace(values[name]) == "" {
+			allErrs = append(allErrs, field.Required(spec.Child("rule", name), "required by the SecurityEvent policy of the operator"))
+		}
+	}
+
+	if len(allErrs) == 0 {
+		return nil
+	}
+	return apierrors.NewInvalid(amtdv1beta1.GroupVersion.WithKind("SecurityEvent").GroupKind(), securityEvent.Name, allErrs)
+}
+
+// checkTarget reports an error if the target pod does not exist or is not managed by any AMTD
+func (v *SecurityEventCustomValidator) checkTarget(ctx context.Context, path *field.Path, namespace string, name string) *field.Error {
+	pod := &corev1.Pod{}
+	if err := v.Client.Get(ctx, types.NamespacedName{Namespace: namespace, Name: name}, pod); err != nil {
+		if apierrors.IsNotFound(err) {
+			return field.NotFound(path, namespace+"/"+name)
+		}
+		return field.InternalError(path, err)
+	}
+	if _, found := pod.Annotations[controller.AMTD_MANAGED_BY]; !found {
+		return field.Invalid(path, namespace+"/"+name, "pod is not managed by any AdaptiveMovingTargetDefense")
+	}
+	return nil
+}
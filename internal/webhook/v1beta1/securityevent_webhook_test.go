@@ -0,0 +1,144 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package v1beta1
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/internal/controller"
+)
+
+func newSecurityEvent(rule amtdv1beta1.Rule, targets ...string) *amtdv1beta1.SecurityEvent {
+	return &amtdv1beta1.SecurityEvent{
+		ObjectMeta: metav1.ObjectMeta{Name: "event"},
+		Spec: amtdv1beta1.SecurityEventSpec{
+			Targets:     targets,
+			Rule:        rule,
+			Description: "test",
+		},
+	}
+}
+
+func TestValidateSecurityEvent(t *testing.T) {
+	managed := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{
+		Namespace:   "default",
+		Name:        "managed",
+		Annotations: map[string]string{controller.AMTD_MANAGED_BY: "[]"},
+	}}
+	unmanaged := &corev1.Pod{ObjectMeta: metav1.ObjectMeta{Namespace: "default", Name: "unmanaged"}}
+	validator := &SecurityEventCustomValidator{
+		Client: newFakeClient(t, managed, unmanaged),
+		Policy: SecurityEventPolicy{
+			RequiredRuleFields: []string{RULE_FIELD_TYPE, RULE_FIELD_SOURCE},
+			MaxTargets:         2,
+			CheckTargets:       true,
+		},
+	}
+	rule := amtdv1beta1.Rule{Type: "test", Source: "TimerBackend"}
+
+	tests := []struct {
+		name          string
+		securityEvent *amtdv1beta1.SecurityEvent
+		errors        []string
+	}{
+		{
+			name:          "valid",
+			securityEvent: newSecurityEvent(rule, "default/managed"),
+		},
+		{
+			name:          "invalid targets",
+			securityEvent: newSecurityEvent(rule, "/managed", "managed"),
+			errors:        []string{"spec.targets[0]: Invalid value", "spec.targets[1]: Invalid value"},
+		},
+		{
+			name:          "duplicate target",
+			securityEvent: newSecurityEvent(rule, "default/managed", "default/managed"),
+			errors:        []string{"spec.targets[1]: Duplicate value"},
+		},
+		{
+			name:          "too many targets",
+			securityEvent: newSecurityEvent(rule, "default/managed", "default/a", "default/b"),
+			errors:        []string{"spec.targets: Too many"},
+		},
+		{
+			name:          "missing and unmanaged targets",
+			securityEvent: newSecurityEvent(rule, "default/missing", "default/unmanaged"),
+			errors:        []string{"spec.targets[0]: Not found", "spec.targets[1]: Invalid value"},
+		},
+		{
+			name:          "missing rule field",
+			securityEvent: newSecurityEvent(amtdv1beta1.Rule{Type: "test", ThreatLevel: "high"}, "default/managed"),
+			errors:        []string{"spec.rule.source: Required value"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := validator.ValidateCreate(context.Background(), tt.securityEvent)
+			if len(tt.errors) == 0 {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			for _, want := range tt.errors {
+				if !strings.Contains(err.Error(), want) {
+					t.Errorf("error %q does not contain %q", err.Error(), want)
+				}
+			}
+		})
+	}
+}
+
+func TestValidateSecurityEventUpdateSkipsTargetCheck(t *testing.T) {
+	validator := &SecurityEventCustomValidator{
+		Client: newFakeClient(t),
+		Policy: SecurityEventPolicy{CheckTargets: true},
+	}
+	old := newSecurityEvent(amtdv1beta1.Rule{Type: "test"}, "default/deleted")
+	updated := old.DeepCopy()
+	updated.Labels = map[string]string{"reviewed": "true"}
+	if _, err := validator.ValidateUpdate(context.Background(), old, updated); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	updated.Spec.Description = "changed"
+	if _, err := validator.ValidateUpdate(context.Background(), old, updated); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+}
+
+func TestParseRuleFields(t *testing.T) {
+	fields, err := ParseRuleFields(" type, threatLevel ,,source")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(fields, ",") != "type,threatLevel,source" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if _, err := ParseRuleFields("type,severity"); err == nil {
+		t.Error("expected an error for an unknown field")
+	}
+}
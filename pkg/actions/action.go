@@ -0,0 +1,175 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+// Package actions contains the Action interface that every response executed by
+// Phoenix implements and the Registry that maps AMTDAction variants to them.
+package actions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// Names of the built-in actions, they match the json field names of AMTDAction
+const (
+	Disable      string = "disable"
+	Delete       string = "delete"
+	Quarantine   string = "quarantine"
+	Debugger     string = "debugger"
+	CustomAction string = "customAction"
+)
+
+// Builtins lists the names of the built-in actions, plugins cannot be registered under them
+var Builtins = []string{Disable, Delete, Quarantine, Debugger, CustomAction}
+
+// IsBuiltin reports whether name is the name of a built-in action
+func IsBuiltin(name string) bool {
+	return slices.Contains(Builtins, name)
+}
+
+// ErrNotRevertible is returned by Revert if the effect of an action cannot be undone
+var ErrNotRevertible = errors.New("action cannot be reverted")
+
+// DeferredError is returned by Execute when the action cannot be executed yet, e.g. because
+// it would exceed a disruption budget. The caller keeps the action queued and executes it
+// again after RetryAfter instead of reporting a failure.
+type DeferredError struct {
+	// Reason explains what the action waits for
+	Reason string
+
+	RetryAfter time.Duration
+}
+
+func (e *DeferredError) Error() string {
+	return "deferred: " + e.Reason
+}
+
+// IsDeferred reports whether err is or wraps a DeferredError
+func IsDeferred(err error) (*DeferredError, bool) {
+	var deferred *DeferredError
+	if errors.As(err, &deferred) {
+		return deferred, true
+	}
+	return nil, false
+}
+
+// InvalidSpecError is returned by Resolve and Validate when the action definition of a strategy
+// is invalid. Retrying does not help until the AMTD is fixed, so the caller fails the target
+// without retrying it. Other errors of Validate are retried.
+type InvalidSpecError struct {
+	Err error
+}
+
+func (e *InvalidSpecError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *InvalidSpecError) Unwrap() error {
+	return e.Err
+}
+
+// InvalidSpec returns an InvalidSpecError with the formatted message
+func InvalidSpec(format string, args ...any) error {
+	return &InvalidSpecError{Err: fmt.Errorf(format, args...)}
+}
+
+// IsInvalidSpec reports whether err is or wraps an InvalidSpecError
+func IsInvalidSpec(err error) bool {
+	var invalid *InvalidSpecError
+	return errors.As(err, &invalid)
+}
+
+// Target is the pod an action is executed on together with the resources that
+// selected the action for it
+type Target struct {
+	Pod *corev1.Pod
+
+	// AMTD that contains the strategy the action comes from
+	AMTD *amtdv1beta1.AdaptiveMovingTargetDefense
+
+	// SecurityEvent that triggered the action, nil if the action is not triggered by an event
+	SecurityEvent *amtdv1beta1.SecurityEvent
+
+	// Spec is the action as it is defined in the strategy of the AMTD
+	Spec amtdv1beta1.AMTDAction
+
+	// Issued is set by an action that is finished only when the pod is gone, once it issued the
+	// deletion of the pod. The caller records it, so that the disappearance of the pod is only
+	// reported as the result of the action if the action actually deleted it.
+	Issued bool
+
+	// Warnings are set by an action that succeeded but did not take full effect, e.g. a quarantine
+	// that could not take the pod out of its Services. The caller reports them.
+	Warnings []string
+}
+
+// Action is a response that Phoenix can execute on a pod
+type Action interface {
+	// Validate checks the action definition of a strategy before it is executed, an invalid
+	// definition is reported with an InvalidSpecError
+	Validate(spec amtdv1beta1.AMTDAction) error
+
+	// Execute applies the action on the target pod. A non-zero RequeueAfter in the
+	// result asks the caller to execute the action again later, e.g. when the action
+	// waits for another controller to finish its job.
+	Execute(ctx context.Context, target *Target) (ctrl.Result, error)
+
+	// Revert undoes the effect of Execute on the target pod or returns ErrNotRevertible
+	Revert(ctx context.Context, target *Target) error
+}
+
+// NameOf returns the name of the action defined in spec or an empty string if
+// spec does not define any action
+func NameOf(spec amtdv1beta1.AMTDAction) string {
+	switch {
+	case spec.Disable != nil:
+		return Disable
+	case spec.Delete != nil:
+		return Delete
+	case spec.Quarantine != nil:
+		return Quarantine
+	case spec.Debugger != nil:
+		return Debugger
+	case spec.CustomAction != nil:
+		return CustomAction
+	case spec.Plugin != nil:
+		return spec.Plugin.Name
+	}
+	return ""
+}
+
+// severities ranks the built-in actions from the least to the most destructive
+var severities = map[string]int{
+	Debugger:     1,
+	CustomAction: 1,
+	Disable:      2,
+	Quarantine:   3,
+	Delete:       4,
+}
+
+// Severity returns how destructive the named action is, plugin actions rank lowest
+func Severity(name string) int {
+	return severities[name]
+}
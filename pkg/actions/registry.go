@@ -0,0 +1,113 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package actions
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// Registry holds the actions that can be referred to from AMTD strategies
+type Registry struct {
+	mu      sync.RWMutex
+	actions map[string]Action
+}
+
+// NewRegistry returns an empty registry
+func NewRegistry() *Registry {
+	return &Registry{actions: map[string]Action{}}
+}
+
+// Register makes the plugin action available under name. Registering the same name twice or
+// the name of a built-in action is an error.
+func (r *Registry) Register(name string, action Action) error {
+	if IsBuiltin(name) {
+		return fmt.Errorf(`action name "%s" is reserved for the built-in action`, name)
+	}
+	return r.register(name, action)
+}
+
+// RegisterBuiltin makes the implementation of the named built-in action available
+func (r *Registry) RegisterBuiltin(name string, action Action) error {
+	if !IsBuiltin(name) {
+		return fmt.Errorf(`"%s" is not a built-in action`, name)
+	}
+	return r.register(name, action)
+}
+
+func (r *Registry) register(name string, action Action) error {
+	if name == "" {
+		return fmt.Errorf("action name must not be empty")
+	}
+	if action == nil {
+		return fmt.Errorf(`action "%s" must not be nil`, name)
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, found := r.actions[name]; found {
+		return fmt.Errorf(`action "%s" is already registered`, name)
+	}
+	r.actions[name] = action
+	return nil
+}
+
+// Get returns the action registered under name
+func (r *Registry) Get(name string) (Action, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	action, found := r.actions[name]
+	return action, found
+}
+
+// Resolve returns the name and the implementation of the action defined in spec. An action that
+// is not registered is not an InvalidSpecError, e.g. the plugin may be missing from an older replica.
+func (r *Registry) Resolve(spec amtdv1beta1.AMTDAction) (string, Action, error) {
+	name := NameOf(spec)
+	if name == "" {
+		return "", nil, InvalidSpec("no action is defined")
+	}
+	if spec.Plugin != nil && IsBuiltin(spec.Plugin.Name) {
+		// the plugin must not run the built-in action without its configuration
+		return spec.Plugin.Name, nil, InvalidSpec(`plugin name "%s" is reserved for the built-in action`, spec.Plugin.Name)
+	}
+
+	action, found := r.Get(name)
+	if !found {
+		return name, nil, fmt.Errorf(`action "%s" is not registered`, name)
+	}
+	return name, action, nil
+}
+
+// Names returns the sorted names of the registered actions
+func (r *Registry) Names() []string {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	names := make([]string, 0, len(r.actions))
+	for name := range r.actions {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -0,0 +1,86 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package actions
+
+import (
+	"context"
+	"testing"
+
+	ctrl "sigs.k8s.io/controller-runtime"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+type nopAction struct{}
+
+func (nopAction) Validate(spec amtdv1beta1.AMTDAction) error { return nil }
+func (nopAction) Execute(ctx context.Context, target *Target) (ctrl.Result, error) {
+	return ctrl.Result{}, nil
+}
+func (nopAction) Revert(ctx context.Context, target *Target) error { return ErrNotRevertible }
+
+func TestRegistryResolve(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.RegisterBuiltin(Delete, nopAction{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.Register("scale-down", nopAction{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.RegisterBuiltin(Delete, nopAction{}); err == nil {
+		t.Fatalf("registering %q twice should fail", Delete)
+	}
+
+	name, _, err := registry.Resolve(amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{}})
+	if err != nil || name != Delete {
+		t.Errorf("Resolve(delete) = %q, %v", name, err)
+	}
+
+	name, _, err = registry.Resolve(amtdv1beta1.AMTDAction{Plugin: &amtdv1beta1.PluginAction{Name: "scale-down"}})
+	if err != nil || name != "scale-down" {
+		t.Errorf("Resolve(plugin) = %q, %v", name, err)
+	}
+
+	if _, _, err = registry.Resolve(amtdv1beta1.AMTDAction{Quarantine: &amtdv1beta1.QuarantineAction{}}); err == nil {
+		t.Errorf("Resolve of an unregistered action should fail")
+	}
+
+	if _, _, err = registry.Resolve(amtdv1beta1.AMTDAction{}); err == nil {
+		t.Errorf("Resolve of an empty action should fail")
+	}
+}
+
+func TestRegistryReservesBuiltinNames(t *testing.T) {
+	registry := NewRegistry()
+	if err := registry.RegisterBuiltin(Delete, nopAction{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := registry.RegisterBuiltin("scale-down", nopAction{}); err == nil {
+		t.Errorf("registering a plugin as a built-in action should fail")
+	}
+	for _, name := range Builtins {
+		if err := registry.Register(name, nopAction{}); err == nil {
+			t.Errorf("registering a plugin named %q should fail", name)
+		}
+	}
+
+	// a plugin named after a built-in action must not run the built-in action
+	if _, action, err := registry.Resolve(amtdv1beta1.AMTDAction{Plugin: &amtdv1beta1.PluginAction{Name: Delete}}); err == nil || action != nil {
+		t.Errorf("Resolve(plugin %q) = %v, %v, expected an error", Delete, action, err)
+	}
+}
@@ -21,28 +21,47 @@ import (
     ctrl "sigs.k8s.io/controller-runtime"
 
     internalcontroller "github.com/r6security/phoenix/internal/controller"
+    webhookv1beta1 "github.com/r6security/phoenix/internal/webhook/v1beta1"
+    "github.com/r6security/phoenix/pkg/actions"
+    "github.com/r6security/phoenix/pkg/rules"
 )
 
+// NewActionRegistry returns a registry with the built-in Phoenix actions.
+// Custom actions can be added to it before passing it to RegisterCoreControllersWithActions,
+// then they can be referred to from AMTD strategies as plugin actions.
+func NewActionRegistry(mgr ctrl.Manager) *actions.Registry {
+    return internalcontroller.NewActionRegistry(mgr.GetClient(), mgr.GetAPIReader(), mgr.GetScheme())
+}
+
 // RegisterCoreControllers registers all core Phoenix controllers with the manager.
 // This wrapper keeps controller implementations internal while exposing a public entrypoint.
 func RegisterCoreControllers(mgr ctrl.Manager) error {
+    return RegisterCoreControllersWithActions(mgr, NewActionRegistry(mgr))
+}
+
+// RegisterCoreControllersWithActions registers all core Phoenix controllers with the manager
+// and executes the actions of the given registry in response to SecurityEvents.
+func RegisterCoreControllersWithActions(mgr ctrl.Manager, registry *actions.Registry) error {
     if err := (&internalcontroller.AdaptiveMovingTargetDefenseReconciler{
-        Client: mgr.GetClient(),
-        Scheme: mgr.GetScheme(),
+        Client:  mgr.GetClient(),
+        Scheme:  mgr.GetScheme(),
+        Actions: registry,
     }).SetupWithManager(mgr); err != nil {
         return err
     }
 
     if err := (&internalcontroller.PodReconciler{
-        Client: mgr.GetClient(),
-        Scheme: mgr.GetScheme(),
+        Client:  mgr.GetClient(),
+        Scheme:  mgr.GetScheme(),
+        Actions: registry,
     }).SetupWithManager(mgr); err != nil {
         return err
     }
 
     if err := (&internalcontroller.SecurityEventReconciler{
-        Client: mgr.GetClient(),
-        Scheme: mgr.GetScheme(),
+        Client:  mgr.GetClient(),
+        Scheme:  mgr.GetScheme(),
+        Actions: registry,
     }).SetupWithManager(mgr); err != nil {
         return err
     }
@@ -68,4 +87,12 @@ func RegisterAMTDAndPodControllers(mgr ctrl.Manager) error {
     return nil
 }
 
-
+// RegisterWebhooks registers the validating admission webhooks of the Phoenix resources
+// with the webhook server of the manager, SecurityEvents are validated with the default policy
+// and rule collisions are checked for the default resolution mode.
+func RegisterWebhooks(mgr ctrl.Manager) error {
+    if err := webhookv1beta1.SetupAdaptiveMovingTargetDefenseWebhookWithManager(mgr, rules.HighestPriority); err != nil {
+        return err
+    }
+    return webhookv1beta1.SetupSecurityEventWebhookWithManager(mgr, webhookv1beta1.DefaultSecurityEventPolicy)
+}
@@ -0,0 +1,59 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package rules
+
+import (
+	"reflect"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+	"github.com/r6security/phoenix/pkg/actions"
+)
+
+// Collide reports whether the two AMTDs have overlapping rules that the resolution mode cannot
+// order, so that the executed action would depend on the order the AMTDs started managing the pod.
+// Strategies with the same action never collide, neither do strategies in the run-all mode where
+// every matching action is executed. Otherwise:
+//   - first-match orders the strategies of different AMTDs by nothing but that order
+//   - highest-priority orders them by the priority of the AMTDs and then of the strategies
+//   - most-severe-action orders them by the severity of the action and then by the priorities
+func Collide(mode ResolutionMode, AMTD *amtdv1beta1.AdaptiveMovingTargetDefense, AMTDOther *amtdv1beta1.AdaptiveMovingTargetDefense) bool {
+	if mode == RunAll {
+		return false
+	}
+	for _, strategyOther := range AMTDOther.Spec.Strategy {
+		for _, strategy := range AMTD.Spec.Strategy {
+			if reflect.DeepEqual(strategyOther.Action, strategy.Action) || !Overlap(strategyOther.Rule, strategy.Rule) {
+				continue
+			}
+			samePriority := AMTD.Spec.Priority == AMTDOther.Spec.Priority && strategy.Priority == strategyOther.Priority
+			switch mode {
+			case FirstMatch:
+				return true
+			case MostSevereAction:
+				if samePriority && actions.Severity(actions.NameOf(strategy.Action)) == actions.Severity(actions.NameOf(strategyOther.Action)) {
+					return true
+				}
+			default:
+				if samePriority {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
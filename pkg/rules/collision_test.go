@@ -0,0 +1,92 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package rules
+
+import (
+	"testing"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+func TestOverlap(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    amtdv1beta1.Rule
+		other   amtdv1beta1.Rule
+		overlap bool
+	}{
+		{"same rules", amtdv1beta1.Rule{Type: "proc-a"}, amtdv1beta1.Rule{Type: "proc-a"}, true},
+		{"different values", amtdv1beta1.Rule{Type: "proc-a"}, amtdv1beta1.Rule{Type: "proc-b"}, false},
+		{"any value", amtdv1beta1.Rule{Source: "falco"}, amtdv1beta1.Rule{Type: "proc-a", Source: "*"}, true},
+		{"glob and value", amtdv1beta1.Rule{Type: "proc-*"}, amtdv1beta1.Rule{Type: "proc-a"}, true},
+		{"disjoint globs", amtdv1beta1.Rule{Type: "proc-*"}, amtdv1beta1.Rule{Type: "net-*"}, false},
+		{"crossing globs", amtdv1beta1.Rule{Type: "*-a"}, amtdv1beta1.Rule{Type: "proc-?"}, true},
+		{"list and value", amtdv1beta1.Rule{Source: "falco,kubearmor"}, amtdv1beta1.Rule{Source: "kubearmor"}, true},
+		{"disjoint lists", amtdv1beta1.Rule{Source: "falco,kubearmor"}, amtdv1beta1.Rule{Source: "tetragon, trivy"}, false},
+		{"regex and glob", amtdv1beta1.Rule{Type: "regex:proc-[0-9]+"}, amtdv1beta1.Rule{Type: "proc-1*"}, true},
+		{"disjoint regex and glob", amtdv1beta1.Rule{Type: "regex:proc-[0-9]+"}, amtdv1beta1.Rule{Type: "proc-a*"}, false},
+		{"regexes", amtdv1beta1.Rule{Type: "regex:(read|write)-.*"}, amtdv1beta1.Rule{Type: "regex:.*-etc"}, true},
+		{"invalid regex", amtdv1beta1.Rule{Type: "regex:("}, amtdv1beta1.Rule{}, false},
+		{"one field differs", amtdv1beta1.Rule{Type: "proc-*", Source: "falco"}, amtdv1beta1.Rule{Type: "proc-a", Source: "kubearmor"}, false},
+		{"thresholds", amtdv1beta1.Rule{ThreatLevel: ">=high"}, amtdv1beta1.Rule{ThreatLevel: "<=high"}, true},
+		{"disjoint thresholds", amtdv1beta1.Rule{ThreatLevel: ">high"}, amtdv1beta1.Rule{ThreatLevel: "<=medium"}, false},
+		{"threshold and level", amtdv1beta1.Rule{ThreatLevel: ">=medium"}, amtdv1beta1.Rule{ThreatLevel: "Warning"}, true},
+		{"threshold and other level", amtdv1beta1.Rule{ThreatLevel: ">=high"}, amtdv1beta1.Rule{ThreatLevel: "Warning"}, false},
+	}
+	for _, test := range tests {
+		if got := Overlap(test.rule, test.other); got != test.overlap {
+			t.Errorf("%s: Overlap(%+v, %+v) = %v, want %v", test.name, test.rule, test.other, got, test.overlap)
+		}
+		if got := Overlap(test.other, test.rule); got != test.overlap {
+			t.Errorf("%s: Overlap is not symmetric", test.name)
+		}
+	}
+}
+
+func TestCollide(t *testing.T) {
+	newAMTD := func(priority int32, action amtdv1beta1.AMTDAction) *amtdv1beta1.AdaptiveMovingTargetDefense {
+		return &amtdv1beta1.AdaptiveMovingTargetDefense{Spec: amtdv1beta1.AdaptiveMovingTargetDefenseSpec{
+			Priority: priority,
+			Strategy: []amtdv1beta1.ResponseStrategy{{Rule: amtdv1beta1.Rule{Type: "proc-*"}, Action: action}},
+		}}
+	}
+	deleteAction := amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{}}
+	quarantineAction := amtdv1beta1.AMTDAction{Quarantine: &amtdv1beta1.QuarantineAction{}}
+	disableAction := amtdv1beta1.AMTDAction{Disable: &amtdv1beta1.DisableAction{}}
+	other := newAMTD(0, deleteAction)
+
+	tests := []struct {
+		name    string
+		mode    ResolutionMode
+		AMTD    *amtdv1beta1.AdaptiveMovingTargetDefense
+		collide bool
+	}{
+		{"same action", FirstMatch, newAMTD(0, deleteAction), false},
+		{"first match ignores priorities", FirstMatch, newAMTD(1, quarantineAction), true},
+		{"highest priority with the same priority", HighestPriority, newAMTD(0, quarantineAction), true},
+		{"highest priority with another priority", HighestPriority, newAMTD(1, quarantineAction), false},
+		{"most severe action with another severity", MostSevereAction, newAMTD(0, quarantineAction), false},
+		{"most severe action with the same severity", MostSevereAction, newAMTD(0, amtdv1beta1.AMTDAction{Delete: &amtdv1beta1.DeleteAction{Mode: amtdv1beta1.DeleteDirect}}), true},
+		{"run all", RunAll, newAMTD(0, disableAction), false},
+	}
+	for _, test := range tests {
+		if got := Collide(test.mode, test.AMTD, other); got != test.collide {
+			t.Errorf("%s: Collide = %v, want %v", test.name, got, test.collide)
+		}
+	}
+}
@@ -0,0 +1,195 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+// Package rules matches the rules of AMTD strategies against the rules of SecurityEvents.
+//
+// Each field of a strategy rule is a pattern:
+//   - "" or "*" matches any value
+//   - "regex:<expression>" matches values that the (fully anchored) regular expression matches
+//   - "a,b,c" matches any value in the comma separated list, where each item may be a pattern below
+//   - "net-*" or "file-?" matches as a glob, where "*" is any sequence of characters and "?" is a single character
+//   - anything else matches the exact value
+//
+// The threatLevel field may also be a threshold on the severity scale (see ThreatLevel),
+// e.g. ">=high" matches the high and critical threats.
+package rules
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+const (
+	// Any is the pattern that matches any value, the empty pattern means the same
+	Any = "*"
+	// RegexPrefix marks a pattern as a regular expression
+	RegexPrefix = "regex:"
+	// ListSeparator separates the values of a list pattern
+	ListSeparator = ","
+)
+
+// Specificity of a matching field, the more specific a rule is the better it matches.
+// Thresholds fall between regular expressions and globs depending on their bound.
+const (
+	specificityAny       = 0
+	specificityRegex     = 10
+	specificityThreshold = 11
+	specificityGlob      = 20
+	specificityExact     = 30
+)
+
+// Match reports whether the SecurityEvent rule matches the strategy rule. Invalid
+// patterns do not match anything.
+func Match(strategy amtdv1beta1.Rule, event amtdv1beta1.Rule) bool {
+	_, ok := Score(strategy, event)
+	return ok
+}
+
+// Score reports whether the SecurityEvent rule matches the strategy rule and how specific
+// the match is. When several strategies match the one with the highest score is the best.
+func Score(strategy amtdv1beta1.Rule, event amtdv1beta1.Rule) (int, bool) {
+	score := 0
+	for _, field := range fields(strategy, event) {
+		specificity, ok := matchField(field[0], field[1])
+		if !ok {
+			return 0, false
+		}
+		score += specificity
+	}
+
+	specificity, ok := matchThreatLevel(strategy.ThreatLevel, event.ThreatLevel)
+	if !ok {
+		return 0, false
+	}
+	return score + specificity, true
+}
+
+// Validate checks that every field of the strategy rule is a valid pattern
+func Validate(strategy amtdv1beta1.Rule) error {
+	names := []string{"type", "source"}
+	for i, field := range fields(strategy, amtdv1beta1.Rule{}) {
+		if err := validatePattern(field[0]); err != nil {
+			return fmt.Errorf(`invalid %s pattern "%s": %w`, names[i], field[0], err)
+		}
+	}
+
+	if _, _, isThreshold, err := parseThreshold(strings.TrimSpace(strategy.ThreatLevel)); isThreshold {
+		if err != nil {
+			return fmt.Errorf(`invalid threatLevel threshold "%s": %w`, strategy.ThreatLevel, err)
+		}
+		return nil
+	}
+	if err := validatePattern(strategy.ThreatLevel); err != nil {
+		return fmt.Errorf(`invalid threatLevel pattern "%s": %w`, strategy.ThreatLevel, err)
+	}
+	return nil
+}
+
+// fields pairs the fields of the strategy rule with the fields of the SecurityEvent rule,
+// except for the threat level that is matched by matchThreatLevel
+func fields(strategy amtdv1beta1.Rule, event amtdv1beta1.Rule) [][2]string {
+	return [][2]string{
+		{strategy.Type, event.Type},
+		{strategy.Source, event.Source},
+	}
+}
+
+// matchThreatLevel matches the threat level either against a threshold or as any other field
+func matchThreatLevel(pattern string, value string) (int, bool) {
+	operator, bound, isThreshold, err := parseThreshold(strings.TrimSpace(pattern))
+	if !isThreshold {
+		return matchField(pattern, value)
+	}
+	if err != nil {
+		return 0, false
+	}
+	return matchThreshold(operator, bound, value)
+}
+
+func matchField(pattern string, value string) (int, bool) {
+	pattern = strings.TrimSpace(pattern)
+
+	switch {
+	case pattern == "" || pattern == Any:
+		return specificityAny, true
+	case strings.HasPrefix(pattern, RegexPrefix):
+		expression, err := compileRegex(strings.TrimPrefix(pattern, RegexPrefix))
+		if err != nil {
+			return 0, false
+		}
+		return specificityRegex, expression.MatchString(value)
+	case strings.Contains(pattern, ListSeparator):
+		best, matched := 0, false
+		for _, item := range strings.Split(pattern, ListSeparator) {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if specificity, ok := matchItem(item, value); ok && (!matched || specificity > best) {
+				best, matched = specificity, true
+			}
+		}
+		return best, matched
+	default:
+		return matchItem(pattern, value)
+	}
+}
+
+// matchItem matches a single exact or glob value
+func matchItem(pattern string, value string) (int, bool) {
+	if pattern == Any {
+		return specificityAny, true
+	}
+	if !strings.ContainsAny(pattern, "*?") {
+		return specificityExact, pattern == value
+	}
+	return specificityGlob, globToRegex(pattern).MatchString(value)
+}
+
+func validatePattern(pattern string) error {
+	pattern = strings.TrimSpace(pattern)
+	if strings.HasPrefix(pattern, RegexPrefix) {
+		_, err := compileRegex(strings.TrimPrefix(pattern, RegexPrefix))
+		return err
+	}
+	return nil
+}
+
+func compileRegex(expression string) (*regexp.Regexp, error) {
+	return regexp.Compile("^(?:" + expression + ")$")
+}
+
+// globToRegex translates a glob pattern, unlike path.Match "*" also matches "/"
+func globToRegex(pattern string) *regexp.Regexp {
+	var expression strings.Builder
+	expression.WriteString("^")
+	for _, r := range pattern {
+		switch r {
+		case '*':
+			expression.WriteString(".*")
+		case '?':
+			expression.WriteString(".")
+		default:
+			expression.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	expression.WriteString("$")
+	return regexp.MustCompile(expression.String())
+}
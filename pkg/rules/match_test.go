@@ -0,0 +1,152 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package rules
+
+import (
+	"testing"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+func TestMatch(t *testing.T) {
+	event := amtdv1beta1.Rule{Type: "Write below /etc", ThreatLevel: "Warning", Source: "falco"}
+
+	tests := []struct {
+		name     string
+		strategy amtdv1beta1.Rule
+		match    bool
+	}{
+		{"empty fields match any value", amtdv1beta1.Rule{}, true},
+		{"star matches any value", amtdv1beta1.Rule{Type: "*", Source: "falco"}, true},
+		{"exact", amtdv1beta1.Rule{Type: "Write below /etc", ThreatLevel: "Warning", Source: "falco"}, true},
+		{"exact mismatch", amtdv1beta1.Rule{Source: "kubearmor"}, false},
+		{"glob crosses slashes", amtdv1beta1.Rule{Type: "Write below *"}, true},
+		{"glob single character", amtdv1beta1.Rule{Source: "falc?"}, true},
+		{"glob mismatch", amtdv1beta1.Rule{Type: "Read *"}, false},
+		{"list", amtdv1beta1.Rule{ThreatLevel: "Notice, Warning"}, true},
+		{"list of globs", amtdv1beta1.Rule{Type: "Read *,Write *"}, true},
+		{"list mismatch", amtdv1beta1.Rule{ThreatLevel: "Error,Critical"}, false},
+		{"regex is anchored", amtdv1beta1.Rule{Type: "regex:Write"}, false},
+		{"regex", amtdv1beta1.Rule{Type: "regex:(Read|Write) below /(etc|root)"}, true},
+		{"invalid regex never matches", amtdv1beta1.Rule{Type: "regex:("}, false},
+	}
+	for _, test := range tests {
+		if got := Match(test.strategy, event); got != test.match {
+			t.Errorf("%s: Match(%+v) = %v, want %v", test.name, test.strategy, got, test.match)
+		}
+	}
+}
+
+func TestScorePrefersSpecificRules(t *testing.T) {
+	event := amtdv1beta1.Rule{Type: "network-attack", ThreatLevel: "medium", Source: "falco"}
+
+	exact, _ := Score(amtdv1beta1.Rule{Type: "network-attack", Source: "falco"}, event)
+	glob, _ := Score(amtdv1beta1.Rule{Type: "network-*", Source: "falco"}, event)
+	any, _ := Score(amtdv1beta1.Rule{Source: "falco"}, event)
+	if !(exact > glob && glob > any) {
+		t.Errorf("expected exact (%d) > glob (%d) > any (%d)", exact, glob, any)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	if err := Validate(amtdv1beta1.Rule{Type: "regex:net-.*", Source: "falco,kubearmor"}); err != nil {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if err := Validate(amtdv1beta1.Rule{Source: "regex:[a-"}); err == nil {
+		t.Errorf("expected an error for an invalid regular expression")
+	}
+}
+
+func TestThresholdMatch(t *testing.T) {
+	tests := []struct {
+		threshold string
+		level     string
+		match     bool
+	}{
+		{">=high", "critical", true},
+		{">=high", "High", true},
+		{">=high", "medium", false},
+		{">= high", "Error", true},
+		{">=high", "Warning", false},
+		{">high", "Emergency", true},
+		{"<=low", "Notice", true},
+		{"<medium", "medium", false},
+		{"=medium", "Warning", true},
+		{">=medium", "7", true},
+		{">=medium", "4", false},
+		{">=info", "unknown", false},
+		{">=unknown", "critical", false},
+	}
+	for _, test := range tests {
+		strategy := amtdv1beta1.Rule{ThreatLevel: test.threshold}
+		event := amtdv1beta1.Rule{ThreatLevel: test.level}
+		if got := Match(strategy, event); got != test.match {
+			t.Errorf("Match(%q, %q) = %v, want %v", test.threshold, test.level, got, test.match)
+		}
+	}
+}
+
+func TestScorePrefersTighterThresholds(t *testing.T) {
+	event := amtdv1beta1.Rule{ThreatLevel: "critical"}
+
+	medium, _ := Score(amtdv1beta1.Rule{ThreatLevel: ">=medium"}, event)
+	high, _ := Score(amtdv1beta1.Rule{ThreatLevel: ">=high"}, event)
+	critical, _ := Score(amtdv1beta1.Rule{ThreatLevel: ">=critical"}, event)
+	if !(critical > high && high > medium) {
+		t.Errorf("expected >=critical (%d) > >=high (%d) > >=medium (%d)", critical, high, medium)
+	}
+	if err := Validate(amtdv1beta1.Rule{ThreatLevel: ">=severe-ish"}); err == nil {
+		t.Errorf("expected an error for an unknown threshold level")
+	}
+}
+
+func TestResolve(t *testing.T) {
+	candidates := []Candidate{
+		{Action: "debugger", Severity: 1, Score: 30, Order: 0},
+		{Action: "quarantine", Severity: 3, Priority: 5, Order: 1},
+		{Action: "delete", Severity: 4, Order: 2},
+		{Action: "quarantine", Severity: 3, AMTDPriority: 1, Order: 3},
+	}
+
+	tests := []struct {
+		mode ResolutionMode
+		want []int
+	}{
+		{FirstMatch, []int{0}},
+		{HighestPriority, []int{3}},
+		{MostSevereAction, []int{2}},
+		{RunAll, []int{0, 3, 2}},
+	}
+	for _, test := range tests {
+		got := Resolve(test.mode, candidates)
+		if len(got) != len(test.want) {
+			t.Errorf("%s: Resolve() = %v, want %v", test.mode, got, test.want)
+			continue
+		}
+		for i := range got {
+			if got[i] != test.want[i] {
+				t.Errorf("%s: Resolve() = %v, want %v", test.mode, got, test.want)
+				break
+			}
+		}
+	}
+
+	if _, err := ParseResolutionMode("random"); err == nil {
+		t.Errorf("expected an error for an unknown resolution mode")
+	}
+}
@@ -0,0 +1,252 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package rules
+
+import (
+	"regexp"
+	"regexp/syntax"
+	"strconv"
+	"strings"
+	"unicode"
+
+	amtdv1beta1 "github.com/r6security/phoenix/api/v1beta1"
+)
+
+// Overlap reports whether a SecurityEvent rule exists that matches both strategy rules, e.g.
+// "proc-*" and "proc-a" overlap while "proc-*" and "net-*" do not. Invalid patterns do not
+// overlap with anything since they do not match anything.
+func Overlap(rule amtdv1beta1.Rule, other amtdv1beta1.Rule) bool {
+	for _, field := range [][2]string{{rule.Type, other.Type}, {rule.Source, other.Source}} {
+		if !overlapField(field[0], field[1]) {
+			return false
+		}
+	}
+	return overlapThreatLevel(rule.ThreatLevel, other.ThreatLevel)
+}
+
+// overlapField reports whether a value exists that both patterns match
+func overlapField(pattern string, other string) bool {
+	for _, expression := range alternatives(pattern) {
+		for _, expressionOther := range alternatives(other) {
+			if expression == nil || expressionOther == nil {
+				// one of them matches any value
+				return true
+			}
+			if intersect(expression, expressionOther) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// alternatives translates the pattern into the compiled regular expressions of its alternatives,
+// nil stands for any value. Invalid alternatives are left out.
+func alternatives(pattern string) []*syntax.Prog {
+	pattern = strings.TrimSpace(pattern)
+
+	items := []string{}
+	switch {
+	case pattern == "" || pattern == Any:
+		return []*syntax.Prog{nil}
+	case strings.HasPrefix(pattern, RegexPrefix):
+		items = append(items, "^(?:"+strings.TrimPrefix(pattern, RegexPrefix)+")$")
+	default:
+		for _, item := range strings.Split(pattern, ListSeparator) {
+			item = strings.TrimSpace(item)
+			switch {
+			case item == "":
+				continue
+			case item == Any:
+				return []*syntax.Prog{nil}
+			case strings.ContainsAny(item, "*?"):
+				items = append(items, globToRegex(item).String())
+			default:
+				items = append(items, "^"+regexp.QuoteMeta(item)+"$")
+			}
+		}
+	}
+
+	programs := []*syntax.Prog{}
+	for _, item := range items {
+		parsed, err := syntax.Parse(item, syntax.Perl)
+		if err != nil {
+			continue
+		}
+		program, err := syntax.Compile(parsed.Simplify())
+		if err != nil {
+			continue
+		}
+		programs = append(programs, program)
+	}
+	return programs
+}
+
+// intersect reports whether a string exists that both programs match, by walking the product of
+// the two automatons. Empty-width assertions are assumed to hold, so the result may be a false
+// positive for expressions relying on e.g. word boundaries.
+func intersect(program *syntax.Prog, other *syntax.Prog) bool {
+	type state struct{ pc, pcOther uint32 }
+	start := state{uint32(program.Start), uint32(other.Start)}
+	visited := map[state]bool{start: true}
+	queue := []state{start}
+	for len(queue) > 0 {
+		current := queue[0]
+		queue = queue[1:]
+		for _, pc := range closure(program, current.pc) {
+			for _, pcOther := range closure(other, current.pcOther) {
+				inst, instOther := &program.Inst[pc], &other.Inst[pcOther]
+				if inst.Op == syntax.InstMatch && instOther.Op == syntax.InstMatch {
+					return true
+				}
+				if inst.Op == syntax.InstMatch || instOther.Op == syntax.InstMatch || !sharedRune(inst, instOther) {
+					continue
+				}
+				next := state{inst.Out, instOther.Out}
+				if !visited[next] {
+					visited[next] = true
+					queue = append(queue, next)
+				}
+			}
+		}
+	}
+	return false
+}
+
+// closure returns the instructions that consume a rune or match, reachable from pc without consuming input
+func closure(program *syntax.Prog, pc uint32) []uint32 {
+	visited := map[uint32]bool{}
+	result := []uint32{}
+	stack := []uint32{pc}
+	for len(stack) > 0 {
+		pc := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		if visited[pc] {
+			continue
+		}
+		visited[pc] = true
+
+		inst := &program.Inst[pc]
+		switch inst.Op {
+		case syntax.InstAlt, syntax.InstAltMatch:
+			stack = append(stack, inst.Out, inst.Arg)
+		case syntax.InstCapture, syntax.InstEmptyWidth, syntax.InstNop:
+			stack = append(stack, inst.Out)
+		case syntax.InstFail:
+		default:
+			result = append(result, pc)
+		}
+	}
+	return result
+}
+
+// sharedRune reports whether a rune exists that both rune instructions accept. If two rune sets
+// overlap, the lower bound of one of the overlapping ranges is in both of them.
+func sharedRune(inst *syntax.Inst, other *syntax.Inst) bool {
+	candidates := []rune{'a', '\n'}
+	for _, i := range []*syntax.Inst{inst, other} {
+		if i.Op != syntax.InstRune && i.Op != syntax.InstRune1 {
+			continue
+		}
+		for j := 0; j < len(i.Rune); j += 2 {
+			candidates = append(candidates, i.Rune[j])
+			for folded := unicode.SimpleFold(i.Rune[j]); folded != i.Rune[j]; folded = unicode.SimpleFold(folded) {
+				candidates = append(candidates, folded)
+			}
+		}
+	}
+	for _, r := range candidates {
+		if matchRune(inst, r) && matchRune(other, r) {
+			return true
+		}
+	}
+	return false
+}
+
+// matchRune reports whether the rune instruction accepts r
+func matchRune(inst *syntax.Inst, r rune) bool {
+	switch inst.Op {
+	case syntax.InstRuneAny:
+		return true
+	case syntax.InstRuneAnyNotNL:
+		return r != '\n'
+	default:
+		return inst.MatchRune(r)
+	}
+}
+
+// overlapThreatLevel reports whether a threat level exists that both patterns match, either
+// of them may be a threshold
+func overlapThreatLevel(pattern string, other string) bool {
+	levels, isThreshold := thresholdLevels(pattern)
+	levelsOther, isThresholdOther := thresholdLevels(other)
+	switch {
+	case isThreshold && isThresholdOther:
+		for level := range levels {
+			if levelsOther[level] {
+				return true
+			}
+		}
+		return false
+	case isThreshold:
+		return matchesLevel(other, levels)
+	case isThresholdOther:
+		return matchesLevel(pattern, levelsOther)
+	default:
+		return overlapField(pattern, other)
+	}
+}
+
+// thresholdLevels returns the threat levels the threshold pattern matches
+func thresholdLevels(pattern string) (map[ThreatLevel]bool, bool) {
+	operator, bound, isThreshold, err := parseThreshold(strings.TrimSpace(pattern))
+	if !isThreshold {
+		return nil, false
+	}
+	levels := map[ThreatLevel]bool{}
+	if err != nil {
+		return levels, true
+	}
+	for level := Info; level <= Critical; level++ {
+		if _, ok := matchThreshold(operator, bound, level.String()); ok {
+			levels[level] = true
+		}
+	}
+	return levels, true
+}
+
+// matchesLevel reports whether the pattern matches a name of one of the threat levels
+func matchesLevel(pattern string, levels map[ThreatLevel]bool) bool {
+	names := []string{}
+	for name, level := range threatLevelAliases {
+		if levels[level] {
+			names = append(names, name, strings.ToUpper(name), strings.ToUpper(name[:1])+name[1:])
+		}
+	}
+	for severity := 1; severity <= 10; severity++ {
+		if levels[ThreatLevel((severity-1)/2)] {
+			names = append(names, strconv.Itoa(severity))
+		}
+	}
+	for _, name := range names {
+		if _, ok := matchField(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
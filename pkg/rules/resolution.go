@@ -0,0 +1,137 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package rules
+
+import (
+	"fmt"
+	"sort"
+)
+
+// ResolutionMode decides which of the matching strategies are executed when several
+// strategies (of one or more AMTDs managing the same pod) match a SecurityEvent
+type ResolutionMode string
+
+const (
+	// FirstMatch executes the first matching strategy, AMTDs in the order they started
+	// managing the pod and strategies in the order they are listed
+	FirstMatch ResolutionMode = "first-match"
+	// HighestPriority executes the strategy with the highest AMTD priority, then strategy
+	// priority, then rule specificity, then order
+	HighestPriority ResolutionMode = "highest-priority"
+	// MostSevereAction executes the strategy with the most severe action, ties are broken
+	// as in HighestPriority
+	MostSevereAction ResolutionMode = "most-severe-action"
+	// RunAll executes every matching strategy once per action, from the least to the most
+	// severe action
+	RunAll ResolutionMode = "run-all"
+)
+
+// ResolutionModes lists the supported resolution modes
+var ResolutionModes = []ResolutionMode{FirstMatch, HighestPriority, MostSevereAction, RunAll}
+
+// ParseResolutionMode validates the name of a resolution mode
+func ParseResolutionMode(value string) (ResolutionMode, error) {
+	for _, mode := range ResolutionModes {
+		if string(mode) == value {
+			return mode, nil
+		}
+	}
+	return "", fmt.Errorf(`unknown resolution mode "%s", it must be one of %v`, value, ResolutionModes)
+}
+
+// Candidate is a strategy that matches the SecurityEvent
+type Candidate struct {
+	// AMTDPriority is the priority of the AMTD the strategy belongs to
+	AMTDPriority int32
+	// Priority is the priority of the strategy within its AMTD
+	Priority int32
+	// Score is the specificity of the matching rule as returned by Score
+	Score int
+	// Action is the name of the action of the strategy
+	Action string
+	// Severity of the action, the higher the more destructive
+	Severity int
+	// Order is the position of the strategy when AMTDs and their strategies are listed in order
+	Order int
+}
+
+// Resolve returns the indexes of the candidates to execute, in the order of execution
+func Resolve(mode ResolutionMode, candidates []Candidate) []int {
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	indexes := make([]int, len(candidates))
+	for i := range indexes {
+		indexes[i] = i
+	}
+
+	byPriority := func(a, b Candidate) bool {
+		if a.AMTDPriority != b.AMTDPriority {
+			return a.AMTDPriority > b.AMTDPriority
+		}
+		if a.Priority != b.Priority {
+			return a.Priority > b.Priority
+		}
+		if a.Score != b.Score {
+			return a.Score > b.Score
+		}
+		return a.Order < b.Order
+	}
+
+	switch mode {
+	case FirstMatch:
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return candidates[indexes[i]].Order < candidates[indexes[j]].Order
+		})
+		return indexes[:1]
+	case MostSevereAction:
+		sort.SliceStable(indexes, func(i, j int) bool {
+			a, b := candidates[indexes[i]], candidates[indexes[j]]
+			if a.Severity != b.Severity {
+				return a.Severity > b.Severity
+			}
+			return byPriority(a, b)
+		})
+		return indexes[:1]
+	case RunAll:
+		// the best strategy of each action is kept
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return byPriority(candidates[indexes[i]], candidates[indexes[j]])
+		})
+		seen := map[string]bool{}
+		selected := []int{}
+		for _, index := range indexes {
+			if seen[candidates[index].Action] {
+				continue
+			}
+			seen[candidates[index].Action] = true
+			selected = append(selected, index)
+		}
+		// destructive actions last so that the others still find the pod
+		sort.SliceStable(selected, func(i, j int) bool {
+			return candidates[selected[i]].Severity < candidates[selected[j]].Severity
+		})
+		return selected
+	default:
+		sort.SliceStable(indexes, func(i, j int) bool {
+			return byPriority(candidates[indexes[i]], candidates[indexes[j]])
+		})
+		return indexes[:1]
+	}
+}
@@ -0,0 +1,129 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package rules
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// ThreatLevel is the position of a threat on the severity scale
+type ThreatLevel int
+
+// The severity scale, from the least to the most severe
+const (
+	Info ThreatLevel = iota
+	Low
+	Medium
+	High
+	Critical
+)
+
+var threatLevelNames = []string{"info", "low", "medium", "high", "critical"}
+
+// String returns the canonical name of the threat level
+func (l ThreatLevel) String() string {
+	if l < Info || l > Critical {
+		return fmt.Sprintf("ThreatLevel(%d)", int(l))
+	}
+	return threatLevelNames[l]
+}
+
+// threatLevelAliases maps the names used by the integration backends onto the scale
+var threatLevelAliases = map[string]ThreatLevel{
+	// canonical names
+	"info":     Info,
+	"low":      Low,
+	"medium":   Medium,
+	"high":     High,
+	"critical": Critical,
+
+	// Falco priorities
+	"debug":         Info,
+	"informational": Info,
+	"notice":        Low,
+	"warning":       Medium,
+	"error":         High,
+	"alert":         Critical,
+	"emergency":     Critical,
+
+	// common variants
+	"information": Info,
+	"warn":        Medium,
+	"moderate":    Medium,
+	"err":         High,
+	"severe":      High,
+	"fatal":       Critical,
+}
+
+// ParseThreatLevel maps a threat level name onto the scale. Besides the canonical names it
+// understands the Falco priorities and the numeric KubeArmor severities (1-10). Names are
+// case insensitive.
+func ParseThreatLevel(value string) (ThreatLevel, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if level, found := threatLevelAliases[value]; found {
+		return level, true
+	}
+
+	// KubeArmor severity is a number between 1 and 10
+	if severity, err := strconv.Atoi(value); err == nil && severity >= 1 && severity <= 10 {
+		return ThreatLevel((severity - 1) / 2), true
+	}
+	return Info, false
+}
+
+// thresholdOperators are the comparison operators of threat level thresholds, longer operators first
+var thresholdOperators = []string{">=", "<=", ">", "<", "="}
+
+// parseThreshold splits a threshold pattern such as ">=high" into its operator and level
+func parseThreshold(pattern string) (string, ThreatLevel, bool, error) {
+	for _, operator := range thresholdOperators {
+		if strings.HasPrefix(pattern, operator) {
+			value := strings.TrimSpace(strings.TrimPrefix(pattern, operator))
+			level, ok := ParseThreatLevel(value)
+			if !ok {
+				return operator, Info, true, fmt.Errorf(`unknown threat level "%s"`, value)
+			}
+			return operator, level, true, nil
+		}
+	}
+	return "", Info, false, nil
+}
+
+// matchThreshold matches a threat level against a threshold. The returned specificity grows
+// with the tightness of the bound, so that ">=critical" is preferred over ">=high".
+func matchThreshold(operator string, bound ThreatLevel, value string) (int, bool) {
+	level, ok := ParseThreatLevel(value)
+	if !ok {
+		return 0, false
+	}
+
+	switch operator {
+	case ">=":
+		return specificityThreshold + int(bound), level >= bound
+	case ">":
+		return specificityThreshold + int(bound) + 1, level > bound
+	case "<=":
+		return specificityThreshold + int(Critical-bound), level <= bound
+	case "<":
+		return specificityThreshold + int(Critical-bound) + 1, level < bound
+	default:
+		return specificityThreshold + int(Critical) + 1, level == bound
+	}
+}
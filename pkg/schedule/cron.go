@@ -0,0 +1,209 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+// Package schedule parses the cron expressions of the periodic pod rotation.
+//
+// An expression has five space separated fields:
+//
+//	minute (0-59) hour (0-23) day-of-month (1-31) month (1-12 or jan-dec) day-of-week (0-6 or sun-sat, 7 is sunday)
+//
+// Each field is a comma separated list of "*", a value "5", a range "1-5" or any of them
+// with a step "*/15", "1-30/2", "10/5" (the latter from 10 to the maximum). When both the
+// day-of-month and the day-of-week are restricted, a day matching either of them matches,
+// as in the classic cron. The macros @yearly (@annually), @monthly, @weekly, @daily (@midnight)
+// and @hourly are accepted too.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cron is a parsed cron expression
+type Cron struct {
+	minute, hour, dayOfMonth, month, dayOfWeek uint64
+
+	// the day fields are restricted unless they start with "*"
+	dayOfMonthRestricted, dayOfWeekRestricted bool
+}
+
+// bounds of a field of the expression, names are mapped to min+index
+type bounds struct {
+	name     string
+	min, max int
+	names    []string
+}
+
+var (
+	minuteBounds     = bounds{"minute", 0, 59, nil}
+	hourBounds       = bounds{"hour", 0, 23, nil}
+	dayOfMonthBounds = bounds{"day-of-month", 1, 31, nil}
+	monthBounds      = bounds{"month", 1, 12, []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}}
+	dayOfWeekBounds  = bounds{"day-of-week", 0, 7, []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// searchLimit bounds the search of Next, expressions like "0 0 30 2 *" never activate
+const searchLimit = 5 * 366 * 24 * time.Hour
+
+// ParseCron parses a cron expression of five fields or a macro
+func ParseCron(expression string) (*Cron, error) {
+	expression = strings.TrimSpace(expression)
+	if macro, found := macros[strings.ToLower(expression)]; found {
+		expression = macro
+	}
+	fields := strings.Fields(expression)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf(`cron expression "%s" must have 5 fields, got %d`, expression, len(fields))
+	}
+
+	cron := &Cron{
+		dayOfMonthRestricted: !strings.HasPrefix(fields[2], "*"),
+		dayOfWeekRestricted:  !strings.HasPrefix(fields[4], "*"),
+	}
+	var err error
+	for i, target := range []struct {
+		bits   *uint64
+		bounds bounds
+	}{
+		{&cron.minute, minuteBounds},
+		{&cron.hour, hourBounds},
+		{&cron.dayOfMonth, dayOfMonthBounds},
+		{&cron.month, monthBounds},
+		{&cron.dayOfWeek, dayOfWeekBounds},
+	} {
+		if *target.bits, err = parseField(fields[i], target.bounds); err != nil {
+			return nil, fmt.Errorf(`invalid cron expression "%s": %w`, expression, err)
+		}
+	}
+	// 7 is an alias of sunday
+	if cron.dayOfWeek&(1<<7) != 0 {
+		cron.dayOfWeek |= 1
+	}
+	return cron, nil
+}
+
+// parseField returns the values of the field as a bit set
+func parseField(field string, b bounds) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(item, "/")
+
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf(`invalid step "%s" in %s "%s"`, stepPart, b.name, item)
+			}
+		}
+
+		var low, high int
+		switch {
+		case rangePart == "*":
+			low, high = b.min, b.max
+		case strings.Contains(rangePart, "-"):
+			lowPart, highPart, _ := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = parseValue(lowPart, b); err != nil {
+				return 0, err
+			}
+			if high, err = parseValue(highPart, b); err != nil {
+				return 0, err
+			}
+			if low > high {
+				return 0, fmt.Errorf(`invalid range "%s" in %s`, rangePart, b.name)
+			}
+		default:
+			var err error
+			if low, err = parseValue(rangePart, b); err != nil {
+				return 0, err
+			}
+			high = low
+			if hasStep {
+				high = b.max
+			}
+		}
+
+		for value := low; value <= high; value += step {
+			bits |= 1 << value
+		}
+	}
+	return bits, nil
+}
+
+// parseValue parses a number or a name of the field
+func parseValue(value string, b bounds) (int, error) {
+	for i, name := range b.names {
+		if strings.EqualFold(value, name) {
+			return b.min + i, nil
+		}
+	}
+	number, err := strconv.Atoi(value)
+	if err != nil || number < b.min || number > b.max {
+		return 0, fmt.Errorf(`invalid %s "%s", must be between %d and %d`, b.name, value, b.min, b.max)
+	}
+	return number, nil
+}
+
+// Next returns the first activation of the expression after t in the location of t,
+// or the zero time if it never activates
+func (c *Cron) Next(t time.Time) time.Time {
+	limit := t.Add(searchLimit)
+	t = t.Truncate(time.Minute).Add(time.Minute)
+
+	for t.Before(limit) {
+		if c.month&(1<<uint(t.Month())) == 0 {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !c.matchDay(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.hour&(1<<uint(t.Hour())) == 0 {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if c.minute&(1<<uint(t.Minute())) == 0 {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// matchDay applies the day-of-month and day-of-week fields to the day of t
+func (c *Cron) matchDay(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth&(1<<uint(t.Day())) != 0
+	dayOfWeek := c.dayOfWeek&(1<<uint(t.Weekday())) != 0
+	if c.dayOfMonthRestricted && c.dayOfWeekRestricted {
+		return dayOfMonth || dayOfWeek
+	}
+	return dayOfMonth && dayOfWeek
+}
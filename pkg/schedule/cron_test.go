@@ -0,0 +1,74 @@
+/*
+ * Copyright (C) 2023 R6 Security, Inc.
+ *
+ * This program is free software: you can redistribute it and/or modify
+ * it under the terms of the Server Side Public License, version 1,
+ * as published by MongoDB, Inc.
+ *
+ * This program is distributed in the hope that it will be useful,
+ * but WITHOUT ANY WARRANTY; without even the implied warranty of
+ * MERCHANTABILITY or FITNESS FOR A PARTICULAR PURPOSE. See the
+ * Server Side Public License for more details.
+ *
+ * You should have received a copy of the Server Side Public License
+ * along with this program. If not, see
+ * <http://www.mongodb.com/licensing/server-side-public-license>.
+ */
+
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNext(t *testing.T) {
+	// Friday
+	now := time.Date(2024, time.March, 1, 10, 17, 30, 0, time.UTC)
+
+	tests := []struct {
+		expression string
+		want       time.Time
+	}{
+		{"* * * * *", time.Date(2024, time.March, 1, 10, 18, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, time.March, 1, 10, 30, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2024, time.March, 2, 3, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2024, time.March, 1, 11, 0, 0, 0, time.UTC)},
+		{"30 2 * * mon-wed", time.Date(2024, time.March, 4, 2, 30, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, time.March, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, time.February, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 12 1,15 * *", time.Date(2024, time.March, 1, 12, 0, 0, 0, time.UTC)},
+		// restricted day-of-month and day-of-week match either of them
+		{"0 0 13 * 1", time.Date(2024, time.March, 4, 0, 0, 0, 0, time.UTC)},
+		{"5/20 10 * * *", time.Date(2024, time.March, 1, 10, 25, 0, 0, time.UTC)},
+		{"0 0 30 2 *", time.Time{}},
+	}
+	for _, tt := range tests {
+		cron, err := ParseCron(tt.expression)
+		if err != nil {
+			t.Errorf("%s: %v", tt.expression, err)
+			continue
+		}
+		if got := cron.Next(now); !got.Equal(tt.want) {
+			t.Errorf("%s: expected %v, got %v", tt.expression, tt.want, got)
+		}
+	}
+}
+
+func TestParseCronErrors(t *testing.T) {
+	for _, expression := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * foo *",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"@every 5m",
+	} {
+		if _, err := ParseCron(expression); err == nil {
+			t.Errorf("%q: expected an error", expression)
+		}
+	}
+}